@@ -0,0 +1,66 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HealthCheck verifies that the resource becomes healthy after it was modified.
+// Deployments, StatefulSets and DaemonSets are healthy, when all their replicas are updated and available,
+// Pods are healthy, when they are Ready. Resources of other kinds are only checked with the Expression.
+type HealthCheck struct {
+	// Timeout limits how long to wait for the resource to become healthy. Default - 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Expression is a CEL expression, evaluated against the resource as `object`, which must be true for
+	// the resource to be healthy, e.g. `object.status.readyReplicas >= 2`. An expression, which can not be evaluated,
+	// e.g. because a field is not set yet, does not hold, until the Timeout passes.
+	// +optional
+	Expression string `json:"expression,omitempty"`
+
+	// Rollback restores the fields changed by the execution, if the resource does not become healthy in time.
+	// +optional
+	Rollback bool `json:"rollback,omitempty"`
+}
+
+// HealthStatus is the outcome of the health verification of the last execution.
+type HealthStatus struct {
+	// Healthy is true, if the resource became healthy in time.
+	Healthy bool `json:"healthy"`
+
+	// Message describes why the resource is not healthy.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartedAt is the time when the verification started, after the resource was modified.
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// CheckedAt is the time when the health of the resource was checked last.
+	CheckedAt metav1.Time `json:"checkedAt"`
+
+	// RolledBack is true, if the changes were reverted, because the resource was not healthy.
+	// +optional
+	RolledBack bool `json:"rolledBack,omitempty"`
+
+	// Changes records fields changed by the execution, which are restored, if the resource does not become healthy.
+	// Only kept while the verification is in progress, and only with Rollback.
+	// +optional
+	Changes *RevertRecord `json:"changes,omitempty"`
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Hooks are Jobs, which are run before and after the actions of ResourceModifier.
+// Hooks of a stage are run one after another, each only after the previous one succeeded.
+type Hooks struct {
+	// Pre hooks are run before the actions. If any of them fails, the actions are not executed, and the execution
+	// fails.
+	// +optional
+	Pre []Hook `json:"pre,omitempty"`
+
+	// Post hooks are run after the actions succeeded. If any of them fails, the execution fails.
+	// +optional
+	Post []Hook `json:"post,omitempty"`
+
+	// CleanupPolicy determines which hook Jobs are deleted, when they finish: all of them (Always), only those which
+	// succeeded (OnSuccess), or none (Never). Jobs which are kept are deleted together with ResourceModifier.
+	// Default - OnSuccess.
+	// +kubebuilder:default=OnSuccess
+	// +optional
+	CleanupPolicy HookCleanupPolicy `json:"cleanupPolicy,omitempty"`
+}
+
+// Hook is a Job, which is run before or after the actions.
+type Hook struct {
+	// Name of the hook, unique within the stage. It is a part of the name of the Job.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=20
+	// +required
+	Name string `json:"name"`
+
+	// Template of the Job. The Job is created in the namespace of ResourceModifier, and owned by it.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +required
+	Template batchv1.JobTemplateSpec `json:"template"`
+
+	// Timeout limits how long the Job may run. When it passes, the hook fails. Default - 10m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HookCleanupPolicy determines which hook Jobs are deleted, when they finish.
+// +kubebuilder:validation:Enum=Always;OnSuccess;Never
+type HookCleanupPolicy string
+
+const (
+	// AlwaysCleanupPolicy deletes hook Jobs, when they finish.
+	AlwaysCleanupPolicy HookCleanupPolicy = "Always"
+
+	// OnSuccessCleanupPolicy deletes hook Jobs, which succeeded, and keeps failed ones for inspection.
+	OnSuccessCleanupPolicy HookCleanupPolicy = "OnSuccess"
+
+	// NeverCleanupPolicy keeps hook Jobs, until ResourceModifier is deleted.
+	NeverCleanupPolicy HookCleanupPolicy = "Never"
+)
+
+// HookStage is a stage of the execution, in which hooks are run.
+// +kubebuilder:validation:Enum=Pre;Post
+type HookStage string
+
+const (
+	// PreHookStage runs hooks before the actions.
+	PreHookStage HookStage = "Pre"
+
+	// PostHookStage runs hooks after the actions.
+	PostHookStage HookStage = "Post"
+)
+
+// HookPhase is a phase of the hook Job.
+// +kubebuilder:validation:Enum=Running;Succeeded;Failed
+type HookPhase string
+
+const (
+	// HookRunning means that the Job was created, and did not finish yet.
+	HookRunning HookPhase = "Running"
+
+	// HookSucceeded means that the Job completed.
+	HookSucceeded HookPhase = "Succeeded"
+
+	// HookFailed means that the Job failed, or did not finish within the timeout.
+	HookFailed HookPhase = "Failed"
+)
+
+// HookStatus is the observed state of a hook Job of the last execution.
+type HookStatus struct {
+	// Name of the hook.
+	Name string `json:"name"`
+
+	// Stage in which the hook is run.
+	Stage HookStage `json:"stage"`
+
+	// JobName is a name of the Job.
+	JobName string `json:"jobName"`
+
+	// Phase of the Job.
+	Phase HookPhase `json:"phase"`
+
+	// Message describes why the hook failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is the time when the Job was created.
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is the time when the Job finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaintenanceWindowSpec defines when ResourceModifiers are allowed to be executed.
+// Execution is allowed inside any of the Windows (or at any time, if there are none), unless it falls into
+// one of the Blackouts.
+type MaintenanceWindowSpec struct {
+	// Windows are recurring time ranges in which execution is allowed.
+	// +optional
+	Windows []TimeWindow `json:"windows,omitempty"`
+
+	// Blackouts are time ranges in which execution is never allowed, e.g. holidays or release freezes.
+	// +optional
+	Blackouts []Blackout `json:"blackouts,omitempty"`
+
+	// TimeZone is a name of the time zone (e.g. "Europe/Stockholm") in which schedules of Windows are interpreted.
+	// Default - time zone of the controller, usually UTC.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// Scope determines which ResourceModifiers are restricted: all of them (All), or only those, which perform
+	// destructive actions, like deleteResource or removeAnyFinalizers, or whose patches remove fields (Destructive).
+	// Default - All.
+	// +kubebuilder:default=All
+	// +optional
+	Scope WindowScope `json:"scope,omitempty"`
+}
+
+// TimeWindow is a recurring time range.
+type TimeWindow struct {
+	// Schedule in cron format (e.g. "0 22 * * 1-5") of the starts of the window.
+	// +required
+	Schedule string `json:"schedule"`
+
+	// Duration of the window (e.g. "8h").
+	// +required
+	Duration metav1.Duration `json:"duration"`
+}
+
+// Blackout is a time range in which execution is not allowed.
+type Blackout struct {
+	// Start of the blackout (RFC3339).
+	// +required
+	Start metav1.Time `json:"start"`
+
+	// End of the blackout (RFC3339).
+	// +required
+	End metav1.Time `json:"end"`
+
+	// Reason is a human-readable description of the blackout, reported in the status of ResourceModifiers.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// WindowScope determines which ResourceModifiers are restricted by the maintenance window.
+// +kubebuilder:validation:Enum=All;Destructive
+type WindowScope string
+
+const (
+	// AllScope restricts execution of all ResourceModifiers.
+	AllScope WindowScope = "All"
+
+	// DestructiveScope restricts only ResourceModifiers performing destructive actions, or removing fields by patches.
+	DestructiveScope WindowScope = "Destructive"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Scope",type=string,JSONPath=`.spec.scope`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MaintenanceWindow is the Schema for the maintenancewindows API. It is referenced by ResourceModifiers in the
+// same namespace, restricting when they are executed.
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MaintenanceWindowSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MaintenanceWindowList contains a list of MaintenanceWindow.
+type MaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaintenanceWindow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MaintenanceWindow{}, &MaintenanceWindowList{})
+}
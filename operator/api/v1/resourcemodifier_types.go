@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,11 +31,14 @@ import (
 //
 // TODO: Potentially, it may be possible to retrieve a list of objects, and perform modification on a list. However, I will introduce another CRD for this purpose
 type TargetResourceData struct {
-	// Labels field will be used to find a specific Kubernetes Resource by watching Labels
-	Labels map[string]string `json:"labels"`
+	// Labels field will be used to find a specific Kubernetes Resource by watching Labels.
+	// Unless ResourceModifier is in Selector mode, labels must match exactly one resource.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
 
 	// Name is used to get a resource with specific metadata.name
-	Name string `json:"name"`
+	// +optional
+	Name string `json:"name,omitempty"`
 
 	// Namespace specifies namespace in which Resources should be searched. Default - default
 	// +kubebuilder:default=default
@@ -59,11 +63,301 @@ type ResourceModifierSpec struct {
 	// It will result in removing any finalizers Pod currently has, and executing a command to sleep for 50 seconds.
 	//
 	// All examples of annotations will be provided in README.
-	Annotations []string `json:"annotations"`
+	// +optional
+	Annotations []string `json:"annotations,omitempty"`
+
+	// Patches are applied to the resource after all annotations were executed, in the order they are listed.
+	// They serve as an escape hatch for modifications which are not covered by annotations.
+	// +optional
+	Patches []Patch `json:"patches,omitempty"`
+
+	// DeleteOptions configure deletion of the resource performed by deleteResource annotation.
+	// +optional
+	DeleteOptions *DeleteOptions `json:"deleteOptions,omitempty"`
+
+	// Mode determines whether ResourceModifier is executed once (OneShot), or the resource is watched and
+	// actions are re-applied whenever it drifts from the desired state (Enforce), or actions are applied once
+	// to every resource matching ResourceData.Labels, including resources created later (Selector).
+	// Default - OneShot.
+	// +kubebuilder:default=OneShot
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// RevertOnDelete makes the controller record previous values of all fields it changed, and restore them
+	// when ResourceModifier is deleted. Fields, which were changed by someone else in the meantime, are not restored.
+	// +optional
+	RevertOnDelete bool `json:"revertOnDelete,omitempty"`
+
+	// Duration limits how long the modification lasts. When it passes after the execution, changed fields are
+	// restored the same way as with RevertOnDelete, and ResourceModifier is marked Expired.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Schedule in cron format (e.g. "0 20 * * 1-5"). When specified, ResourceModifier is executed at the scheduled
+	// times instead of immediately. Only supported in OneShot mode.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// TimeZone is a name of the time zone (e.g. "Europe/Stockholm") in which the Schedule is interpreted.
+	// Default - time zone of the controller, usually UTC.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// ExecuteAt is a time (RFC3339) at which ResourceModifier is executed once. Mutually exclusive with Schedule.
+	// +optional
+	ExecuteAt *metav1.Time `json:"executeAt,omitempty"`
+
+	// StartingDeadlineSeconds is a deadline for starting a scheduled execution, which was missed (e.g. because the
+	// controller was not running). Executions later than the deadline are skipped. Without the deadline, the
+	// latest missed execution is caught up.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// DependsOn lists names of ResourceModifiers in the same namespace, which must succeed with their current
+	// generation, before ResourceModifier is executed. Until then, the WaitingForDependencies condition is set.
+	// +listType=set
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// When lists CEL expressions, which must all evaluate to true against the resource, available as `object`,
+	// before actions are applied, e.g. object.status.phase == 'Pending'. Until then, the PreconditionNotMet condition
+	// is set. Accessing a missing field is an evaluation error, and an expression, which can not be evaluated, counts
+	// as not met; test optional fields with has(), e.g. has(object.spec.nodeName) && object.spec.nodeName == 'node-1'.
+	// The expressions are evaluated again on the state of the resource, which is modified, on every write attempt.
+	// +optional
+	When []string `json:"when,omitempty"`
+
+	// Rollout modifies the resources matching the labels in batches. Only supported in Selector mode.
+	// +optional
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// HealthCheck waits for the resource to become healthy after it was modified, and optionally rolls the changes
+	// back, if it does not. Only supported in OneShot mode.
+	// +optional
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+
+	// Hooks are Jobs, which are run before and after the actions, e.g. to back up data before modifying
+	// a StatefulSet. Only supported in OneShot mode.
+	// +optional
+	Hooks *Hooks `json:"hooks,omitempty"`
+
+	// MaintenanceWindow restricts when ResourceModifier is executed. Outside of the window, execution waits until
+	// the window opens. Mutually exclusive with MaintenanceWindowName.
+	// +optional
+	MaintenanceWindow *MaintenanceWindowSpec `json:"maintenanceWindow,omitempty"`
+
+	// MaintenanceWindowName is a name of the MaintenanceWindow resource in the namespace of ResourceModifier,
+	// which restricts when ResourceModifier is executed.
+	// +optional
+	MaintenanceWindowName string `json:"maintenanceWindowName,omitempty"`
+
+	// Atomic makes the execution all-or-nothing: if any of the actions fails, the resource is not written at all.
+	// Otherwise, changes made by the actions before the failed one are written.
+	// In both cases, all actions are composed in memory, and the resource is written once, except that deletion
+	// writes the changes made so far first.
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+
+	// WriteStrategy determines how the resource is written: as a JSON merge patch of changed fields, guarded by
+	// the resourceVersion (Patch), or by Server-Side Apply (ServerSideApply), making the controller the owner of
+	// the changed fields in managedFields. Default - Patch.
+	// +kubebuilder:default=Patch
+	// +optional
+	WriteStrategy WriteStrategy `json:"writeStrategy,omitempty"`
+
+	// ForceConflicts makes Server-Side Apply take over fields owned by other managers. Otherwise, such conflicts
+	// fail the execution, and are reported in the FieldConflict condition.
+	// +optional
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+
+	// DryRun makes the controller execute all actions in the API server's dry-run mode, so nothing is persisted.
+	// Instead, a unified diff of the resources is recorded in the status, showing the effect of a real run.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// RetryPolicy determines how failed executions are retried. Without it, conflicts and unavailability of the API
+	// server are retried up to 5 times, and other errors fail the execution immediately.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Timeouts override the controller's timeouts of requests to the API server and of actions.
+	// +optional
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+
+	// RunID is an arbitrary token. ResourceModifier is executed once per generation, so changing the RunID
+	// executes it again, without changing anything else in the spec.
+	// Alternatively, the RerunAnnotation can be set on the ResourceModifier.
+	// +optional
+	RunID string `json:"runID,omitempty"`
+}
+
+// Mode is an execution mode of ResourceModifier.
+// +kubebuilder:validation:Enum=OneShot;Enforce;Selector
+type Mode string
+
+const (
+	// OneShotMode executes ResourceModifier once per generation.
+	OneShotMode Mode = "OneShot"
+
+	// EnforceMode watches the resource, and re-applies actions whenever the resource drifts.
+	EnforceMode Mode = "Enforce"
+
+	// SelectorMode watches resources matching the labels, and applies actions once to each of them.
+	SelectorMode Mode = "Selector"
+)
+
+// WriteStrategy determines how the resource is written.
+// +kubebuilder:validation:Enum=Patch;ServerSideApply
+type WriteStrategy string
+
+const (
+	// PatchWriteStrategy writes a JSON merge patch of changed fields, with the resourceVersion as a precondition.
+	PatchWriteStrategy WriteStrategy = "Patch"
+
+	// ServerSideApplyWriteStrategy writes changed fields by Server-Side Apply.
+	ServerSideApplyWriteStrategy WriteStrategy = "ServerSideApply"
+)
+
+// RetryPolicy determines how failed executions are retried.
+type RetryPolicy struct {
+	// MaxAttempts is a maximum number of executions, including the first one. When it is reached, the execution
+	// fails. Default - 5.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// InitialBackoff is a delay before the first retry. It doubles with every retry. Default - 10s.
+	// +optional
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+
+	// MaxBackoff is a maximum delay between retries. Default - 5m.
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+
+	// RetryOn lists classes of errors, which are retried. Errors of other classes fail the execution immediately.
+	// Default - Conflict and Unavailable.
+	// +listType=set
+	// +optional
+	RetryOn []ErrorClass `json:"retryOn,omitempty"`
+}
+
+// Timeouts limit how long the execution of ResourceModifier may take.
+type Timeouts struct {
+	// Operation limits a single request to the API server. Default - --operation-timeout flag of the controller (5s).
+	// +optional
+	Operation *metav1.Duration `json:"operation,omitempty"`
+
+	// Action limits a single annotation or patch, including waiting, e.g. for the deletion of the resource.
+	// Default - --action-timeout flag of the controller (10m).
+	// +optional
+	Action *metav1.Duration `json:"action,omitempty"`
+}
+
+// ErrorClass is a class of errors, which fail the execution.
+// +kubebuilder:validation:Enum=Conflict;Unavailable;NotFound;Forbidden;Invalid;Other
+type ErrorClass string
+
+const (
+	// ConflictErrorClass means that the resource was changed by someone else during the execution.
+	ConflictErrorClass ErrorClass = "Conflict"
+
+	// UnavailableErrorClass means that the API server timed out, throttled the request, or was unavailable.
+	UnavailableErrorClass ErrorClass = "Unavailable"
+
+	// NotFoundErrorClass means that the resource does not exist, or no resource matches the labels.
+	NotFoundErrorClass ErrorClass = "NotFound"
+
+	// ForbiddenErrorClass means that the controller is not allowed to access the resource.
+	ForbiddenErrorClass ErrorClass = "Forbidden"
+
+	// InvalidErrorClass means that the API server rejected the modified resource.
+	InvalidErrorClass ErrorClass = "Invalid"
+
+	// OtherErrorClass covers all other errors, e.g. actions not applicable to the resource, failed tests of JSON
+	// patches, or conflicts with fields owned by other managers.
+	OtherErrorClass ErrorClass = "Other"
+)
+
+const (
+	// RerunAnnotation is an annotation of ResourceModifier. Changing its value executes ResourceModifier again.
+	RerunAnnotation = "annot-resource-modif.ericsson.com/rerun"
+
+	// RevertFinalizer is a finalizer of ResourceModifier, which restores modified fields upon its deletion.
+	RevertFinalizer = "annot-resource-modif.ericsson.com/revert"
+)
+
+// DeleteOptions configure how the resource is deleted by deleteResource annotation.
+type DeleteOptions struct {
+	// PropagationPolicy determines how dependents of the resource are garbage collected.
+	// Defaults to the policy of the resource (usually Background).
+	// +kubebuilder:validation:Enum=Foreground;Background;Orphan
+	// +optional
+	PropagationPolicy *metav1.DeletionPropagation `json:"propagationPolicy,omitempty"`
+
+	// GracePeriodSeconds overrides the grace period of the resource. Zero means immediate deletion.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+
+	// UID, if specified, must be equal to the UID of the resource, otherwise it is not deleted.
+	// Regardless of this field, the UID of the resource found by the controller is always used as a precondition,
+	// so a resource recreated with the same name in the meantime is never deleted.
+	// +optional
+	UID string `json:"uid,omitempty"`
+
+	// ResourceVersion, if specified, must be equal to the resourceVersion of the resource, otherwise it is not deleted.
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// WaitForDeletion makes the controller wait until the resource is gone from the API server.
+	// +optional
+	WaitForDeletion bool `json:"waitForDeletion,omitempty"`
+
+	// Timeout limits how long the controller waits for the resource to be gone. Default - 1m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// PatchType is a type of patch, which will be applied to the resource.
+// +kubebuilder:validation:Enum=json;merge;strategic
+type PatchType string
+
+const (
+	// JSONPatchType is RFC 6902 JSON Patch. Operations of type "test" can be used as preconditions:
+	// if any of them fails, the patch is not applied at all.
+	JSONPatchType PatchType = "json"
+
+	// MergePatchType is RFC 7386 JSON Merge Patch.
+	MergePatchType PatchType = "merge"
+
+	// StrategicMergePatchType is Kubernetes strategic merge patch. It is supported only for built-in resource types.
+	StrategicMergePatchType PatchType = "strategic"
+)
+
+// Patch is a patch, which will be applied to the resource.
+type Patch struct {
+	// Type specifies how the Patch is interpreted.
+	// +required
+	Type PatchType `json:"type"`
+
+	// Patch is a content of the patch, supplied as structured YAML.
+	// For json type it is a list of operations, for merge and strategic types it is a partial object.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +required
+	Patch apiextensionsv1.JSON `json:"patch"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Next Run",type=date,JSONPath=`.status.nextScheduleTime`,priority=1
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expirationTime`,priority=1
+// +kubebuilder:printcolumn:name="Drifts",type=integer,JSONPath=`.status.driftCount`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ResourceModifier is the Schema for the resourcemodifiers API.
 type ResourceModifier struct {
@@ -0,0 +1,76 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Rollout paces the execution of ResourceModifier in Selector mode, so the matching resources are modified in
+// batches rather than all at once.
+type Rollout struct {
+	// BatchSize is a number of resources, or a percentage of the matching resources (rounded up), modified in
+	// a batch. Default - all of them.
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	BatchSize *intstr.IntOrString `json:"batchSize,omitempty"`
+
+	// Canary is a number of resources, or a percentage of the matching resources (rounded up), modified in the first
+	// batch. Default - BatchSize.
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	Canary *intstr.IntOrString `json:"canary,omitempty"`
+
+	// Pause between batches.
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+
+	// MaxFailures is a number of resources, or a percentage of the matching resources (rounded down), which may fail
+	// before the rollout is aborted. Failed resources are not retried. Default - 0, the first failure aborts.
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	MaxFailures *intstr.IntOrString `json:"maxFailures,omitempty"`
+}
+
+// RolloutStatus is the progress of the rollout.
+type RolloutStatus struct {
+	// Batch is a number of batches started.
+	// +optional
+	Batch int32 `json:"batch,omitempty"`
+
+	// Targets is a number of the matching resources.
+	// +optional
+	Targets int32 `json:"targets,omitempty"`
+
+	// Processed is a number of the matching resources, which were modified.
+	// +optional
+	Processed int32 `json:"processed,omitempty"`
+
+	// FailedUIDs lists UIDs of the matching resources, which failed to be modified.
+	// +optional
+	FailedUIDs []string `json:"failedUIDs,omitempty"`
+
+	// NextBatchTime is the time when the pause after the last batch ends.
+	// +optional
+	NextBatchTime *metav1.Time `json:"nextBatchTime,omitempty"`
+
+	// Aborted is true, if more resources failed than allowed by MaxFailures. Aborted rollout is resumed, when
+	// the spec or the RerunAnnotation of ResourceModifier changes.
+	// +optional
+	Aborted bool `json:"aborted,omitempty"`
+}
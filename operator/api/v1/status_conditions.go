@@ -1,11 +1,59 @@
 package v1
 
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 const (
 	// StatusSuccess is a key to Conditions map, which indicates that there were no errors during Reconciliation
 	StatusSuccess = "Success"
 
 	// StatusError is a key to Conditions map, which indicates that there were errors during Reconciliation
 	StatusError = "Error"
+
+	// StatusWaitingForWindow is a key to Conditions map, which indicates that execution waits for the maintenance
+	// window to open
+	StatusWaitingForWindow = "WaitingForWindow"
+
+	// StatusWaitingForDependencies is a key to Conditions map, which indicates that execution waits for
+	// ResourceModifiers listed in DependsOn to succeed
+	StatusWaitingForDependencies = "WaitingForDependencies"
+
+	// StatusPreconditionNotMet is a key to Conditions map, which indicates that execution waits for the resource
+	// to satisfy the When expressions
+	StatusPreconditionNotMet = "PreconditionNotMet"
+
+	// StatusHealthCheckStarted is a key to Conditions map, which indicates that the resource was modified, and
+	// the execution waits for it to become healthy
+	StatusHealthCheckStarted = "HealthCheckStarted"
+
+	// StatusLocked is a key to Conditions map, which indicates that the resource is locked by another
+	// ResourceModifier, so the execution is retried later
+	StatusLocked = "Locked"
+
+	// StatusFieldConflict is a key to Conditions map, which indicates that the resource could not be written,
+	// because of a conflict with another manager
+	StatusFieldConflict = "FieldConflict"
+
+	// StatusTooManyMissedSchedules is a key to Conditions map, which indicates that more scheduled executions were
+	// missed than are looked through, e.g. because the controller was down, and only the latest one was considered
+	StatusTooManyMissedSchedules = "TooManyMissedSchedules"
+
+	// StatusFailed is a key to Conditions map, which indicates that the execution failed, and is not retried
+	StatusFailed = "Failed"
+)
+
+// Phase is a phase of the execution of ResourceModifier.
+// +kubebuilder:validation:Enum=Succeeded;Failed;Expired
+type Phase string
+
+const (
+	// PhaseSucceeded means that all annotations and patches were applied to the resource
+	PhaseSucceeded Phase = "Succeeded"
+
+	// PhaseFailed means that the execution was stopped by an error
+	PhaseFailed Phase = "Failed"
+
+	// PhaseExpired means that the duration of the modification passed, and changes were reverted
+	PhaseExpired Phase = "Expired"
 )
 
 // ResourceModifierStatus defines the observed state of ResourceModifier.
@@ -15,6 +63,152 @@ type ResourceModifierStatus struct {
 	// If Reconciliation was successful - this fields will also be updated, with
 	// successful condition type and appropriate message.
 	Conditions map[string]string `json:"conditions"`
+
+	// AppliedPatches lists patches which were applied to the resource during the last reconciliation.
+	// +optional
+	AppliedPatches []AppliedPatch `json:"appliedPatches,omitempty"`
+
+	// Phase is a terminal phase of the last execution. Empty, if ResourceModifier was not executed yet.
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of ResourceModifier, which was executed last.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ObservedRerun is the value of RerunAnnotation at the time of the last execution.
+	// +optional
+	ObservedRerun string `json:"observedRerun,omitempty"`
+
+	// CompletionTime is the time when the last execution was finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// ExpirationTime is the time when the modification is reverted. Only used, when Duration is specified.
+	// +optional
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+
+	// LastScheduleTime is the latest scheduled time, which was either executed or skipped.
+	// Only used, when Schedule or ExecuteAt is specified.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is the next scheduled time of the execution.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// SkippedRuns is a number of scheduled executions, which were skipped, because they were missed by more than
+	// StartingDeadlineSeconds.
+	// +optional
+	SkippedRuns int64 `json:"skippedRuns,omitempty"`
+
+	// DriftCount is a number of times the resource drifted from the desired state, and was corrected.
+	// Only used in Enforce mode.
+	// +optional
+	DriftCount int64 `json:"driftCount,omitempty"`
+
+	// LastDriftTime is the time when the drift was corrected last.
+	// +optional
+	LastDriftTime *metav1.Time `json:"lastDriftTime,omitempty"`
+
+	// ProcessedUIDs lists UIDs of existing resources, to which actions were already applied.
+	// Only used in Selector mode.
+	// +optional
+	ProcessedUIDs []string `json:"processedUIDs,omitempty"`
+
+	// Rollout is the progress of the rollout. Only used in Selector mode with Rollout.
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Attempts is a number of attempts to execute the actions during the last reconciliation, including retries
+	// after retryable errors, e.g. when the resource was changed by someone else meanwhile.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// FailedAttempts is a number of consecutive failed executions, which were retried according to the RetryPolicy.
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+
+	// NextRetryTime is the time when the failed execution is retried.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// Health is the outcome of the health verification of the last execution.
+	// +optional
+	Health *HealthStatus `json:"health,omitempty"`
+
+	// HookStage is the stage of the execution, whose hooks are running. Empty, when no hooks are running.
+	// +optional
+	HookStage HookStage `json:"hookStage,omitempty"`
+
+	// Hooks lists hook Jobs of the last execution.
+	// +optional
+	Hooks []HookStatus `json:"hooks,omitempty"`
+
+	// DryRunDiff is a unified diff of the resources in YAML, showing the changes which the last execution in
+	// DryRun mode would have made.
+	// +optional
+	DryRunDiff string `json:"dryRunDiff,omitempty"`
+
+	// RevertRecords list fields changed by ResourceModifier, with their previous values.
+	// Only used when RevertOnDelete is enabled.
+	// +optional
+	RevertRecords []RevertRecord `json:"revertRecords,omitempty"`
+}
+
+// RevertRecord records fields of a resource, which were changed by ResourceModifier.
+type RevertRecord struct {
+	// APIVersion of the modified resource. Records without it refer to the resource type of the spec.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the modified resource.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// UID of the modified resource. A resource recreated with the same name is not reverted.
+	UID string `json:"uid"`
+
+	// Name of the modified resource.
+	Name string `json:"name"`
+
+	// Namespace of the modified resource.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Fields lists changed fields.
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange records a change of a single field of a resource.
+type FieldChange struct {
+	// Path is a JSON pointer to the field, e.g. /metadata/labels/env.
+	Path string `json:"path"`
+
+	// Previous is the JSON-encoded value of the field before the first modification.
+	// Absent, if the field did not exist.
+	// +optional
+	Previous *string `json:"previous,omitempty"`
+
+	// Applied is the JSON-encoded value of the field after the last modification.
+	// Absent, if the field was removed.
+	// +optional
+	Applied *string `json:"applied,omitempty"`
+}
+
+// AppliedPatch records a patch which was applied to the resource.
+type AppliedPatch struct {
+	// Type of the applied patch.
+	Type PatchType `json:"type"`
+
+	// Patch is the applied patch in JSON format.
+	Patch string `json:"patch"`
+
+	// ResourceVersion is the resourceVersion of the resource after the patch was applied.
+	ResourceVersion string `json:"resourceVersion"`
+
+	// AppliedAt is the time when the patch was applied.
+	AppliedAt metav1.Time `json:"appliedAt"`
 }
 
 // ErrorStatus initializes/updates the Conditions field with key StatusError and reason as value
@@ -29,3 +223,24 @@ func (r *ResourceModifierStatus) SuccessfulStatus(reason string) {
 	}
 	r.Conditions[StatusSuccess] = reason
 }
+
+// Completed records that the execution of given generation and rerun token finished in given phase.
+func (r *ResourceModifierStatus) Completed(phase Phase, generation int64, rerun string) {
+	now := metav1.Now()
+	r.Phase = phase
+	r.ObservedGeneration = generation
+	r.ObservedRerun = rerun
+	r.CompletionTime = &now
+}
+
+// IsCompleted returns true, if ResourceModifier was already executed with given generation and rerun token.
+func (r *ResourceModifierStatus) IsCompleted(generation int64, rerun string) bool {
+	return r.Phase != "" && r.ObservedGeneration == generation && r.ObservedRerun == rerun
+}
+
+// DriftCorrected records that the resource drifted from the desired state, and was corrected.
+func (r *ResourceModifierStatus) DriftCorrected() {
+	now := metav1.Now()
+	r.DriftCount++
+	r.LastDriftTime = &now
+}
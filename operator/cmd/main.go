@@ -20,15 +20,20 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"time"
+	// Embed the time zone database, so spec.timeZone of ResourceModifiers works in images without zoneinfo.
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -59,6 +64,11 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var operationTimeout time.Duration
+	var actionTimeout time.Duration
+	var targetLocks bool
+	var lockNamespace string
+	var lockDuration time.Duration
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -70,6 +80,19 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&operationTimeout, "operation-timeout", controller.DefaultOperationTimeout,
+		"Timeout of a single request to the API server, unless a ResourceModifier specifies spec.timeouts.operation.")
+	flag.DurationVar(&actionTimeout, "action-timeout", controller.DefaultActionTimeout,
+		"Timeout of a single action, including waiting (e.g. for deletion), "+
+			"unless a ResourceModifier specifies spec.timeouts.action.")
+	flag.BoolVar(&targetLocks, "target-locks", true,
+		"If set, resources are locked with Leases while actions are applied, so concurrent ResourceModifiers "+
+			"(or replicas of the controller) do not modify the same resource at once.")
+	flag.StringVar(&lockNamespace, "lock-namespace", controller.DefaultLockNamespace,
+		"The namespace of Leases locking cluster-scoped resources.")
+	flag.DurationVar(&lockDuration, "lock-duration", controller.DefaultLockDuration,
+		"How long a Lease locks a resource, unless it is renewed. Leases are renewed every third of it. "+
+			"At least 1s.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -78,6 +101,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if lockDuration < controller.MinLockDuration {
+		setupLog.Error(nil, "lock duration is too short", "lock-duration", lockDuration,
+			"minimum", controller.MinLockDuration)
+		os.Exit(1)
+	}
+
 	disableHTTP2 := func(c *tls.Config) {
 		setupLog.Info("disabling http/2")
 		c.NextProtos = []string{"http/1.1"}
@@ -112,6 +141,10 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "bd6a95ca.ericsson.com",
+		// Leases locking resources are read directly, rather than caching all Leases of the cluster
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: []client.Object{&coordinationv1.Lease{}}},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -119,8 +152,16 @@ func main() {
 	}
 
 	if err = (&controller.ResourceModifierReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    client.WithFieldOwner(mgr.GetClient(), controller.FieldManager),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("resourcemodifier-controller"),
+
+		OperationTimeout: operationTimeout,
+		ActionTimeout:    actionTimeout,
+		TargetLocks:      targetLocks,
+		LockNamespace:    lockNamespace,
+		LockDuration:     lockDuration,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ResourceModifier")
 		os.Exit(1)
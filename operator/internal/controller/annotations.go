@@ -6,7 +6,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"slices"
 	"strings"
-	"time"
 )
 
 const (
@@ -22,37 +21,20 @@ const (
 
 // executeRemoveAnyFinalizerAnnotation
 // This function removes any finalizers from the resource, if there were one.
-func (r *ResourceModifierReconciler) executeRemoveAnyFinalizerAnnotation(resource client.Object,
-	rm annotresourcemodifv1.ResourceModifier) error {
+func (r *ResourceModifierReconciler) executeRemoveAnyFinalizerAnnotation(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) error {
 	if resource.GetFinalizers() == nil {
 		return nil
 	}
 
 	resource.SetFinalizers(nil)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err := r.Client.Update(ctx, resource)
-	if err != nil {
-		return err
-	}
-
-	err = r.updateStatusSuccess(rm, successRemovingFinalizers)
-	if err != nil {
-		updateErr := r.updateErrorStatus(rm, err.Error())
-		if updateErr != nil {
-			return updateErr
-		}
-		return err
-	}
-
-	return nil
+	return r.updateResource(ctx, resource, rm, successRemovingFinalizers)
 }
 
 // executeAddFinalizer adds provided finalizer to the target resource.
-func (r *ResourceModifierReconciler) executeAddFinalizer(resource client.Object,
-	rm annotresourcemodifv1.ResourceModifier, finalizer string) error {
+func (r *ResourceModifierReconciler) executeAddFinalizer(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, finalizer string) error {
 	existentFinalizers := resource.GetFinalizers()
 	if slices.Contains(existentFinalizers, finalizer) {
 		return nil
@@ -61,29 +43,12 @@ func (r *ResourceModifierReconciler) executeAddFinalizer(resource client.Object,
 
 	resource.SetFinalizers(existentFinalizers)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err := r.Client.Update(ctx, resource)
-	if err != nil {
-		return err
-	}
-
-	err = r.updateStatusSuccess(rm, successAddFinalizers)
-	if err != nil {
-		updateErr := r.updateErrorStatus(rm, err.Error())
-		if updateErr != nil {
-			return updateErr
-		}
-		return err
-	}
-
-	return nil
+	return r.updateResource(ctx, resource, rm, successAddFinalizers)
 }
 
 // executeAddLabel adds new label to the resource.
-func (r *ResourceModifierReconciler) executeAddLabel(resource client.Object,
-	rm annotresourcemodifv1.ResourceModifier, label string) error {
+func (r *ResourceModifierReconciler) executeAddLabel(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, label string) error {
 	labels := resource.GetLabels()
 	s := strings.Split(label, ":")
 	key, value := s[0], s[1]
@@ -91,31 +56,18 @@ func (r *ResourceModifierReconciler) executeAddLabel(resource client.Object,
 	if _, exists := labels[key]; exists {
 		return nil
 	}
-	labels[key] = value
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err := r.Client.Update(ctx, resource)
-	if err != nil {
-		return err
-	}
-
-	err = r.updateStatusSuccess(rm, successAddLabel)
-	if err != nil {
-		updateErr := r.updateErrorStatus(rm, err.Error())
-		if updateErr != nil {
-			return updateErr
-		}
-		return err
+	if labels == nil {
+		labels = make(map[string]string)
 	}
+	labels[key] = value
+	resource.SetLabels(labels)
 
-	return nil
+	return r.updateResource(ctx, resource, rm, successAddLabel)
 }
 
 // executeAddLabel removes label from the resource.
-func (r *ResourceModifierReconciler) executeRemoveLabel(resource client.Object,
-	rm annotresourcemodifv1.ResourceModifier, label string) error {
+func (r *ResourceModifierReconciler) executeRemoveLabel(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, label string) error {
 	labels := resource.GetLabels()
 
 	if _, exists := labels[label]; !exists {
@@ -123,17 +75,23 @@ func (r *ResourceModifierReconciler) executeRemoveLabel(resource client.Object,
 	}
 	delete(labels, label)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	return r.updateResource(ctx, resource, rm, successAddLabel)
+}
+
+// updateResource updates the target resource, and records reason as successful status of ResourceModifier.
+func (r *ResourceModifierReconciler) updateResource(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, reason string) error {
+	updateCtx, cancel := r.operationContext(ctx)
 	defer cancel()
 
-	err := r.Client.Update(ctx, resource)
+	err := r.Client.Update(updateCtx, resource)
 	if err != nil {
 		return err
 	}
 
-	err = r.updateStatusSuccess(rm, successAddLabel)
+	err = r.updateStatusSuccess(ctx, rm, reason)
 	if err != nil {
-		updateErr := r.updateErrorStatus(rm, err.Error())
+		updateErr := r.updateErrorStatus(ctx, rm, err.Error())
 		if updateErr != nil {
 			return updateErr
 		}
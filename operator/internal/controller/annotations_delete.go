@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// successDeleteResource
+	successDeleteResource = "Successfully deleted resource"
+
+	// defaultDeletionTimeout is used, when DeleteOptions do not specify how long to wait for the resource to be gone
+	defaultDeletionTimeout = time.Minute
+
+	// deletionPollInterval is an interval between checks whether the resource is gone
+	deletionPollInterval = time.Second
+)
+
+// executeDeleteResource deletes the resource according to DeleteOptions of the ResourceModifier.
+// The UID of the resource is always sent as a precondition, so that a resource recreated with the same name
+// after it was fetched is not deleted. If the resource still exists after deletion (e.g. it has finalizers),
+// it is re-fetched, so that the following annotations operate on its current state.
+func (r *ResourceModifierReconciler) executeDeleteResource(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) error {
+	options := rm.Spec.DeleteOptions
+	if options == nil {
+		options = &annotresourcemodifv1.DeleteOptions{}
+	}
+
+	uid := resource.GetUID()
+	if options.UID != "" && types.UID(options.UID) != uid {
+		return fmt.Errorf("precondition failed: expected UID %s, resource has UID %s", options.UID, uid)
+	}
+
+	preconditions := metav1.Preconditions{UID: &uid}
+	if options.ResourceVersion != "" {
+		preconditions.ResourceVersion = &options.ResourceVersion
+	}
+	deleteOptions := []client.DeleteOption{client.Preconditions(preconditions)}
+	if options.PropagationPolicy != nil {
+		deleteOptions = append(deleteOptions, client.PropagationPolicy(*options.PropagationPolicy))
+	}
+	if options.GracePeriodSeconds != nil {
+		deleteOptions = append(deleteOptions, client.GracePeriodSeconds(*options.GracePeriodSeconds))
+	}
+
+	deleteCtx, cancel := r.operationContext(ctx)
+	err := r.Client.Delete(deleteCtx, resource, deleteOptions...)
+	cancel()
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if options.WaitForDeletion && !rm.Spec.DryRun {
+		timeout := defaultDeletionTimeout
+		if options.Timeout != nil {
+			timeout = options.Timeout.Duration
+		}
+		if err = r.waitForDeletion(ctx, resource, uid, timeout); err != nil {
+			return err
+		}
+	} else if err == nil {
+		getCtx, cancel := r.operationContext(ctx)
+		err = r.Client.Get(getCtx, client.ObjectKeyFromObject(resource), resource)
+		cancel()
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	err = r.updateStatusSuccess(ctx, rm, successDeleteResource)
+	if err != nil {
+		updateErr := r.updateErrorStatus(ctx, rm, err.Error())
+		if updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// waitForDeletion polls the API server until the resource with given UID is gone, or timeout expires.
+// Waiting is limited by the action timeout carried by ctx as well.
+func (r *ResourceModifierReconciler) waitForDeletion(ctx context.Context, resource client.Object, uid types.UID,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	current, ok := resource.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Errorf("unable to copy %T", resource)
+	}
+
+	err := wait.PollUntilContextCancel(ctx, deletionPollInterval, true, func(ctx context.Context) (bool, error) {
+		getCtx, cancel := r.operationContext(ctx)
+		defer cancel()
+
+		err := r.Client.Get(getCtx, client.ObjectKeyFromObject(resource), current)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return current.GetUID() != uid, nil
+	})
+	if err != nil {
+		return fmt.Errorf("resource was not deleted within %s: %w", timeout, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestResourceModifierReconciler_executeDeleteResource(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	background := metav1.DeletePropagationBackground
+
+	tests := []struct {
+		name          string
+		finalizers    []string
+		deleteOptions *v1.DeleteOptions
+		wantErr       bool
+		wantDeleted   bool
+	}{
+		{
+			name:        "Delete with default options",
+			wantDeleted: true,
+		},
+		{
+			name: "Delete and wait until the resource is gone",
+			deleteOptions: &v1.DeleteOptions{
+				PropagationPolicy: &background,
+				WaitForDeletion:   true,
+				Timeout:           &metav1.Duration{Duration: time.Second * 5},
+			},
+			wantDeleted: true,
+		},
+		{
+			name:          "UID precondition does not match",
+			deleteOptions: &v1.DeleteOptions{UID: "another-uid"},
+			wantErr:       true,
+		},
+		{
+			name:          "ResourceVersion precondition does not match",
+			deleteOptions: &v1.DeleteOptions{ResourceVersion: "42"},
+			wantErr:       true,
+		},
+		{
+			name:       "Resource with finalizers is only marked for deletion",
+			finalizers: []string{"test-finalizer"},
+		},
+		{
+			name:       "Timeout while waiting for resource with finalizers",
+			finalizers: []string{"test-finalizer"},
+			deleteOptions: &v1.DeleteOptions{
+				WaitForDeletion: true,
+				Timeout:         &metav1.Duration{Duration: time.Millisecond * 100},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-pod",
+					Namespace:  "test-ns",
+					UID:        "test-uid",
+					Finalizers: tt.finalizers,
+				},
+			}
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rm-test",
+				},
+				Spec: v1.ResourceModifierSpec{
+					DeleteOptions: tt.deleteOptions,
+				},
+			}
+			rm.Status.Conditions = make(map[string]string)
+
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+				Scheme: scheme,
+			}
+
+			target := &v2.Pod{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), target))
+
+			err := r.executeAnnotation(context.Background(), "deleteResource", target, rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			got := &v2.Pod{}
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(pod), got)
+			if tt.wantDeleted {
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			assert.Nil(t, err)
+			if len(tt.finalizers) > 0 {
+				assert.NotNil(t, got.DeletionTimestamp)
+			}
+			if len(tt.finalizers) > 0 && !tt.wantErr {
+				assert.Equal(t, got.DeletionTimestamp, target.DeletionTimestamp)
+			}
+		})
+	}
+}
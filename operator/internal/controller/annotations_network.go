@@ -0,0 +1,560 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	v2 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// successSetServiceType
+	successSetServiceType = "Successfully updated service type"
+
+	// successAddServicePort
+	successAddServicePort = "Successfully added service port"
+
+	// successRemoveServicePort
+	successRemoveServicePort = "Successfully removed service port"
+
+	// successUpdateServiceSelector
+	successUpdateServiceSelector = "Successfully updated service selector"
+
+	// successSetExternalTrafficPolicy
+	successSetExternalTrafficPolicy = "Successfully updated external traffic policy"
+
+	// successSetIngressHost
+	successSetIngressHost = "Successfully updated ingress host"
+
+	// successSetIngressTLSSecret
+	successSetIngressTLSSecret = "Successfully updated ingress TLS secret"
+
+	// successAddIngressPath
+	successAddIngressPath = "Successfully added ingress path"
+
+	// successRemoveIngressPath
+	successRemoveIngressPath = "Successfully removed ingress path"
+
+	// notAService is an error message indicating that action can be performed only on a Service
+	notAService = "Action can be performed only on a Service, got: "
+
+	// notAnIngress is an error message indicating that action can be performed only on an Ingress
+	notAnIngress = "Action can be performed only on an Ingress, got: "
+
+	// minNodePort is the lowest port of default node port range of the API server
+	minNodePort = 30000
+
+	// maxNodePort is the highest port of default node port range of the API server
+	maxNodePort = 32767
+)
+
+// ingressPathRule is a parsed representation of addIngressPath annotation.
+type ingressPathRule struct {
+	host string
+	path networking.HTTPIngressPath
+}
+
+// serviceFromResource casts the resource to a Service.
+func serviceFromResource(resource client.Object) (*v2.Service, error) {
+	service, ok := resource.(*v2.Service)
+	if !ok {
+		return nil, fmt.Errorf("%s%T", notAService, resource)
+	}
+	return service, nil
+}
+
+// ingressFromResource casts the resource to an Ingress.
+func ingressFromResource(resource client.Object) (*networking.Ingress, error) {
+	ingress, ok := resource.(*networking.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("%s%T", notAnIngress, resource)
+	}
+	return ingress, nil
+}
+
+// parsePort parses port number, and checks that it is in range [low, high].
+func parsePort(annotation, port string, low, high int) (int32, error) {
+	number, err := strconv.Atoi(port)
+	if err != nil || number < low || number > high {
+		return 0, fmt.Errorf("%s%s: port %s must be a number in range %d-%d", invalidAnnotation, annotation, port, low, high)
+	}
+	return int32(number), nil
+}
+
+// parseServiceType parses setServiceType:<type>[:<externalName>] annotation arguments.
+// External name is required for ExternalName type.
+func parseServiceType(annotation string, args []string) (v2.ServiceType, string, error) {
+	if err := requireArgs(annotation, args, 1); err != nil {
+		return "", "", err
+	}
+
+	serviceType := v2.ServiceType(args[0])
+	switch serviceType {
+	case v2.ServiceTypeClusterIP, v2.ServiceTypeNodePort, v2.ServiceTypeLoadBalancer:
+		return serviceType, "", nil
+	case v2.ServiceTypeExternalName:
+		if len(args) < 2 || args[1] == "" {
+			return "", "", fmt.Errorf("%s%s: external name is required for ExternalName service", invalidAnnotation, annotation)
+		}
+		if err := validateHost(annotation, args[1]); err != nil {
+			return "", "", err
+		}
+		return serviceType, args[1], nil
+	}
+
+	return "", "", fmt.Errorf("%s%s: unknown service type %s", invalidAnnotation, annotation, args[0])
+}
+
+// parseServicePort parses addServicePort:<name>:<port>[:<targetPort>[:<protocol>[:<nodePort>]]] annotation
+// arguments. Target port may be either a number, or a name of container port.
+func parseServicePort(annotation string, args []string) (v2.ServicePort, error) {
+	if err := requireArgs(annotation, args, 2); err != nil {
+		return v2.ServicePort{}, err
+	}
+
+	servicePort := v2.ServicePort{Name: args[0], Protocol: v2.ProtocolTCP}
+	if errs := validation.IsDNS1123Label(servicePort.Name); len(errs) > 0 {
+		return v2.ServicePort{}, fmt.Errorf("%s%s: invalid port name: %s", invalidAnnotation, annotation, strings.Join(errs, ", "))
+	}
+
+	port, err := parsePort(annotation, args[1], 1, 65535)
+	if err != nil {
+		return v2.ServicePort{}, err
+	}
+	servicePort.Port = port
+
+	if len(args) > 2 && args[2] != "" {
+		targetPort := intstr.Parse(args[2])
+		if targetPort.Type == intstr.Int {
+			if _, err := parsePort(annotation, args[2], 1, 65535); err != nil {
+				return v2.ServicePort{}, err
+			}
+		} else if errs := validation.IsValidPortName(args[2]); len(errs) > 0 {
+			return v2.ServicePort{}, fmt.Errorf("%s%s: invalid target port: %s", invalidAnnotation, annotation, strings.Join(errs, ", "))
+		}
+		servicePort.TargetPort = targetPort
+	}
+
+	if len(args) > 3 && args[3] != "" {
+		protocol := v2.Protocol(args[3])
+		switch protocol {
+		case v2.ProtocolTCP, v2.ProtocolUDP, v2.ProtocolSCTP:
+			servicePort.Protocol = protocol
+		default:
+			return v2.ServicePort{}, fmt.Errorf("%s%s: unknown protocol %s", invalidAnnotation, annotation, args[3])
+		}
+	}
+
+	if len(args) > 4 && args[4] != "" {
+		nodePort, err := parsePort(annotation, args[4], minNodePort, maxNodePort)
+		if err != nil {
+			return v2.ServicePort{}, err
+		}
+		servicePort.NodePort = nodePort
+	}
+
+	return servicePort, nil
+}
+
+// parseExternalTrafficPolicy parses setExternalTrafficPolicy:<Cluster|Local> annotation arguments.
+func parseExternalTrafficPolicy(annotation string, args []string) (v2.ServiceExternalTrafficPolicy, error) {
+	if err := requireArgs(annotation, args, 1); err != nil {
+		return "", err
+	}
+
+	policy := v2.ServiceExternalTrafficPolicy(args[0])
+	switch policy {
+	case v2.ServiceExternalTrafficPolicyCluster, v2.ServiceExternalTrafficPolicyLocal:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("%s%s: unknown external traffic policy %s", invalidAnnotation, annotation, args[0])
+}
+
+// parseIngressPath parses addIngressPath:<host>:<path>:<pathType>:<service>:<port> annotation arguments.
+// Host may be left empty to match all hosts, port may be either a number or a name of service port.
+func parseIngressPath(annotation string, args []string) (ingressPathRule, error) {
+	if err := requireArgs(annotation, args, 5); err != nil {
+		return ingressPathRule{}, err
+	}
+
+	host, path, service, port := args[0], args[1], args[3], args[4]
+	if host != "" {
+		if err := validateHost(annotation, host); err != nil {
+			return ingressPathRule{}, err
+		}
+	}
+	if !strings.HasPrefix(path, "/") {
+		return ingressPathRule{}, fmt.Errorf("%s%s: path must start with /", invalidAnnotation, annotation)
+	}
+
+	pathType := networking.PathType(args[2])
+	switch pathType {
+	case networking.PathTypeExact, networking.PathTypePrefix, networking.PathTypeImplementationSpecific:
+	default:
+		return ingressPathRule{}, fmt.Errorf("%s%s: unknown path type %s", invalidAnnotation, annotation, args[2])
+	}
+
+	backendPort := networking.ServiceBackendPort{}
+	if intstr.Parse(port).Type == intstr.Int {
+		number, err := parsePort(annotation, port, 1, 65535)
+		if err != nil {
+			return ingressPathRule{}, err
+		}
+		backendPort.Number = number
+	} else {
+		backendPort.Name = port
+	}
+
+	return ingressPathRule{
+		host: host,
+		path: networking.HTTPIngressPath{
+			Path:     path,
+			PathType: &pathType,
+			Backend: networking.IngressBackend{
+				Service: &networking.IngressServiceBackend{Name: service, Port: backendPort},
+			},
+		},
+	}, nil
+}
+
+// validateHost checks that host is a valid DNS subdomain. Wildcard hosts (e.g. *.example.com) are allowed.
+func validateHost(annotation, host string) error {
+	if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(host, "*.")); len(errs) > 0 {
+		return fmt.Errorf("%s%s: invalid host %s: %s", invalidAnnotation, annotation, host, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// executeSetServiceType changes type of the Service. Fields which are not allowed for the new type
+// (e.g. node ports of ClusterIP service) are cleared.
+func (r *ResourceModifierReconciler) executeSetServiceType(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, serviceType v2.ServiceType, externalName string) error {
+	service, err := serviceFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if service.Spec.Type == serviceType && service.Spec.ExternalName == externalName {
+		return nil
+	}
+	service.Spec.Type = serviceType
+	service.Spec.ExternalName = externalName
+
+	if serviceType != v2.ServiceTypeLoadBalancer {
+		service.Spec.AllocateLoadBalancerNodePorts = nil
+		service.Spec.LoadBalancerClass = nil
+		service.Spec.HealthCheckNodePort = 0
+	}
+	if serviceType == v2.ServiceTypeClusterIP || serviceType == v2.ServiceTypeExternalName {
+		service.Spec.ExternalTrafficPolicy = ""
+		for i := range service.Spec.Ports {
+			service.Spec.Ports[i].NodePort = 0
+		}
+	}
+	if serviceType == v2.ServiceTypeExternalName {
+		service.Spec.ClusterIP = ""
+		service.Spec.ClusterIPs = nil
+		service.Spec.IPFamilies = nil
+		service.Spec.IPFamilyPolicy = nil
+	}
+
+	return r.updateResource(ctx, resource, rm, successSetServiceType)
+}
+
+// executeAddServicePort adds port to the Service. Port with the same name is replaced.
+func (r *ResourceModifierReconciler) executeAddServicePort(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, port v2.ServicePort) error {
+	service, err := serviceFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if port.NodePort != 0 && service.Spec.Type != v2.ServiceTypeNodePort && service.Spec.Type != v2.ServiceTypeLoadBalancer {
+		return fmt.Errorf("node port can not be set on service of type %s", service.Spec.Type)
+	}
+
+	index := -1
+	for i, existing := range service.Spec.Ports {
+		if existing.Name == port.Name {
+			index = i
+			continue
+		}
+		if existing.Port == port.Port && existing.Protocol == port.Protocol {
+			return fmt.Errorf("port %d/%s is already exposed as %s", port.Port, port.Protocol, existing.Name)
+		}
+	}
+
+	if index >= 0 {
+		existing := service.Spec.Ports[index]
+		if port.NodePort == 0 {
+			// Keep the node port allocated by the API server
+			port.NodePort = existing.NodePort
+		}
+		if equality.Semantic.DeepEqual(existing, port) {
+			return nil
+		}
+		service.Spec.Ports[index] = port
+	} else {
+		service.Spec.Ports = append(service.Spec.Ports, port)
+	}
+
+	return r.updateResource(ctx, resource, rm, successAddServicePort)
+}
+
+// executeRemoveServicePort removes port with given name from the Service.
+func (r *ResourceModifierReconciler) executeRemoveServicePort(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, name string) error {
+	service, err := serviceFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	ports := make([]v2.ServicePort, 0, len(service.Spec.Ports))
+	for _, existing := range service.Spec.Ports {
+		if existing.Name != name {
+			ports = append(ports, existing)
+		}
+	}
+	if len(ports) == len(service.Spec.Ports) {
+		return nil
+	}
+	service.Spec.Ports = ports
+
+	return r.updateResource(ctx, resource, rm, successRemoveServicePort)
+}
+
+// executeSetServiceSelector sets a key of the Service's selector to value.
+func (r *ResourceModifierReconciler) executeSetServiceSelector(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, key, value string) error {
+	service, err := serviceFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if existing, exists := service.Spec.Selector[key]; exists && existing == value {
+		return nil
+	}
+	if service.Spec.Selector == nil {
+		service.Spec.Selector = make(map[string]string)
+	}
+	service.Spec.Selector[key] = value
+
+	return r.updateResource(ctx, resource, rm, successUpdateServiceSelector)
+}
+
+// executeRemoveServiceSelector removes a key from the Service's selector.
+func (r *ResourceModifierReconciler) executeRemoveServiceSelector(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, key string) error {
+	service, err := serviceFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := service.Spec.Selector[key]; !exists {
+		return nil
+	}
+	delete(service.Spec.Selector, key)
+
+	return r.updateResource(ctx, resource, rm, successUpdateServiceSelector)
+}
+
+// executeSetExternalTrafficPolicy sets external traffic policy of NodePort or LoadBalancer Service.
+func (r *ResourceModifierReconciler) executeSetExternalTrafficPolicy(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, policy v2.ServiceExternalTrafficPolicy) error {
+	service, err := serviceFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if service.Spec.Type != v2.ServiceTypeNodePort && service.Spec.Type != v2.ServiceTypeLoadBalancer {
+		return fmt.Errorf("external traffic policy can not be set on service of type %s", service.Spec.Type)
+	}
+	if service.Spec.ExternalTrafficPolicy == policy {
+		return nil
+	}
+	service.Spec.ExternalTrafficPolicy = policy
+
+	return r.updateResource(ctx, resource, rm, successSetExternalTrafficPolicy)
+}
+
+// executeSetIngressHost replaces host of Ingress rules. If oldHost is empty, every rule is updated, otherwise only
+// the rules with oldHost. Hosts of TLS entries are updated accordingly.
+func (r *ResourceModifierReconciler) executeSetIngressHost(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, host, oldHost string) error {
+	ingress, err := ingressFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	var changed bool
+	replaced := make(map[string]struct{})
+	for i, rule := range ingress.Spec.Rules {
+		if rule.Host == host || (oldHost != "" && rule.Host != oldHost) {
+			continue
+		}
+		replaced[rule.Host] = struct{}{}
+		ingress.Spec.Rules[i].Host = host
+		changed = true
+	}
+
+	for i := range ingress.Spec.TLS {
+		for j, tlsHost := range ingress.Spec.TLS[i].Hosts {
+			if _, ok := replaced[tlsHost]; ok {
+				ingress.Spec.TLS[i].Hosts[j] = host
+			}
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.updateResource(ctx, resource, rm, successSetIngressHost)
+}
+
+// executeSetIngressTLSSecret sets the secret used to terminate TLS for the host. If host is empty, the secret is set
+// for all hosts of the Ingress rules. A TLS entry is created, if the host is not covered by any.
+func (r *ResourceModifierReconciler) executeSetIngressTLSSecret(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, secretName, host string) error {
+	ingress, err := ingressFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	hosts := []string{host}
+	if host == "" {
+		hosts = hosts[:0]
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host != "" {
+				hosts = append(hosts, rule.Host)
+			}
+		}
+	}
+
+	var changed bool
+	var uncovered []string
+	for _, h := range hosts {
+		covered := false
+		for i := range ingress.Spec.TLS {
+			tls := &ingress.Spec.TLS[i]
+			if !slices.Contains(tls.Hosts, h) {
+				continue
+			}
+			covered = true
+			if tls.SecretName != secretName {
+				tls.SecretName = secretName
+				changed = true
+			}
+		}
+		if !covered && !slices.Contains(uncovered, h) {
+			uncovered = append(uncovered, h)
+		}
+	}
+
+	if len(uncovered) > 0 || (len(hosts) == 0 && len(ingress.Spec.TLS) == 0) {
+		ingress.Spec.TLS = append(ingress.Spec.TLS, networking.IngressTLS{Hosts: uncovered, SecretName: secretName})
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.updateResource(ctx, resource, rm, successSetIngressTLSSecret)
+}
+
+// executeAddIngressPath adds a path to the rule of the host, creating the rule if needed.
+// Existing path with the same path and type is replaced.
+func (r *ResourceModifierReconciler) executeAddIngressPath(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, rule ingressPathRule) error {
+	ingress, err := ingressFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	var ingressRule *networking.IngressRule
+	for i := range ingress.Spec.Rules {
+		if ingress.Spec.Rules[i].Host == rule.host {
+			ingressRule = &ingress.Spec.Rules[i]
+			break
+		}
+	}
+	if ingressRule == nil {
+		ingress.Spec.Rules = append(ingress.Spec.Rules, networking.IngressRule{Host: rule.host})
+		ingressRule = &ingress.Spec.Rules[len(ingress.Spec.Rules)-1]
+	}
+	if ingressRule.HTTP == nil {
+		ingressRule.HTTP = &networking.HTTPIngressRuleValue{}
+	}
+
+	paths := ingressRule.HTTP.Paths
+	index := -1
+	for i, existing := range paths {
+		if existing.Path == rule.path.Path && equality.Semantic.DeepEqual(existing.PathType, rule.path.PathType) {
+			index = i
+			break
+		}
+	}
+
+	if index >= 0 {
+		if equality.Semantic.DeepEqual(paths[index], rule.path) {
+			return nil
+		}
+		paths[index] = rule.path
+	} else {
+		ingressRule.HTTP.Paths = append(paths, rule.path)
+	}
+
+	return r.updateResource(ctx, resource, rm, successAddIngressPath)
+}
+
+// executeRemoveIngressPath removes a path from the rule of the host. Rules left without paths are removed.
+func (r *ResourceModifierReconciler) executeRemoveIngressPath(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, host, path string) error {
+	ingress, err := ingressFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	var changed bool
+	rules := make([]networking.IngressRule, 0, len(ingress.Spec.Rules))
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != host || rule.HTTP == nil {
+			rules = append(rules, rule)
+			continue
+		}
+
+		paths := make([]networking.HTTPIngressPath, 0, len(rule.HTTP.Paths))
+		for _, existing := range rule.HTTP.Paths {
+			if existing.Path != path {
+				paths = append(paths, existing)
+			}
+		}
+		if len(paths) == len(rule.HTTP.Paths) {
+			rules = append(rules, rule)
+			continue
+		}
+
+		changed = true
+		if len(paths) > 0 {
+			rule.HTTP.Paths = paths
+			rules = append(rules, rule)
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	ingress.Spec.Rules = rules
+
+	return r.updateResource(ctx, resource, rm, successRemoveIngressPath)
+}
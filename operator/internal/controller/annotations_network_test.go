@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestValidateAnnotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		wantErr    bool
+	}{
+		{name: "Service port with node port", annotation: "addServicePort:http:80:8080:TCP:30080"},
+		{name: "Node port out of range", annotation: "addServicePort:http:80:8080:TCP:80", wantErr: true},
+		{name: "Port out of range", annotation: "addServicePort:http:70000", wantErr: true},
+		{name: "Named target port", annotation: "addServicePort:http:80:web"},
+		{name: "Unknown protocol", annotation: "addServicePort:http:80:8080:HTTP", wantErr: true},
+		{name: "Service type", annotation: "setServiceType:NodePort"},
+		{name: "ExternalName requires external name", annotation: "setServiceType:ExternalName", wantErr: true},
+		{name: "Unknown service type", annotation: "setServiceType:Headless", wantErr: true},
+		{name: "External traffic policy", annotation: "setExternalTrafficPolicy:Local"},
+		{name: "Wildcard ingress host", annotation: "setIngressHost:*.example.com"},
+		{name: "Invalid ingress host", annotation: "setIngressHost:Example_Com", wantErr: true},
+		{name: "Ingress path", annotation: "addIngressPath:example.com:/api:Prefix:backend:http"},
+		{name: "Ingress path must be absolute", annotation: "addIngressPath:example.com:api:Prefix:backend:80", wantErr: true},
+		{name: "Unknown action", annotation: "sleep:50", wantErr: true},
+		{name: "Missing arguments", annotation: "addLabel:key", wantErr: true},
+		{name: "Label", annotation: "executeAddLabel:env:prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAnnotation(tt.annotation)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestResourceModifierReconciler_serviceActions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	service := &v2.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "test-ns",
+		},
+		Spec: v2.ServiceSpec{
+			Type:                  v2.ServiceTypeNodePort,
+			ExternalTrafficPolicy: v2.ServiceExternalTrafficPolicyCluster,
+			Selector:              map[string]string{"app": "test"},
+			Ports: []v2.ServicePort{
+				{Name: "http", Port: 80, Protocol: v2.ProtocolTCP, NodePort: 30080},
+			},
+		},
+	}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rm-test",
+		},
+	}
+	rm.Status.Conditions = make(map[string]string)
+
+	tests := []struct {
+		name       string
+		annotation string
+		check      func(t *testing.T, got *v2.Service)
+		wantErr    bool
+	}{
+		{
+			name:       "Add port",
+			annotation: "addServicePort:metrics:9090:metrics",
+			check: func(t *testing.T, got *v2.Service) {
+				assert.Len(t, got.Spec.Ports, 2)
+				assert.Equal(t, intstr.FromString("metrics"), got.Spec.Ports[1].TargetPort)
+			},
+		},
+		{
+			name:       "Port number is already exposed",
+			annotation: "addServicePort:web:80",
+			wantErr:    true,
+		},
+		{
+			name:       "Remove port",
+			annotation: "removeServicePort:http",
+			check: func(t *testing.T, got *v2.Service) {
+				assert.Empty(t, got.Spec.Ports)
+			},
+		},
+		{
+			name:       "Change type to ClusterIP clears node ports",
+			annotation: "setServiceType:ClusterIP",
+			check: func(t *testing.T, got *v2.Service) {
+				assert.Equal(t, v2.ServiceTypeClusterIP, got.Spec.Type)
+				assert.Equal(t, int32(0), got.Spec.Ports[0].NodePort)
+				assert.Empty(t, got.Spec.ExternalTrafficPolicy)
+			},
+		},
+		{
+			name:       "Set selector",
+			annotation: "setServiceSelector:version:v2",
+			check: func(t *testing.T, got *v2.Service) {
+				assert.Equal(t, map[string]string{"app": "test", "version": "v2"}, got.Spec.Selector)
+			},
+		},
+		{
+			name:       "Remove selector",
+			annotation: "removeServiceSelector:app",
+			check: func(t *testing.T, got *v2.Service) {
+				assert.Empty(t, got.Spec.Selector)
+			},
+		},
+		{
+			name:       "Set external traffic policy",
+			annotation: "setExternalTrafficPolicy:Local",
+			check: func(t *testing.T, got *v2.Service) {
+				assert.Equal(t, v2.ServiceExternalTrafficPolicyLocal, got.Spec.ExternalTrafficPolicy)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := service.DeepCopy()
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(target, rm).WithStatusSubresource(rm).Build(),
+				Scheme: scheme,
+			}
+
+			err := r.executeAnnotation(context.Background(), tt.annotation, target, rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			got := &v2.Service{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(target), got))
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_ingressActions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, networking.AddToScheme(scheme))
+
+	pathType := networking.PathTypePrefix
+	ingress := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-ingress",
+			Namespace: "test-ns",
+		},
+		Spec: networking.IngressSpec{
+			TLS: []networking.IngressTLS{{Hosts: []string{"old.example.com"}, SecretName: "old-cert"}},
+			Rules: []networking.IngressRule{{
+				Host: "old.example.com",
+				IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{
+					Paths: []networking.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+							Name: "frontend", Port: networking.ServiceBackendPort{Number: 80}}},
+					}},
+				}},
+			}},
+		},
+	}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rm-test",
+		},
+	}
+	rm.Status.Conditions = make(map[string]string)
+
+	tests := []struct {
+		name       string
+		annotation string
+		check      func(t *testing.T, got *networking.Ingress)
+	}{
+		{
+			name:       "Set host updates rules and TLS",
+			annotation: "setIngressHost:new.example.com:old.example.com",
+			check: func(t *testing.T, got *networking.Ingress) {
+				assert.Equal(t, "new.example.com", got.Spec.Rules[0].Host)
+				assert.Equal(t, []string{"new.example.com"}, got.Spec.TLS[0].Hosts)
+			},
+		},
+		{
+			name:       "Set TLS secret of existing host",
+			annotation: "setIngressTLSSecret:new-cert:old.example.com",
+			check: func(t *testing.T, got *networking.Ingress) {
+				assert.Len(t, got.Spec.TLS, 1)
+				assert.Equal(t, "new-cert", got.Spec.TLS[0].SecretName)
+			},
+		},
+		{
+			name:       "Set TLS secret of uncovered host",
+			annotation: "setIngressTLSSecret:api-cert:api.example.com",
+			check: func(t *testing.T, got *networking.Ingress) {
+				assert.Len(t, got.Spec.TLS, 2)
+				assert.Equal(t, networking.IngressTLS{Hosts: []string{"api.example.com"}, SecretName: "api-cert"}, got.Spec.TLS[1])
+			},
+		},
+		{
+			name:       "Add path to existing rule",
+			annotation: "addIngressPath:old.example.com:/api:Prefix:backend:8080",
+			check: func(t *testing.T, got *networking.Ingress) {
+				assert.Len(t, got.Spec.Rules, 1)
+				assert.Len(t, got.Spec.Rules[0].HTTP.Paths, 2)
+				assert.Equal(t, "backend", got.Spec.Rules[0].HTTP.Paths[1].Backend.Service.Name)
+			},
+		},
+		{
+			name:       "Add path to new rule",
+			annotation: "addIngressPath:api.example.com:/:Prefix:backend:http",
+			check: func(t *testing.T, got *networking.Ingress) {
+				assert.Len(t, got.Spec.Rules, 2)
+				assert.Equal(t, "http", got.Spec.Rules[1].HTTP.Paths[0].Backend.Service.Port.Name)
+			},
+		},
+		{
+			name:       "Remove the last path removes the rule",
+			annotation: "removeIngressPath:old.example.com:/",
+			check: func(t *testing.T, got *networking.Ingress) {
+				assert.Empty(t, got.Spec.Rules)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := ingress.DeepCopy()
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(target, rm).WithStatusSubresource(rm).Build(),
+				Scheme: scheme,
+			}
+
+			assert.Nil(t, r.executeAnnotation(context.Background(), tt.annotation, target, rm))
+
+			got := &networking.Ingress{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(target), got))
+			tt.check(t, got)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// successAddOwnerReference
+	successAddOwnerReference = "Successfully added owner reference"
+
+	// successRemoveOwnerReference
+	successRemoveOwnerReference = "Successfully removed owner reference"
+
+	// ownerReferenceControllerFlag marks the added owner reference as the managing controller of the resource
+	ownerReferenceControllerFlag = "controller"
+
+	// ownerReferenceBlockOwnerDeletionFlag prevents deletion of the owner until the resource is deleted
+	ownerReferenceBlockOwnerDeletionFlag = "blockOwnerDeletion"
+)
+
+// ownerReferenceRequest is a parsed representation of addOwnerReference annotation.
+type ownerReferenceRequest struct {
+	kind               string
+	name               string
+	controller         bool
+	blockOwnerDeletion bool
+}
+
+// parseOwnerReference constructs ownerReferenceRequest from
+// addOwnerReference:<kind>:<name>[:controller][:blockOwnerDeletion] annotation arguments.
+func parseOwnerReference(annotation string, args []string) (ownerReferenceRequest, error) {
+	if err := requireArgs(annotation, args, 2); err != nil {
+		return ownerReferenceRequest{}, err
+	}
+
+	request := ownerReferenceRequest{kind: args[0], name: args[1]}
+	for _, flag := range args[2:] {
+		switch flag {
+		case ownerReferenceControllerFlag:
+			request.controller = true
+		case ownerReferenceBlockOwnerDeletionFlag:
+			request.blockOwnerDeletion = true
+		default:
+			return ownerReferenceRequest{}, fmt.Errorf("%s%s: unknown flag %s", invalidAnnotation, annotation, flag)
+		}
+	}
+
+	return request, nil
+}
+
+// resolveOwnerMapping finds REST mapping of the owner kind. Kind can either be one of resource types supported in
+// TargetResourceData (e.g. deployment), or Kind.group of any resource known to the API server (e.g. MyApp.example.com).
+func (r *ResourceModifierReconciler) resolveOwnerMapping(kind string) (*meta.RESTMapping, error) {
+	if obj, err := r.determineResourceType(annotresourcemodifv1.TargetResourceData{ResourceType: kind}); err == nil {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		return r.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+
+	return r.Client.RESTMapper().RESTMapping(schema.ParseGroupKind(kind))
+}
+
+// owners of the supported resource types, which are not modified otherwise, are only read
+// +kubebuilder:rbac:groups="",resources=nodes;persistentvolumes;persistentvolumeclaims,verbs=get
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get
+
+// apiReader returns the reader, which bypasses the cache.
+func (r *ResourceModifierReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// executeAddOwnerReference looks up the owner by kind and name, and adds an owner reference pointing to it to the
+// resource. Namespaced owners are searched in the namespace of the resource, since owner references can not point to
+// other namespaces. Existing reference to the same owner is replaced, so the flags can be changed.
+// The owner is read without the cache, so no informer is started for its kind, and only get permission is required.
+func (r *ResourceModifierReconciler) executeAddOwnerReference(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, request ownerReferenceRequest) error {
+	mapping, err := r.resolveOwnerMapping(request.kind)
+	if err != nil {
+		return err
+	}
+
+	owner := &metav1.PartialObjectMetadata{}
+	owner.SetGroupVersionKind(mapping.GroupVersionKind)
+	key := client.ObjectKey{Name: request.name}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if resource.GetNamespace() == "" {
+			return fmt.Errorf("cluster-scoped resource can not be owned by namespaced %s", mapping.GroupVersionKind.Kind)
+		}
+		key.Namespace = resource.GetNamespace()
+	}
+
+	getCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	if err = r.apiReader().Get(getCtx, key, owner); err != nil {
+		return err
+	}
+
+	reference := metav1.OwnerReference{
+		APIVersion: mapping.GroupVersionKind.GroupVersion().String(),
+		Kind:       mapping.GroupVersionKind.Kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}
+	if request.controller {
+		reference.Controller = ptr.To(true)
+	}
+	if request.blockOwnerDeletion {
+		reference.BlockOwnerDeletion = ptr.To(true)
+	}
+
+	references := resource.GetOwnerReferences()
+	index := -1
+	for i, existing := range references {
+		if existing.UID == reference.UID {
+			index = i
+			continue
+		}
+		if request.controller && ptr.Deref(existing.Controller, false) {
+			return fmt.Errorf("resource is already controlled by %s %s", existing.Kind, existing.Name)
+		}
+	}
+
+	if index >= 0 {
+		if equalOwnerReferences(references[index], reference) {
+			return nil
+		}
+		references[index] = reference
+	} else {
+		references = append(references, reference)
+	}
+	resource.SetOwnerReferences(references)
+
+	return r.updateResource(ctx, resource, rm, successAddOwnerReference)
+}
+
+// executeRemoveOwnerReference removes owner references pointing to the owner of given kind and name, orphaning
+// the resource. The owner itself does not have to exist anymore.
+func (r *ResourceModifierReconciler) executeRemoveOwnerReference(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, kind, name string) error {
+	mapping, err := r.resolveOwnerMapping(kind)
+	if err != nil {
+		return err
+	}
+
+	references := resource.GetOwnerReferences()
+	remaining := make([]metav1.OwnerReference, 0, len(references))
+	for _, existing := range references {
+		gv, err := schema.ParseGroupVersion(existing.APIVersion)
+		if err == nil && gv.Group == mapping.GroupVersionKind.Group &&
+			existing.Kind == mapping.GroupVersionKind.Kind && existing.Name == name {
+			continue
+		}
+		remaining = append(remaining, existing)
+	}
+
+	if len(remaining) == len(references) {
+		return nil
+	}
+	resource.SetOwnerReferences(remaining)
+
+	return r.updateResource(ctx, resource, rm, successRemoveOwnerReference)
+}
+
+// equalOwnerReferences compares owner references, treating unset flags as false.
+func equalOwnerReferences(a, b metav1.OwnerReference) bool {
+	return a.APIVersion == b.APIVersion && a.Kind == b.Kind && a.Name == b.Name && a.UID == b.UID &&
+		ptr.Deref(a.Controller, false) == ptr.Deref(b.Controller, false) &&
+		ptr.Deref(a.BlockOwnerDeletion, false) == ptr.Deref(b.BlockOwnerDeletion, false)
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestParseOwnerReference(t *testing.T) {
+	_, args := splitAnnotation("addOwnerReference:deployment:backend:controller:blockOwnerDeletion")
+	request, err := parseOwnerReference("addOwnerReference", args)
+	assert.Nil(t, err)
+	assert.Equal(t, ownerReferenceRequest{kind: "deployment", name: "backend", controller: true, blockOwnerDeletion: true}, request)
+
+	_, args = splitAnnotation("addOwnerReference:deployment:backend:orphan")
+	_, err = parseOwnerReference("addOwnerReference", args)
+	assert.NotNil(t, err)
+
+	_, args = splitAnnotation("addOwnerReference:deployment")
+	_, err = parseOwnerReference("addOwnerReference", args)
+	assert.NotNil(t, err)
+}
+
+func TestResourceModifierReconciler_executeAddOwnerReference(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, appsv1.AddToScheme(scheme))
+
+	restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{appsv1.SchemeGroupVersion})
+	restMapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+
+	owner := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "owner",
+			Namespace: "test-ns",
+			UID:       "owner-uid",
+		},
+	}
+	otherController := metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       "other",
+		UID:        "other-uid",
+		Controller: ptr.To(true),
+	}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rm-test",
+		},
+	}
+	rm.Status.Conditions = make(map[string]string)
+
+	tests := []struct {
+		name     string
+		resource *v2.Pod
+		request  ownerReferenceRequest
+		want     []metav1.OwnerReference
+		wantErr  bool
+	}{
+		{
+			name:     "Successful adoption of orphaned pod",
+			resource: &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "test-ns"}},
+			request:  ownerReferenceRequest{kind: "deployment", name: "owner", controller: true},
+			want: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "owner",
+				UID:        "owner-uid",
+				Controller: ptr.To(true),
+			}},
+		},
+		{
+			name: "Existing reference is updated with new flags",
+			resource: &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "test-ns",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "owner", UID: "owner-uid"}}}},
+			request: ownerReferenceRequest{kind: "Deployment.apps", name: "owner", blockOwnerDeletion: true},
+			want: []metav1.OwnerReference{{
+				APIVersion:         "apps/v1",
+				Kind:               "Deployment",
+				Name:               "owner",
+				UID:                "owner-uid",
+				BlockOwnerDeletion: ptr.To(true),
+			}},
+		},
+		{
+			name: "Resource already has a controller",
+			resource: &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "test-ns",
+				OwnerReferences: []metav1.OwnerReference{otherController}}},
+			request: ownerReferenceRequest{kind: "deployment", name: "owner", controller: true},
+			wantErr: true,
+		},
+		{
+			name:     "Owner does not exist",
+			resource: &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "test-ns"}},
+			request:  ownerReferenceRequest{kind: "deployment", name: "missing"},
+			wantErr:  true,
+		},
+		{
+			name:     "Unknown owner kind",
+			resource: &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "test-ns"}},
+			request:  ownerReferenceRequest{kind: "MyApp.example.com", name: "owner"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the owner is read directly from the API server, not from the cache of the client
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(restMapper).
+					WithObjects(tt.resource, rm).WithStatusSubresource(rm).Build(),
+				APIReader: fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(restMapper).
+					WithObjects(owner).Build(),
+				Scheme: scheme,
+			}
+
+			err := r.executeAddOwnerReference(context.Background(), tt.resource, rm, tt.request)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			got := &v2.Pod{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.resource), got))
+			assert.Equal(t, tt.want, got.OwnerReferences)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_executeRemoveOwnerReference(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, appsv1.AddToScheme(scheme))
+
+	restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{appsv1.SchemeGroupVersion})
+	restMapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+
+	kept := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "kept", UID: "kept-uid"}
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "test-ns",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "removed", UID: "removed-uid"},
+				kept,
+			},
+		},
+	}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rm-test",
+		},
+	}
+	rm.Status.Conditions = make(map[string]string)
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(restMapper).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme: scheme,
+	}
+
+	assert.Nil(t, r.executeRemoveOwnerReference(context.Background(), pod, rm, "deployment", "removed"))
+
+	got := &v2.Pod{}
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), got))
+	assert.Equal(t, []metav1.OwnerReference{kept}, got.OwnerReferences)
+
+	// Removing a reference which is not present is a no-op
+	assert.Nil(t, r.executeRemoveOwnerReference(context.Background(), got, rm, "deployment", "removed"))
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// successApplyPatches
+	successApplyPatches = "Successfully applied patches"
+
+	// unknownPatchType is an error message indicating that patch type is not supported
+	unknownPatchType = "Unknown patch type: "
+)
+
+// patchTypeOf converts type of the patch from ResourceModifier's spec into a type understood by the API server.
+func patchTypeOf(patchType annotresourcemodifv1.PatchType) (types.PatchType, error) {
+	switch patchType {
+	case annotresourcemodifv1.JSONPatchType:
+		return types.JSONPatchType, nil
+	case annotresourcemodifv1.MergePatchType:
+		return types.MergePatchType, nil
+	case annotresourcemodifv1.StrategicMergePatchType:
+		return types.StrategicMergePatchType, nil
+	}
+
+	return "", fmt.Errorf("%s%s", unknownPatchType, patchType)
+}
+
+// executePatches applies patches from ResourceModifier's spec to the resource one by one. "test" operations of
+// a JSON Patch are evaluated against the state of the resource composed by the preceding actions.
+// Applied patches and resulting resourceVersion of the resource are recorded in the status. Within a batch,
+// the patches are applied in memory, so applyBatch records the resourceVersion once the resource is written.
+func (r *ResourceModifierReconciler) executePatches(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) error {
+	if len(rm.Spec.Patches) == 0 {
+		return nil
+	}
+
+	applied := make([]annotresourcemodifv1.AppliedPatch, 0, len(rm.Spec.Patches))
+	for i, patch := range rm.Spec.Patches {
+		patchType, err := patchTypeOf(patch.Type)
+		if err != nil {
+			return err
+		}
+
+		patchCtx, cancel := r.operationContext(ctx)
+		err = r.Client.Patch(patchCtx, resource, client.RawPatch(patchType, patch.Patch.Raw))
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to apply patch #%d: %w", i, err)
+		}
+
+		applied = append(applied, annotresourcemodifv1.AppliedPatch{
+			Type:            patch.Type,
+			Patch:           string(patch.Patch.Raw),
+			ResourceVersion: resource.GetResourceVersion(),
+			AppliedAt:       metav1.Now(),
+		})
+	}
+	rm.Status.AppliedPatches = applied
+
+	err := r.updateStatusSuccess(ctx, rm, successApplyPatches)
+	if err != nil {
+		updateErr := r.updateErrorStatus(ctx, rm, err.Error())
+		if updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	return nil
+}
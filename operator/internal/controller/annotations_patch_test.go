@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestResourceModifierReconciler_executePatches(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, appsv1.AddToScheme(scheme))
+
+	replicas := int32(3)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "test-ns",
+			Labels:    map[string]string{"app": "test"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v2.PodTemplateSpec{
+				Spec: v2.PodSpec{
+					Containers: []v2.Container{{Name: "app", Image: "app:1"}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		patches   []v1.Patch
+		wantImage string
+		wantLabel string
+		wantErr   bool
+	}{
+		{
+			name: "JSON patch with satisfied test operation",
+			patches: []v1.Patch{{
+				Type: v1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[
+					{"op": "test", "path": "/spec/replicas", "value": 3},
+					{"op": "replace", "path": "/metadata/labels/app", "value": "patched"}]`)},
+			}},
+			wantImage: "app:1",
+			wantLabel: "patched",
+		},
+		{
+			name: "JSON patch with failed test operation is not applied",
+			patches: []v1.Patch{{
+				Type: v1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[
+					{"op": "test", "path": "/spec/replicas", "value": 5},
+					{"op": "replace", "path": "/metadata/labels/app", "value": "patched"}]`)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Merge patch",
+			patches: []v1.Patch{{
+				Type:  v1.MergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{"metadata": {"labels": {"app": "merged"}}}`)},
+			}},
+			wantImage: "app:1",
+			wantLabel: "merged",
+		},
+		{
+			name: "Strategic merge patch merges containers by name",
+			patches: []v1.Patch{{
+				Type: v1.StrategicMergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(
+					`{"spec": {"template": {"spec": {"containers": [{"name": "app", "image": "app:2"}]}}}}`)},
+			}},
+			wantImage: "app:2",
+			wantLabel: "test",
+		},
+		{
+			name: "Unknown patch type",
+			patches: []v1.Patch{{
+				Type:  "xml",
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{}`)},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rm-test",
+				},
+				Spec: v1.ResourceModifierSpec{
+					Patches: tt.patches,
+				},
+			}
+			rm.Status.Conditions = make(map[string]string)
+
+			target := deployment.DeepCopy()
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(target, rm).WithStatusSubresource(rm).Build(),
+				Scheme: scheme,
+			}
+
+			err := r.executePatches(context.Background(), target, rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			got := &appsv1.Deployment{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(target), got))
+			assert.Equal(t, tt.wantLabel, got.Labels["app"])
+			assert.Equal(t, tt.wantImage, got.Spec.Template.Spec.Containers[0].Image)
+
+			gotRM := &v1.ResourceModifier{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(rm), gotRM))
+			assert.Len(t, gotRM.Status.AppliedPatches, len(tt.patches))
+			assert.Equal(t, got.ResourceVersion, gotRM.Status.AppliedPatches[0].ResourceVersion)
+		})
+	}
+}
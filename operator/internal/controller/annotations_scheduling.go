@@ -0,0 +1,592 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/apps/v1"
+	v3 "k8s.io/api/batch/v1"
+	v2 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+const (
+	// successAddToleration
+	successAddToleration = "Successfully added toleration"
+
+	// successRemoveToleration
+	successRemoveToleration = "Successfully removed toleration"
+
+	// successAddAffinity
+	successAddAffinity = "Successfully added affinity"
+
+	// successRemoveAffinity
+	successRemoveAffinity = "Successfully removed affinity"
+
+	// noPodSpec is an error message indicating that resource does not have a pod specification or pod template
+	noPodSpec = "Resource does not have a pod specification: "
+
+	// immutablePodSpec is an error message indicating that the action is not allowed on an existing Pod
+	immutablePodSpec = "Pod does not allow changing affinity or removing tolerations, modify its workload instead"
+
+	// nodeAffinity is a type of affinity, which constraints nodes on which pod can be scheduled
+	nodeAffinity = "nodeAffinity"
+
+	// podAffinity is a type of affinity, which co-locates pod with other pods
+	podAffinity = "podAffinity"
+
+	// podAntiAffinity is a type of affinity, which keeps pod away from other pods
+	podAntiAffinity = "podAntiAffinity"
+
+	// defaultTopologyKey is used as topology key of pod (anti-)affinity terms, unless another one is provided
+	defaultTopologyKey = "kubernetes.io/hostname"
+)
+
+// templateOnlyActions lists actions, which Kubernetes does not allow on existing Pods: affinity of a Pod is
+// immutable, and its tolerations can only be added. They are supported on resources with a pod template.
+var templateOnlyActions = map[string]struct{}{
+	"addAffinity":      {},
+	"removeAffinity":   {},
+	"removeToleration": {},
+}
+
+// affinityRule is a parsed representation of addAffinity annotation.
+// Weight equal to 0 means that the rule is required during scheduling, otherwise it is preferred with given weight.
+type affinityRule struct {
+	affinityType string
+	key          string
+	operator     string
+	values       []string
+	weight       int32
+	topologyKey  string
+}
+
+// podSpecFromResource returns pod specification of the resource. For workload resources (e.g. Deployment)
+// the specification of the pod template is returned, so that all pods created afterward are affected.
+func podSpecFromResource(resource client.Object) (*v2.PodSpec, error) {
+	switch res := resource.(type) {
+	case *v2.Pod:
+		return &res.Spec, nil
+	case *v1.Deployment:
+		return &res.Spec.Template.Spec, nil
+	case *v1.StatefulSet:
+		return &res.Spec.Template.Spec, nil
+	case *v1.DaemonSet:
+		return &res.Spec.Template.Spec, nil
+	case *v1.ReplicaSet:
+		return &res.Spec.Template.Spec, nil
+	case *v3.Job:
+		return &res.Spec.Template.Spec, nil
+	case *v3.CronJob:
+		return &res.Spec.JobTemplate.Spec.Template.Spec, nil
+	}
+
+	return nil, fmt.Errorf("%s%T", noPodSpec, resource)
+}
+
+// podTemplateSpecFromResource returns pod specification of the resource, like podSpecFromResource, but fails for
+// Pods, whose specification does not allow the change.
+func podTemplateSpecFromResource(resource client.Object) (*v2.PodSpec, error) {
+	if _, ok := resource.(*v2.Pod); ok {
+		return nil, errors.New(immutablePodSpec)
+	}
+
+	return podSpecFromResource(resource)
+}
+
+// parseToleration constructs toleration from toleration:<key>:<value>:<effect> annotation arguments.
+// If value is empty, toleration will tolerate any value of the taint.
+// If effect is empty, toleration will tolerate all taint effects.
+func parseToleration(annotation string, args []string) (v2.Toleration, error) {
+	if err := requireArgs(annotation, args, 1); err != nil {
+		return v2.Toleration{}, err
+	}
+
+	toleration := v2.Toleration{
+		Key:      args[0],
+		Operator: v2.TolerationOpExists,
+	}
+	if len(args) > 1 && args[1] != "" {
+		toleration.Operator = v2.TolerationOpEqual
+		toleration.Value = args[1]
+	}
+	if len(args) > 2 && args[2] != "" {
+		effect := v2.TaintEffect(args[2])
+		switch effect {
+		case v2.TaintEffectNoSchedule, v2.TaintEffectPreferNoSchedule, v2.TaintEffectNoExecute:
+			toleration.Effect = effect
+		default:
+			return v2.Toleration{}, fmt.Errorf("%s%s: unknown taint effect %s", invalidAnnotation, annotation, args[2])
+		}
+	}
+
+	return toleration, nil
+}
+
+// parseAffinity constructs affinityRule from addAffinity:<type>:<key>:<operator>:<value>[:<weight>[:<topologyKey>]]
+// annotation arguments. Multiple values may be separated by comma. If weight is provided, the rule is preferred,
+// otherwise it is required. Topology key is only used by pod (anti-)affinity, kubernetes.io/hostname by default.
+func parseAffinity(annotation string, args []string) (affinityRule, error) {
+	if err := requireArgs(annotation, args, 3); err != nil {
+		return affinityRule{}, err
+	}
+
+	rule := affinityRule{
+		affinityType: args[0],
+		key:          args[1],
+		operator:     args[2],
+	}
+
+	switch rule.affinityType {
+	case nodeAffinity:
+		switch v2.NodeSelectorOperator(rule.operator) {
+		case v2.NodeSelectorOpIn, v2.NodeSelectorOpNotIn, v2.NodeSelectorOpExists,
+			v2.NodeSelectorOpDoesNotExist, v2.NodeSelectorOpGt, v2.NodeSelectorOpLt:
+		default:
+			return affinityRule{}, fmt.Errorf("%s%s: unknown operator %s", invalidAnnotation, annotation, rule.operator)
+		}
+	case podAffinity, podAntiAffinity:
+		switch metav1.LabelSelectorOperator(rule.operator) {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn,
+			metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+		default:
+			return affinityRule{}, fmt.Errorf("%s%s: unknown operator %s", invalidAnnotation, annotation, rule.operator)
+		}
+	default:
+		return affinityRule{}, fmt.Errorf("%s%s: unknown affinity type %s", invalidAnnotation, annotation, rule.affinityType)
+	}
+
+	if len(args) > 3 && args[3] != "" {
+		rule.values = strings.Split(args[3], ",")
+	}
+	if err := validateAffinityValues(annotation, rule); err != nil {
+		return affinityRule{}, err
+	}
+
+	if len(args) > 4 && args[4] != "" {
+		weight, err := strconv.ParseInt(args[4], 10, 32)
+		if err != nil || weight < 1 || weight > 100 {
+			return affinityRule{}, fmt.Errorf("%s%s: weight must be a number in range 1-100", invalidAnnotation, annotation)
+		}
+		rule.weight = int32(weight)
+	}
+
+	if len(args) > 5 && args[5] != "" {
+		if rule.affinityType == nodeAffinity {
+			return affinityRule{}, fmt.Errorf("%s%s: topology key is only used by pod affinity", invalidAnnotation,
+				annotation)
+		}
+		if errs := validation.IsQualifiedName(args[5]); len(errs) > 0 {
+			return affinityRule{}, fmt.Errorf("%s%s: invalid topology key %s: %s", invalidAnnotation, annotation,
+				args[5], strings.Join(errs, ", "))
+		}
+		rule.topologyKey = args[5]
+	} else if rule.affinityType != nodeAffinity {
+		rule.topologyKey = defaultTopologyKey
+	}
+
+	return rule, nil
+}
+
+// validateAffinityValues checks that values of the rule fit its operator: Exists and DoesNotExist take no values,
+// In and NotIn take at least one, Gt and Lt take a single integer.
+func validateAffinityValues(annotation string, rule affinityRule) error {
+	switch rule.operator {
+	case string(v2.NodeSelectorOpExists), string(v2.NodeSelectorOpDoesNotExist):
+		if len(rule.values) > 0 {
+			return fmt.Errorf("%s%s: operator %s takes no values", invalidAnnotation, annotation, rule.operator)
+		}
+	case string(v2.NodeSelectorOpIn), string(v2.NodeSelectorOpNotIn):
+		if len(rule.values) == 0 {
+			return fmt.Errorf("%s%s: operator %s requires values", invalidAnnotation, annotation, rule.operator)
+		}
+	case string(v2.NodeSelectorOpGt), string(v2.NodeSelectorOpLt):
+		if len(rule.values) != 1 {
+			return fmt.Errorf("%s%s: operator %s requires a single value", invalidAnnotation, annotation, rule.operator)
+		}
+		if _, err := strconv.ParseInt(rule.values[0], 10, 64); err != nil {
+			return fmt.Errorf("%s%s: operator %s requires an integer value", invalidAnnotation, annotation,
+				rule.operator)
+		}
+	}
+
+	return nil
+}
+
+// executeAddToleration adds toleration to the pod specification of the resource, unless an equivalent toleration
+// is already present.
+func (r *ResourceModifierReconciler) executeAddToleration(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, toleration v2.Toleration) error {
+	spec, err := podSpecFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	for i := range spec.Tolerations {
+		if spec.Tolerations[i].MatchToleration(&toleration) {
+			return nil
+		}
+	}
+	spec.Tolerations = append(spec.Tolerations, toleration)
+
+	return r.updateResource(ctx, resource, rm, successAddToleration)
+}
+
+// executeRemoveToleration removes all tolerations matching the provided one from pod template of the resource.
+// Pods are rejected, since their tolerations can not be removed.
+func (r *ResourceModifierReconciler) executeRemoveToleration(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, toleration v2.Toleration) error {
+	spec, err := podTemplateSpecFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	tolerations := make([]v2.Toleration, 0, len(spec.Tolerations))
+	for _, existing := range spec.Tolerations {
+		if !tolerationMatches(existing, toleration) {
+			tolerations = append(tolerations, existing)
+		}
+	}
+	if len(tolerations) == len(spec.Tolerations) {
+		return nil
+	}
+	spec.Tolerations = tolerations
+
+	return r.updateResource(ctx, resource, rm, successRemoveToleration)
+}
+
+// executeAddAffinity adds affinity rule to the pod template of the resource.
+// Equivalent terms are not duplicated. Pods are rejected, since their affinity is immutable.
+func (r *ResourceModifierReconciler) executeAddAffinity(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, rule affinityRule) error {
+	spec, err := podTemplateSpecFromResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if spec.Affinity == nil {
+		spec.Affinity = &v2.Affinity{}
+	}
+
+	var changed bool
+	switch rule.affinityType {
+	case nodeAffinity:
+		if spec.Affinity.NodeAffinity == nil {
+			spec.Affinity.NodeAffinity = &v2.NodeAffinity{}
+		}
+		changed = addNodeAffinity(spec.Affinity.NodeAffinity, rule)
+	case podAffinity:
+		if spec.Affinity.PodAffinity == nil {
+			spec.Affinity.PodAffinity = &v2.PodAffinity{}
+		}
+		pa := spec.Affinity.PodAffinity
+		changed = addPodAffinityTerm(&pa.RequiredDuringSchedulingIgnoredDuringExecution,
+			&pa.PreferredDuringSchedulingIgnoredDuringExecution, rule)
+	case podAntiAffinity:
+		if spec.Affinity.PodAntiAffinity == nil {
+			spec.Affinity.PodAntiAffinity = &v2.PodAntiAffinity{}
+		}
+		pa := spec.Affinity.PodAntiAffinity
+		changed = addPodAffinityTerm(&pa.RequiredDuringSchedulingIgnoredDuringExecution,
+			&pa.PreferredDuringSchedulingIgnoredDuringExecution, rule)
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.updateResource(ctx, resource, rm, successAddAffinity)
+}
+
+// executeRemoveAffinity removes every requirement on the given key from the affinity of specified type.
+// Terms left without any requirements are removed as well. Pods are rejected, since their affinity is immutable.
+func (r *ResourceModifierReconciler) executeRemoveAffinity(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier, affinityType, key string) error {
+	switch affinityType {
+	case nodeAffinity, podAffinity, podAntiAffinity:
+	default:
+		return fmt.Errorf("%sremoveAffinity: unknown affinity type %s", invalidAnnotation, affinityType)
+	}
+
+	spec, err := podTemplateSpecFromResource(resource)
+	if err != nil {
+		return err
+	}
+	if spec.Affinity == nil {
+		return nil
+	}
+
+	var changed bool
+	switch affinityType {
+	case nodeAffinity:
+		changed = removeNodeAffinity(spec.Affinity, key)
+	case podAffinity:
+		if pa := spec.Affinity.PodAffinity; pa != nil {
+			changed = removePodAffinityTerms(&pa.RequiredDuringSchedulingIgnoredDuringExecution,
+				&pa.PreferredDuringSchedulingIgnoredDuringExecution, key)
+			if len(pa.RequiredDuringSchedulingIgnoredDuringExecution) == 0 &&
+				len(pa.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+				spec.Affinity.PodAffinity = nil
+			}
+		}
+	case podAntiAffinity:
+		if pa := spec.Affinity.PodAntiAffinity; pa != nil {
+			changed = removePodAffinityTerms(&pa.RequiredDuringSchedulingIgnoredDuringExecution,
+				&pa.PreferredDuringSchedulingIgnoredDuringExecution, key)
+			if len(pa.RequiredDuringSchedulingIgnoredDuringExecution) == 0 &&
+				len(pa.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+				spec.Affinity.PodAntiAffinity = nil
+			}
+		}
+	}
+
+	if *spec.Affinity == (v2.Affinity{}) {
+		spec.Affinity = nil
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.updateResource(ctx, resource, rm, successRemoveAffinity)
+}
+
+// addNodeAffinity adds requirement from the rule to node affinity. Required node selector terms are ORed by the
+// scheduler, so the requirement is added to every existing term to keep it mandatory.
+// Returns true, if node affinity was modified.
+func addNodeAffinity(affinity *v2.NodeAffinity, rule affinityRule) bool {
+	requirement := v2.NodeSelectorRequirement{
+		Key:      rule.key,
+		Operator: v2.NodeSelectorOperator(rule.operator),
+		Values:   rule.values,
+	}
+
+	if rule.weight > 0 {
+		term := v2.PreferredSchedulingTerm{
+			Weight:     rule.weight,
+			Preference: v2.NodeSelectorTerm{MatchExpressions: []v2.NodeSelectorRequirement{requirement}},
+		}
+		for _, existing := range affinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			if equality.Semantic.DeepEqual(existing, term) {
+				return false
+			}
+		}
+		affinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.PreferredDuringSchedulingIgnoredDuringExecution, term)
+		return true
+	}
+
+	if affinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		affinity.RequiredDuringSchedulingIgnoredDuringExecution = &v2.NodeSelector{}
+	}
+	selector := affinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(selector.NodeSelectorTerms) == 0 {
+		selector.NodeSelectorTerms = []v2.NodeSelectorTerm{{}}
+	}
+
+	var changed bool
+	for i := range selector.NodeSelectorTerms {
+		term := &selector.NodeSelectorTerms[i]
+		if containsNodeSelectorRequirement(term.MatchExpressions, requirement) {
+			continue
+		}
+		term.MatchExpressions = append(term.MatchExpressions, requirement)
+		changed = true
+	}
+
+	return changed
+}
+
+// removeNodeAffinity removes every requirement on the key from node affinity.
+// Returns true, if node affinity was modified.
+func removeNodeAffinity(affinity *v2.Affinity, key string) bool {
+	na := affinity.NodeAffinity
+	if na == nil {
+		return false
+	}
+
+	var changed bool
+	if selector := na.RequiredDuringSchedulingIgnoredDuringExecution; selector != nil {
+		terms := make([]v2.NodeSelectorTerm, 0, len(selector.NodeSelectorTerms))
+		for _, term := range selector.NodeSelectorTerms {
+			expressions, removed := removeNodeSelectorRequirements(term.MatchExpressions, key)
+			changed = changed || removed
+			term.MatchExpressions = expressions
+			if len(term.MatchExpressions) > 0 || len(term.MatchFields) > 0 {
+				terms = append(terms, term)
+			}
+		}
+		selector.NodeSelectorTerms = terms
+		if len(terms) == 0 {
+			na.RequiredDuringSchedulingIgnoredDuringExecution = nil
+		}
+	}
+
+	preferred := make([]v2.PreferredSchedulingTerm, 0, len(na.PreferredDuringSchedulingIgnoredDuringExecution))
+	for _, term := range na.PreferredDuringSchedulingIgnoredDuringExecution {
+		expressions, removed := removeNodeSelectorRequirements(term.Preference.MatchExpressions, key)
+		changed = changed || removed
+		term.Preference.MatchExpressions = expressions
+		if len(term.Preference.MatchExpressions) > 0 || len(term.Preference.MatchFields) > 0 {
+			preferred = append(preferred, term)
+		}
+	}
+	na.PreferredDuringSchedulingIgnoredDuringExecution = preferred
+	if len(preferred) == 0 {
+		na.PreferredDuringSchedulingIgnoredDuringExecution = nil
+	}
+
+	if na.RequiredDuringSchedulingIgnoredDuringExecution == nil && na.PreferredDuringSchedulingIgnoredDuringExecution == nil {
+		affinity.NodeAffinity = nil
+	}
+
+	return changed
+}
+
+// addPodAffinityTerm adds requirement from the rule to either required or preferred pod (anti-)affinity terms,
+// depending on rule's weight. Returns true, if terms were modified.
+func addPodAffinityTerm(required *[]v2.PodAffinityTerm, preferred *[]v2.WeightedPodAffinityTerm,
+	rule affinityRule) bool {
+	term := v2.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      rule.key,
+				Operator: metav1.LabelSelectorOperator(rule.operator),
+				Values:   rule.values,
+			}},
+		},
+		TopologyKey: rule.topologyKey,
+	}
+
+	if rule.weight > 0 {
+		weighted := v2.WeightedPodAffinityTerm{Weight: rule.weight, PodAffinityTerm: term}
+		for _, existing := range *preferred {
+			if equality.Semantic.DeepEqual(existing, weighted) {
+				return false
+			}
+		}
+		*preferred = append(*preferred, weighted)
+		return true
+	}
+
+	for _, existing := range *required {
+		if equality.Semantic.DeepEqual(existing, term) {
+			return false
+		}
+	}
+	*required = append(*required, term)
+	return true
+}
+
+// removePodAffinityTerms removes every label selector requirement on the key from pod (anti-)affinity terms.
+// Returns true, if terms were modified.
+func removePodAffinityTerms(required *[]v2.PodAffinityTerm, preferred *[]v2.WeightedPodAffinityTerm,
+	key string) bool {
+	var changed bool
+
+	terms := make([]v2.PodAffinityTerm, 0, len(*required))
+	for _, term := range *required {
+		if removeLabelSelectorRequirements(&term, key) {
+			changed = true
+			if term.LabelSelector == nil {
+				continue
+			}
+		}
+		terms = append(terms, term)
+	}
+	*required = terms
+	if len(terms) == 0 {
+		*required = nil
+	}
+
+	weighted := make([]v2.WeightedPodAffinityTerm, 0, len(*preferred))
+	for _, term := range *preferred {
+		if removeLabelSelectorRequirements(&term.PodAffinityTerm, key) {
+			changed = true
+			if term.PodAffinityTerm.LabelSelector == nil {
+				continue
+			}
+		}
+		weighted = append(weighted, term)
+	}
+	*preferred = weighted
+	if len(weighted) == 0 {
+		*preferred = nil
+	}
+
+	return changed
+}
+
+// removeLabelSelectorRequirements removes requirements on the key from term's label selector. If label selector
+// does not select anything afterward, it is set to nil. Returns true, if term was modified.
+func removeLabelSelectorRequirements(term *v2.PodAffinityTerm, key string) bool {
+	if term.LabelSelector == nil {
+		return false
+	}
+
+	var changed bool
+	if _, exists := term.LabelSelector.MatchLabels[key]; exists {
+		delete(term.LabelSelector.MatchLabels, key)
+		changed = true
+	}
+
+	expressions := make([]metav1.LabelSelectorRequirement, 0, len(term.LabelSelector.MatchExpressions))
+	for _, expression := range term.LabelSelector.MatchExpressions {
+		if expression.Key == key {
+			changed = true
+			continue
+		}
+		expressions = append(expressions, expression)
+	}
+	term.LabelSelector.MatchExpressions = expressions
+
+	if len(term.LabelSelector.MatchLabels) == 0 && len(term.LabelSelector.MatchExpressions) == 0 {
+		term.LabelSelector = nil
+	}
+
+	return changed
+}
+
+// tolerationMatches checks whether existing toleration has the same key as pattern. Value and effect are compared
+// only if they were specified in the pattern.
+func tolerationMatches(existing, pattern v2.Toleration) bool {
+	if existing.Key != pattern.Key {
+		return false
+	}
+	if pattern.Value != "" && existing.Value != pattern.Value {
+		return false
+	}
+	if pattern.Effect != "" && existing.Effect != pattern.Effect {
+		return false
+	}
+	return true
+}
+
+// containsNodeSelectorRequirement checks whether requirements contain semantically equal requirement.
+func containsNodeSelectorRequirement(requirements []v2.NodeSelectorRequirement,
+	requirement v2.NodeSelectorRequirement) bool {
+	for _, existing := range requirements {
+		if equality.Semantic.DeepEqual(existing, requirement) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeNodeSelectorRequirements returns requirements without the ones on the given key.
+func removeNodeSelectorRequirements(requirements []v2.NodeSelectorRequirement,
+	key string) ([]v2.NodeSelectorRequirement, bool) {
+	result := make([]v2.NodeSelectorRequirement, 0, len(requirements))
+	for _, requirement := range requirements {
+		if requirement.Key != key {
+			result = append(result, requirement)
+		}
+	}
+	return result, len(result) != len(requirements)
+}
@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestParseToleration(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       v2.Toleration
+		wantErr    bool
+	}{
+		{
+			name:       "Key, value and effect",
+			annotation: "toleration:maintenance:true:NoSchedule",
+			want: v2.Toleration{
+				Key:      "maintenance",
+				Operator: v2.TolerationOpEqual,
+				Value:    "true",
+				Effect:   v2.TaintEffectNoSchedule,
+			},
+		},
+		{
+			name:       "Only key - tolerates any value and effect",
+			annotation: "toleration:maintenance",
+			want: v2.Toleration{
+				Key:      "maintenance",
+				Operator: v2.TolerationOpExists,
+			},
+		},
+		{
+			name:       "Unknown effect",
+			annotation: "toleration:maintenance:true:Never",
+			wantErr:    true,
+		},
+		{
+			name:       "Missing key",
+			annotation: "toleration",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args := splitAnnotation(tt.annotation)
+			got, err := parseToleration(tt.annotation, args)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAffinity(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       affinityRule
+		wantErr    bool
+	}{
+		{
+			name:       "Required node affinity with multiple values",
+			annotation: "addAffinity:nodeAffinity:zone:In:a,b",
+			want: affinityRule{
+				affinityType: nodeAffinity,
+				key:          "zone",
+				operator:     "In",
+				values:       []string{"a", "b"},
+			},
+		},
+		{
+			name:       "Preferred pod anti-affinity",
+			annotation: "addAffinity:podAntiAffinity:app:Exists::50",
+			want: affinityRule{
+				affinityType: podAntiAffinity,
+				key:          "app",
+				operator:     "Exists",
+				weight:       50,
+				topologyKey:  defaultTopologyKey,
+			},
+		},
+		{
+			name:       "Pod affinity with topology key",
+			annotation: "addAffinity:podAffinity:app:In:db:10:topology.kubernetes.io/zone",
+			want: affinityRule{
+				affinityType: podAffinity,
+				key:          "app",
+				operator:     "In",
+				values:       []string{"db"},
+				weight:       10,
+				topologyKey:  "topology.kubernetes.io/zone",
+			},
+		},
+		{
+			name:       "Topology key of node affinity",
+			annotation: "addAffinity:nodeAffinity:zone:In:a::topology.kubernetes.io/zone",
+			wantErr:    true,
+		},
+		{
+			name:       "Invalid topology key",
+			annotation: "addAffinity:podAffinity:app:In:db::not a key",
+			wantErr:    true,
+		},
+		{
+			name:       "Exists operator with values",
+			annotation: "addAffinity:nodeAffinity:zone:Exists:a",
+			wantErr:    true,
+		},
+		{
+			name:       "In operator without values",
+			annotation: "addAffinity:podAffinity:app:In",
+			wantErr:    true,
+		},
+		{
+			name:       "Gt operator with multiple values",
+			annotation: "addAffinity:nodeAffinity:cpus:Gt:4,8",
+			wantErr:    true,
+		},
+		{
+			name:       "Lt operator with a non-integer value",
+			annotation: "addAffinity:nodeAffinity:cpus:Lt:four",
+			wantErr:    true,
+		},
+		{
+			name:       "Gt operator with an integer value",
+			annotation: "addAffinity:nodeAffinity:cpus:Gt:4",
+			want: affinityRule{
+				affinityType: nodeAffinity,
+				key:          "cpus",
+				operator:     "Gt",
+				values:       []string{"4"},
+			},
+		},
+		{
+			name:       "Gt operator is not supported by pod affinity",
+			annotation: "addAffinity:podAffinity:app:Gt:1",
+			wantErr:    true,
+		},
+		{
+			name:       "Unknown affinity type",
+			annotation: "addAffinity:serviceAffinity:app:In:a",
+			wantErr:    true,
+		},
+		{
+			name:       "Weight out of range",
+			annotation: "addAffinity:nodeAffinity:zone:In:a:101",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args := splitAnnotation(tt.annotation)
+			got, err := parseAffinity(tt.annotation, args)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_executeAddToleration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, appsv1.AddToScheme(scheme))
+
+	toleration := v2.Toleration{
+		Key:      "maintenance",
+		Operator: v2.TolerationOpEqual,
+		Value:    "true",
+		Effect:   v2.TaintEffectNoSchedule,
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "test-ns",
+		},
+	}
+	deploymentWithToleration := deployment.DeepCopy()
+	deploymentWithToleration.Spec.Template.Spec.Tolerations = []v2.Toleration{toleration}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rm-test",
+		},
+	}
+	rm.Status.Conditions = make(map[string]string)
+
+	tests := []struct {
+		name     string
+		resource client.Object
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "Successful toleration addition",
+			resource: deployment,
+			want:     1,
+		},
+		{
+			name:     "Toleration already exists",
+			resource: deploymentWithToleration,
+			want:     1,
+		},
+		{
+			name:     "Resource without pod specification",
+			resource: &v2.Service{},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.resource, rm).WithStatusSubresource(rm).Build(),
+				Scheme: scheme,
+			}
+
+			err := r.executeAddToleration(context.Background(), tt.resource, rm, toleration)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			got := &appsv1.Deployment{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.resource), got))
+			assert.Len(t, got.Spec.Template.Spec.Tolerations, tt.want)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_executeAddAffinity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, appsv1.AddToScheme(scheme))
+
+	rule := affinityRule{
+		affinityType: nodeAffinity,
+		key:          "maintenance",
+		operator:     string(v2.NodeSelectorOpDoesNotExist),
+	}
+
+	deploymentWithTwoTerms := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "test-ns",
+		},
+	}
+	deploymentWithTwoTerms.Spec.Template.Spec.Affinity = &v2.Affinity{
+		NodeAffinity: &v2.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v2.NodeSelector{
+				NodeSelectorTerms: []v2.NodeSelectorTerm{
+					{MatchExpressions: []v2.NodeSelectorRequirement{{Key: "zone", Operator: v2.NodeSelectorOpIn, Values: []string{"a"}}}},
+					{MatchExpressions: []v2.NodeSelectorRequirement{{Key: "zone", Operator: v2.NodeSelectorOpIn, Values: []string{"b"}}}},
+				},
+			},
+		},
+	}
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+		},
+	}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rm-test",
+		},
+	}
+	rm.Status.Conditions = make(map[string]string)
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(deploymentWithTwoTerms, pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme: scheme,
+	}
+
+	assert.Nil(t, r.executeAddAffinity(context.Background(), deploymentWithTwoTerms, rm, rule))
+	// Adding the same rule twice must not duplicate requirements
+	assert.Nil(t, r.executeAddAffinity(context.Background(), deploymentWithTwoTerms, rm, rule))
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(rm), rm))
+
+	got := &appsv1.Deployment{}
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(deploymentWithTwoTerms), got))
+	terms := got.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	assert.Len(t, terms, 2)
+	for _, term := range terms {
+		assert.Len(t, term.MatchExpressions, 2)
+		assert.Equal(t, "maintenance", term.MatchExpressions[1].Key)
+	}
+
+	assert.Nil(t, r.executeRemoveAffinity(context.Background(), got, rm, nodeAffinity, "maintenance"))
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(rm), rm))
+	assert.Nil(t, r.executeRemoveAffinity(context.Background(), got, rm, nodeAffinity, "zone"))
+	assert.Nil(t, got.Spec.Template.Spec.Affinity)
+
+	assert.NotNil(t, r.executeRemoveAffinity(context.Background(), got, rm, "serviceAffinity", "zone"))
+
+	// Affinity of an existing Pod is immutable
+	assert.NotNil(t, r.executeAddAffinity(context.Background(), pod, rm, rule))
+	assert.NotNil(t, r.executeRemoveAffinity(context.Background(), pod, rm, nodeAffinity, "zone"))
+	assert.NotNil(t, r.executeRemoveToleration(context.Background(), pod, rm, v2.Toleration{Key: "zone"}))
+}
+
+func TestAddPodAffinityTerm(t *testing.T) {
+	var required []v2.PodAffinityTerm
+	var preferred []v2.WeightedPodAffinityTerm
+
+	preferredRule := affinityRule{affinityType: podAntiAffinity, key: "app", operator: "In", values: []string{"db"}, weight: 10}
+	requiredRule := affinityRule{affinityType: podAntiAffinity, key: "app", operator: "In", values: []string{"db"},
+		topologyKey: defaultTopologyKey}
+
+	assert.True(t, addPodAffinityTerm(&required, &preferred, preferredRule))
+	assert.False(t, addPodAffinityTerm(&required, &preferred, preferredRule))
+	assert.True(t, addPodAffinityTerm(&required, &preferred, requiredRule))
+	assert.False(t, addPodAffinityTerm(&required, &preferred, requiredRule))
+	assert.Len(t, required, 1)
+	assert.Len(t, preferred, 1)
+	assert.Equal(t, defaultTopologyKey, required[0].TopologyKey)
+
+	assert.True(t, removePodAffinityTerms(&required, &preferred, "app"))
+	assert.Nil(t, required)
+	assert.Nil(t, preferred)
+}
@@ -54,6 +54,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 	k8sClient = fake.NewClientBuilder().
 		WithScheme(scheme).
 		WithObjects(podWithFinalizers, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	updateErrK8sClient := fake.NewClientBuilder().
@@ -63,6 +64,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 				return errors.New("error during update")
 			}}).
 		WithObjects(podWithFinalizers, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	type fields struct {
@@ -71,7 +73,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 	}
 	type args struct {
 		resource client.Object
-		rm       v1.ResourceModifier
+		rm       *v1.ResourceModifier
 	}
 
 	tests := []struct {
@@ -88,7 +90,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 			},
 			args: args{
 				resource: podWithFinalizers,
-				rm:       *rm,
+				rm:       rm,
 			},
 			wantErr: true,
 		},
@@ -100,7 +102,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 			},
 			args: args{
 				resource: podWithFinalizers,
-				rm:       *rm,
+				rm:       rm,
 			},
 			wantErr: false,
 		},
@@ -112,7 +114,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 			},
 			args: args{
 				resource: podWithoutFinalizers,
-				rm:       *rm,
+				rm:       rm,
 			},
 			wantErr: false,
 		},
@@ -124,7 +126,7 @@ func TestResourceModifierReconciler_executeRemoveAnyFinalizerAnnotation(t *testi
 				Client: tt.fields.Client,
 				Scheme: tt.fields.Scheme,
 			}
-			if err := r.executeRemoveAnyFinalizerAnnotation(tt.args.resource, tt.args.rm); (err != nil) != tt.wantErr {
+			if err := r.executeRemoveAnyFinalizerAnnotation(context.Background(), tt.args.resource, tt.args.rm); (err != nil) != tt.wantErr {
 				t.Errorf("executeRemoveAnyFinalizerAnnotation() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -174,6 +176,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 	k8sClient = fake.NewClientBuilder().
 		WithScheme(scheme).
 		WithObjects(podWithoutFinalizers, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	updateErrK8sClient := fake.NewClientBuilder().
@@ -183,6 +186,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 				return errors.New("error during update")
 			}}).
 		WithObjects(podWithoutFinalizers, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	type fields struct {
@@ -191,7 +195,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 	}
 	type args struct {
 		resource  client.Object
-		rm        v1.ResourceModifier
+		rm        *v1.ResourceModifier
 		finalizer string
 	}
 
@@ -209,7 +213,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 			},
 			args: args{
 				resource:  podWithoutFinalizers,
-				rm:        *rm,
+				rm:        rm,
 				finalizer: desiredFinalizer,
 			},
 			wantErr: false,
@@ -222,7 +226,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 			},
 			args: args{
 				resource:  podWithDesiredFinalizer,
-				rm:        *rm,
+				rm:        rm,
 				finalizer: desiredFinalizer,
 			},
 			wantErr: false,
@@ -235,7 +239,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 			},
 			args: args{
 				resource:  podWithDesiredFinalizer,
-				rm:        *rm,
+				rm:        rm,
 				finalizer: desiredFinalizer,
 			},
 			wantErr: false,
@@ -248,7 +252,7 @@ func TestResourceModifierReconciler_executeAddFinalizer(t *testing.T) {
 				Client: tt.fields.Client,
 				Scheme: tt.fields.Scheme,
 			}
-			err := r.executeAddFinalizer(tt.args.resource, tt.args.rm, tt.args.finalizer)
+			err := r.executeAddFinalizer(context.Background(), tt.args.resource, tt.args.rm, tt.args.finalizer)
 			// TODO: add additional test for error message
 			if tt.wantErr {
 				assert.NotNil(t, err)
@@ -302,6 +306,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 	k8sClient = fake.NewClientBuilder().
 		WithScheme(scheme).
 		WithObjects(podWithoutFinalizers, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	updateErrK8sClient := fake.NewClientBuilder().
@@ -311,6 +316,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 				return errors.New("error during update")
 			}}).
 		WithObjects(podWithoutFinalizers, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	type fields struct {
@@ -319,7 +325,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 	}
 	type args struct {
 		resource  client.Object
-		rm        v1.ResourceModifier
+		rm        *v1.ResourceModifier
 		finalizer string
 	}
 	tests := []struct {
@@ -332,7 +338,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 			name: "Failed add annotation run - annotation already exists",
 			args: args{
 				resource:  podWithDesiredFinalizer,
-				rm:        *rm,
+				rm:        rm,
 				finalizer: desiredFinalizer,
 			},
 			fields: fields{
@@ -345,7 +351,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 			name: "Failed add annotation run - update error",
 			args: args{
 				resource:  podWithoutFinalizers,
-				rm:        *rm,
+				rm:        rm,
 				finalizer: desiredFinalizer,
 			},
 			fields: fields{
@@ -358,7 +364,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 			name: "Successful add annotation run",
 			args: args{
 				resource:  podWithoutFinalizers,
-				rm:        *rm,
+				rm:        rm,
 				finalizer: desiredFinalizer,
 			},
 			fields: fields{
@@ -376,7 +382,7 @@ func TestResourceModifierReconciler_executeAddFinalizer1(t *testing.T) {
 				Scheme: tt.fields.Scheme,
 			}
 
-			err := r.executeAddFinalizer(tt.args.resource, tt.args.rm, tt.args.finalizer)
+			err := r.executeAddFinalizer(context.Background(), tt.args.resource, tt.args.rm, tt.args.finalizer)
 			if tt.wantErr {
 				assert.NotNil(t, err)
 			} else {
@@ -432,6 +438,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 	k8sClient := fake.NewClientBuilder().
 		WithScheme(scheme).
 		WithObjects(podWithoutLabels, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	updateErrK8sClient := fake.NewClientBuilder().
@@ -441,6 +448,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 				return errors.New("error during update")
 			}}).
 		WithObjects(podWithoutLabels, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	type fields struct {
@@ -449,7 +457,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 	}
 	type args struct {
 		resource client.Object
-		rm       v1.ResourceModifier
+		rm       *v1.ResourceModifier
 		label    string
 	}
 	tests := []struct {
@@ -462,7 +470,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 			name: "Label already exists",
 			args: args{
 				resource: podWithLabel,
-				rm:       *rm,
+				rm:       rm,
 				label:    desiredLabel,
 			},
 			fields: fields{
@@ -475,7 +483,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 			name: "Failed to add label - update error",
 			args: args{
 				resource: podWithoutLabels,
-				rm:       *rm,
+				rm:       rm,
 				label:    desiredLabel,
 			},
 			fields: fields{
@@ -488,7 +496,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 			name: "Successful label addition",
 			args: args{
 				resource: podWithoutLabels,
-				rm:       *rm,
+				rm:       rm,
 				label:    desiredLabel,
 			},
 			fields: fields{
@@ -506,7 +514,7 @@ func TestResourceModifierReconciler_executeAddLabel(t *testing.T) {
 				Scheme: tt.fields.Scheme,
 			}
 
-			err := r.executeAddLabel(tt.args.resource, tt.args.rm, tt.args.label)
+			err := r.executeAddLabel(context.Background(), tt.args.resource, tt.args.rm, tt.args.label)
 			if tt.wantErr {
 				assert.NotNil(t, err)
 			} else {
@@ -556,6 +564,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 	k8sClient := fake.NewClientBuilder().
 		WithScheme(scheme).
 		WithObjects(podWithLabel, podWithoutLabel, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	updateErrK8sClient := fake.NewClientBuilder().
@@ -566,6 +575,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 			},
 		}).
 		WithObjects(podWithLabel, rm).
+		WithStatusSubresource(rm).
 		Build()
 
 	type fields struct {
@@ -574,7 +584,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 	}
 	type args struct {
 		resource client.Object
-		rm       v1.ResourceModifier
+		rm       *v1.ResourceModifier
 		label    string
 	}
 	tests := []struct {
@@ -587,7 +597,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 			name: "Failed remove label - label does not exist",
 			args: args{
 				resource: podWithoutLabel,
-				rm:       *rm,
+				rm:       rm,
 				label:    labelKey,
 			},
 			fields: fields{
@@ -600,7 +610,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 			name: "Failed remove label - update error",
 			args: args{
 				resource: podWithLabel,
-				rm:       *rm,
+				rm:       rm,
 				label:    labelKey,
 			},
 			fields: fields{
@@ -613,7 +623,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 			name: "Successful remove label",
 			args: args{
 				resource: podWithLabel,
-				rm:       *rm,
+				rm:       rm,
 				label:    labelKey,
 			},
 			fields: fields{
@@ -631,7 +641,7 @@ func TestResourceModifierReconciler_executeRemoveLabel(t *testing.T) {
 				Scheme: tt.fields.Scheme,
 			}
 
-			err := r.executeRemoveLabel(tt.args.resource, tt.args.rm, tt.args.label)
+			err := r.executeRemoveLabel(context.Background(), tt.args.resource, tt.args.rm, tt.args.label)
 			if tt.wantErr {
 				assert.NotNil(t, err)
 			} else {
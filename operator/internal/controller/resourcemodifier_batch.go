@@ -0,0 +1,394 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	errs "errors"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/applyconfigurations"
+	"k8s.io/client-go/util/retry"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+)
+
+const (
+	// FieldManager is the name of the manager, under which the controller writes resources
+	FieldManager = "annot-resource-modif"
+)
+
+// batchClient composes all actions of ResourceModifier on the target resource in memory, so that the resource is
+// written once by flush, instead of once per action. Updates of the target are deferred, and patches are applied
+// locally. Deletion of the target writes the pending changes first.
+// Writes of the status of ResourceModifier are skipped, as the status is written once the execution finishes.
+// Other resources are read and written as usual.
+type batchClient struct {
+	client.Client
+
+	target client.Object
+
+	// strategy determines how the target is written
+	strategy annotresourcemodifv1.WriteStrategy
+
+	// force makes Server-Side Apply take over fields owned by other managers
+	force bool
+
+	// written is the state of the target, which was read from, or written to the API server last
+	written client.Object
+}
+
+func newBatchClient(c client.Client, target client.Object, spec annotresourcemodifv1.ResourceModifierSpec) *batchClient {
+	return &batchClient{
+		Client:   c,
+		target:   target,
+		strategy: spec.WriteStrategy,
+		force:    spec.ForceConflicts,
+		written:  target.DeepCopyObject().(client.Object),
+	}
+}
+
+// Get reads the object. When the target is read, its state becomes the base of the pending changes.
+func (c *batchClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	if obj == c.target {
+		c.written = obj.DeepCopyObject().(client.Object)
+	}
+
+	return nil
+}
+
+// Update defers the update of the target until flush.
+func (c *batchClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if obj == c.target {
+		return nil
+	}
+
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch applies the patch to the target in memory.
+func (c *batchClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if obj == c.target {
+		return applyPatch(obj, patch)
+	}
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// Delete writes pending changes of the target, before it is deleted, so that its finalizers and other fields
+// observed by controllers during the deletion are up-to-date.
+func (c *batchClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if obj == c.target {
+		if err := c.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// Status returns a writer, which skips writes of the status of ResourceModifier.
+func (c *batchClient) Status() client.SubResourceWriter {
+	return skippedStatusWriter{}
+}
+
+// flush writes pending changes of the target, either as a single merge patch, or by Server-Side Apply.
+// The resourceVersion of the state, on which the changes were composed, is sent as well, so the write fails with
+// a conflict, if the target changed meanwhile.
+func (c *batchClient) flush(ctx context.Context) error {
+	if equality.Semantic.DeepEqual(c.written, c.target) {
+		return nil
+	}
+
+	if c.strategy == annotresourcemodifv1.ServerSideApplyWriteStrategy {
+		if err := c.apply(ctx); err != nil {
+			return err
+		}
+	} else {
+		patch := client.MergeFromWithOptions(c.written, client.MergeFromWithOptimisticLock{})
+		if err := c.Client.Patch(ctx, c.target, patch, client.FieldOwner(FieldManager)); err != nil {
+			return err
+		}
+	}
+	c.written = c.target.DeepCopyObject().(client.Object)
+
+	return nil
+}
+
+// apply writes pending changes of the target by Server-Side Apply. The applied configuration consists of the
+// changed fields, and of the fields already owned by FieldManager, so that they are not removed. Fields removed
+// by the actions may be owned by other managers, so they are removed by a merge patch first.
+func (c *batchClient) apply(ctx context.Context) error {
+	gvk, err := apiutil.GVKForObject(c.target, c.Scheme())
+	if err != nil {
+		return err
+	}
+	converter := applyconfigurations.NewTypeConverter(c.Scheme())
+	toTyped := func(obj client.Object) (*typed.TypedValue, error) {
+		withKind := obj.DeepCopyObject().(client.Object)
+		withKind.GetObjectKind().SetGroupVersionKind(gvk)
+		return converter.ObjectToTyped(withKind)
+	}
+
+	before, err := toTyped(c.written)
+	if err != nil {
+		return err
+	}
+	after, err := toTyped(c.target)
+	if err != nil {
+		return err
+	}
+	comparison, err := before.Compare(after)
+	if err != nil {
+		return err
+	}
+
+	resourceVersion := c.written.GetResourceVersion()
+	if !comparison.Removed.Empty() {
+		// both states are converted the same way, so that the patch contains only the removed fields
+		base, err := converter.TypedToObject(before)
+		if err != nil {
+			return err
+		}
+		removed, err := converter.TypedToObject(before.RemoveItems(comparison.Removed))
+		if err != nil {
+			return err
+		}
+		current := removed.(client.Object)
+		patch := client.MergeFromWithOptions(base.(client.Object), client.MergeFromWithOptimisticLock{})
+		if err = c.Client.Patch(ctx, current, patch, client.FieldOwner(FieldManager)); err != nil {
+			return err
+		}
+		resourceVersion = current.GetResourceVersion()
+	}
+
+	owned, err := ownedFields(c.written)
+	if err != nil {
+		return err
+	}
+	fields := owned.Union(comparison.Added).Union(comparison.Modified)
+	configuration, ok := after.ExtractItems(fields.Leaves()).AsValue().Unstructured().(map[string]interface{})
+	if !ok {
+		configuration = map[string]interface{}{}
+	}
+
+	applied := &unstructured.Unstructured{Object: configuration}
+	applied.SetGroupVersionKind(gvk)
+	applied.SetName(c.target.GetName())
+	applied.SetNamespace(c.target.GetNamespace())
+	applied.SetResourceVersion(resourceVersion)
+
+	options := []client.PatchOption{client.FieldOwner(FieldManager)}
+	if c.force {
+		options = append(options, client.ForceOwnership)
+	}
+	if err = c.Client.Patch(ctx, applied, client.Apply, options...); err != nil {
+		if isFieldManagerConflict(err) {
+			return &fieldConflictError{message: err.Error()}
+		}
+		return err
+	}
+
+	resetObject(c.target)
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(applied.Object, c.target)
+}
+
+// fieldConflictError is returned, when Server-Side Apply conflicts with fields owned by other managers. Unlike
+// a conflict of resourceVersions, it is not retried, as it persists until the other manager releases the fields.
+type fieldConflictError struct {
+	message string
+}
+
+func (e *fieldConflictError) Error() string {
+	return e.message
+}
+
+// isFieldManagerConflict returns true, if the error reports fields owned by other managers.
+func isFieldManagerConflict(err error) bool {
+	var status apierrors.APIStatus
+	if !errs.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ownedFields returns the set of fields of the object, which FieldManager owns by Server-Side Apply.
+func ownedFields(obj client.Object) (*fieldpath.Set, error) {
+	owned := &fieldpath.Set{}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply ||
+			entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+		if err := owned.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+			return nil, err
+		}
+	}
+
+	return owned, nil
+}
+
+// skippedStatusWriter skips all writes of the status.
+type skippedStatusWriter struct{}
+
+func (skippedStatusWriter) Create(context.Context, client.Object, client.Object, ...client.SubResourceCreateOption) error {
+	return nil
+}
+
+func (skippedStatusWriter) Update(context.Context, client.Object, ...client.SubResourceUpdateOption) error {
+	return nil
+}
+
+func (skippedStatusWriter) Patch(context.Context, client.Object, client.Patch, ...client.SubResourcePatchOption) error {
+	return nil
+}
+
+// applyPatch applies the patch to the object in memory, the way the API server would.
+func applyPatch(obj client.Object, patch client.Patch) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	current, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch patch.Type() {
+	case types.JSONPatchType:
+		operations, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return err
+		}
+		if patched, err = operations.Apply(current); err != nil {
+			return err
+		}
+	case types.MergePatchType:
+		if patched, err = jsonpatch.MergePatch(current, data); err != nil {
+			return err
+		}
+	case types.StrategicMergePatchType:
+		if patched, err = strategicpatch.StrategicMergePatch(current, data, obj); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s%s", unknownPatchType, patch.Type())
+	}
+
+	// fields removed by the patch must not survive the decoding
+	resetObject(obj)
+	return json.Unmarshal(patched, obj)
+}
+
+// resetObject sets all fields of the object to zero values, so that decoding into it does not merge with its
+// previous state.
+func resetObject(obj client.Object) {
+	value := reflect.ValueOf(obj).Elem()
+	value.Set(reflect.Zero(value.Type()))
+}
+
+// applyBatch executes annotations and patches of ResourceModifier on the resource in memory, and writes the result
+// once using the client c. Unless ResourceModifier is Atomic, changes made by actions before a failed one are
+// written as well. If writing fails with a retryable error (e.g. the resource changed meanwhile), the resource is
+// fetched again, and all actions are executed again, with a jittered backoff. Attempts are counted in the status.
+// The When expressions are evaluated on every attempt, against the state of the resource the actions are executed on;
+// if they do not hold, nothing is written, and an error wrapping errPreconditionNotMet is returned.
+// Applied patches are recorded in the status only after the resource is written, with its new resourceVersion.
+// The state of the resource, on which the actions were executed last, is returned.
+func (r *ResourceModifierReconciler) applyBatch(ctx context.Context, c client.Client, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) (client.Object, error) {
+	original := resource.DeepCopyObject().(client.Object)
+	first := true
+	err := retry.OnError(writeBackoff, isRetryable, func() error {
+		// cancelled context (e.g. shutdown of the controller) is not retryable
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rm.Status.Attempts++
+		if !first {
+			key := client.ObjectKeyFromObject(resource)
+			resetObject(resource)
+			getCtx, cancel := r.operationContext(ctx)
+			err := r.Client.Get(getCtx, key, resource)
+			cancel()
+			if err != nil {
+				return err
+			}
+		}
+		first = false
+		original = resource.DeepCopyObject().(client.Object)
+		if unmet := unmetPrecondition(ctx, rm, resource); unmet != "" {
+			return fmt.Errorf("%w: %s", errPreconditionNotMet, unmet)
+		}
+
+		batch := newBatchClient(c, resource, rm.Spec)
+		executor := *r
+		executor.Client = batch
+
+		rm.Status.AppliedPatches = nil
+		err := executor.applyActions(ctx, resource, rm)
+		if err != nil && rm.Spec.Atomic {
+			rm.Status.AppliedPatches = nil
+			return err
+		}
+
+		flushCtx, cancel := r.operationContext(ctx)
+		defer cancel()
+
+		if flushErr := batch.flush(flushCtx); flushErr != nil {
+			rm.Status.AppliedPatches = nil
+			return flushErr
+		}
+		recordAppliedPatches(rm, resource.GetResourceVersion())
+		return err
+	})
+
+	recordFieldConflict(rm, err)
+	return original, err
+}
+
+// recordAppliedPatches stamps patches applied by the batch with the resourceVersion of the resource written by
+// flush, and with the time of the write. Until then, the patches exist only in memory.
+func recordAppliedPatches(rm *annotresourcemodifv1.ResourceModifier, resourceVersion string) {
+	now := metav1.Now()
+	for i := range rm.Status.AppliedPatches {
+		rm.Status.AppliedPatches[i].ResourceVersion = resourceVersion
+		rm.Status.AppliedPatches[i].AppliedAt = now
+	}
+}
+
+// recordFieldConflict records in the FieldConflict condition, that the resource could not be written because of
+// a conflict with another manager, or removes the condition, if there was none.
+func recordFieldConflict(rm *annotresourcemodifv1.ResourceModifier, err error) {
+	if rm.Status.Conditions == nil {
+		return
+	}
+
+	var conflict *fieldConflictError
+	if errs.As(err, &conflict) || apierrors.IsConflict(err) {
+		rm.Status.Conditions[annotresourcemodifv1.StatusFieldConflict] = err.Error()
+		return
+	}
+	delete(rm.Status.Conditions, annotresourcemodifv1.StatusFieldConflict)
+}
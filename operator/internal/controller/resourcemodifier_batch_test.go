@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"testing"
+)
+
+func TestResourceModifierReconciler_applyBatch(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	tests := []struct {
+		name        string
+		annotations []string
+		patches     []v1.Patch
+		atomic      bool
+		failures    int
+		failure     error
+		wantErr     bool
+		wantWrites  int
+		wantRetries int32
+		wantLabels  map[string]string
+	}{
+		{
+			name:        "All actions are written at once",
+			annotations: []string{"addLabel:env:prod", "addLabel:team:core", "removeLabel:tmp", "addFinalizer:test/f"},
+			patches: []v1.Patch{{
+				Type:  v1.StrategicMergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{"metadata":{"labels":{"tier":"db"}}}`)},
+			}},
+			wantWrites: 1,
+			wantLabels: map[string]string{"env": "prod", "team": "core", "tier": "db"},
+		},
+		{
+			name:        "JSON patch tests the composed state",
+			annotations: []string{"addLabel:env:prod"},
+			patches: []v1.Patch{{
+				Type: v1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op":"test","path":"/metadata/labels/env","value":"prod"},` +
+					`{"op":"add","path":"/metadata/labels/tier","value":"db"}]`)},
+			}},
+			wantWrites: 1,
+			wantLabels: map[string]string{"env": "prod", "tmp": "true", "tier": "db"},
+		},
+		{
+			name:        "Actions before the failed one are written",
+			annotations: []string{"addLabel:env:prod", "setServiceType:ClusterIP"},
+			wantErr:     true,
+			wantWrites:  1,
+			wantLabels:  map[string]string{"env": "prod", "tmp": "true"},
+		},
+		{
+			name:        "Atomic execution writes nothing on failure",
+			annotations: []string{"addLabel:env:prod", "setServiceType:ClusterIP"},
+			atomic:      true,
+			wantErr:     true,
+			wantLabels:  map[string]string{"tmp": "true"},
+		},
+		{
+			name:        "Actions are executed again on conflict",
+			annotations: []string{"addLabel:env:prod"},
+			failures:    2,
+			wantWrites:  3,
+			wantRetries: 2,
+			wantLabels:  map[string]string{"env": "prod", "tmp": "true"},
+		},
+		{
+			name: "Applied patches record the version written after a retry",
+			patches: []v1.Patch{{
+				Type:  v1.MergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{"metadata":{"labels":{"tier":"db"}}}`)},
+			}},
+			failures:    1,
+			wantWrites:  2,
+			wantRetries: 1,
+			wantLabels:  map[string]string{"tmp": "true", "tier": "db"},
+		},
+		{
+			name:        "Retries are bounded",
+			annotations: []string{"addLabel:env:prod"},
+			failures:    10,
+			wantErr:     true,
+			wantWrites:  5,
+			wantRetries: 4,
+			wantLabels:  map[string]string{"tmp": "true"},
+		},
+		{
+			name:        "Server timeouts are retried",
+			annotations: []string{"addLabel:env:prod"},
+			failures:    1,
+			failure:     apierrors.NewServerTimeout(schema.GroupResource{Resource: "pods"}, "patch", 1),
+			wantWrites:  2,
+			wantRetries: 1,
+			wantLabels:  map[string]string{"env": "prod", "tmp": "true"},
+		},
+		{
+			name:        "Terminal errors are not retried",
+			annotations: []string{"addLabel:env:prod"},
+			failures:    1,
+			failure:     apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "test-pod", nil),
+			wantErr:     true,
+			wantWrites:  1,
+			wantLabels:  map[string]string{"tmp": "true"},
+		},
+		{
+			name:        "Nothing is written without changes",
+			annotations: []string{"addLabel:tmp:true"},
+			wantLabels:  map[string]string{"tmp": "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+					Labels:    map[string]string{"tmp": "true"},
+				},
+			}
+			rm := &v1.ResourceModifier{
+				Spec: v1.ResourceModifierSpec{Annotations: tt.annotations, Patches: tt.patches, Atomic: tt.atomic},
+				Status: v1.ResourceModifierStatus{
+					Conditions: map[string]string{},
+				},
+			}
+
+			writes := 0
+			failures := tt.failures
+			failure := tt.failure
+			if failure == nil {
+				failure = apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, pod.Name, nil)
+			}
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).WithInterceptorFuncs(interceptor.Funcs{
+					Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+						writes++
+						return c.Update(ctx, obj, opts...)
+					},
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+						opts ...client.PatchOption) error {
+						writes++
+						if failures > 0 {
+							failures--
+							return failure
+						}
+						return c.Patch(ctx, obj, patch, opts...)
+					},
+				}).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			resource := &v2.Pod{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), resource))
+
+			_, err := r.applyBatch(context.Background(), r.Client, resource, rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.wantWrites, writes)
+			assert.Equal(t, tt.wantRetries+1, rm.Status.Attempts)
+
+			got := &v2.Pod{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), got))
+			assert.Equal(t, tt.wantLabels, got.Labels)
+
+			if tt.wantErr {
+				assert.Empty(t, rm.Status.AppliedPatches)
+				return
+			}
+			assert.Len(t, rm.Status.AppliedPatches, len(tt.patches))
+			for _, applied := range rm.Status.AppliedPatches {
+				assert.Equal(t, got.ResourceVersion, applied.ResourceVersion)
+			}
+		})
+	}
+}
+
+func TestResourceModifierReconciler_applyBatch_serverSideApply(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	tests := []struct {
+		name          string
+		force         bool
+		conflict      bool
+		wantErr       bool
+		wantCondition bool
+	}{
+		{
+			name: "Changed and owned fields are applied",
+		},
+		{
+			name:          "Conflict with another manager is reported",
+			conflict:      true,
+			wantErr:       true,
+			wantCondition: true,
+		},
+		{
+			name:  "Conflicts are forced",
+			force: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+					Labels:    map[string]string{"tmp": "true", "owned": "true"},
+				},
+			}
+			rm := &v1.ResourceModifier{
+				Spec: v1.ResourceModifierSpec{
+					Annotations:    []string{"addLabel:env:prod", "removeLabel:tmp"},
+					WriteStrategy:  v1.ServerSideApplyWriteStrategy,
+					ForceConflicts: tt.force,
+				},
+				Status: v1.ResourceModifierStatus{
+					Conditions: map[string]string{},
+				},
+			}
+
+			var patches []string
+			var forced bool
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+						opts ...client.PatchOption) error {
+						data, err := patch.Data(obj)
+						assert.Nil(t, err)
+						patches = append(patches, string(patch.Type())+" "+string(data))
+						if patch.Type() != types.ApplyPatchType {
+							return c.Patch(ctx, obj, patch, opts...)
+						}
+
+						options := &client.PatchOptions{}
+						options.ApplyOptions(opts)
+						forced = options.Force != nil && *options.Force
+						assert.Equal(t, FieldManager, options.FieldManager)
+						if tt.conflict {
+							return &apierrors.StatusError{ErrStatus: metav1.Status{
+								Status:  metav1.StatusFailure,
+								Code:    409,
+								Reason:  metav1.StatusReasonConflict,
+								Message: `Apply failed with 1 conflict: conflict with "kubectl": .metadata.labels.env`,
+								Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{
+									Type:  metav1.CauseTypeFieldManagerConflict,
+									Field: ".metadata.labels.env",
+								}}},
+							}}
+						}
+						return nil
+					},
+				}).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			resource := &v2.Pod{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), resource))
+			// the label "owned" was applied by the controller before
+			resource.ManagedFields = []metav1.ManagedFieldsEntry{{
+				Manager:    FieldManager,
+				Operation:  metav1.ManagedFieldsOperationApply,
+				APIVersion: "v1",
+				FieldsType: "FieldsV1",
+				FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:owned":{}}}}`)},
+			}}
+
+			_, err := r.applyBatch(context.Background(), r.Client, resource, rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.force, forced)
+			assert.Equal(t, tt.wantCondition, rm.Status.Conditions[v1.StatusFieldConflict] != "")
+
+			// removal is written by a merge patch, changed and owned fields are applied, conflicts are not retried
+			assert.Len(t, patches, 2)
+			assert.Contains(t, patches[0], string(types.MergePatchType)+" ")
+			assert.Contains(t, patches[0], `"labels":{"tmp":null}`)
+			assert.Contains(t, patches[1], string(types.ApplyPatchType)+" ")
+			assert.Contains(t, patches[1], `"labels":{"env":"prod","owned":"true"}`)
+			assert.Contains(t, patches[1], `"name":"test-pod","namespace":"test-ns"`)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_apply_cancelled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	rm := &v1.ResourceModifier{
+		Spec: v1.ResourceModifierSpec{Annotations: []string{"addLabel:env:prod"}, RevertOnDelete: true},
+		Status: v1.ResourceModifierStatus{
+			Conditions: map[string]string{},
+		},
+	}
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	resource := &v2.Pod{}
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), resource))
+
+	// the context is cancelled before the first attempt, so nothing is executed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	original, err := r.apply(ctx, resource, rm)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, original)
+	assert.Zero(t, rm.Status.Attempts)
+
+	got := &v2.Pod{}
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), got))
+	assert.Empty(t, got.Labels)
+}
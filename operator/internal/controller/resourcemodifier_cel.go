@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
+)
+
+// celCostLimit limits the cost of evaluating an expression, so an expression iterating over a large resource
+// cannot stall the controller.
+const celCostLimit = 1000000
+
+// errNotEvaluated is returned, when an expression, which compiles, fails to be evaluated against the resource,
+// e.g. because it refers to a field, which is not set.
+var errNotEvaluated = errors.New("failed to evaluate")
+
+// celEnv declares the variables available to expressions: the resource as `object`.
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("object", cel.DynType))
+})
+
+// celPrograms caches compiled programs by their expression, since the same expressions are evaluated repeatedly,
+// e.g. for every resource in Selector mode, and on every write attempt. Programs are safe for concurrent use.
+var celPrograms sync.Map
+
+// ValidateExpression checks that the CEL expression compiles, and evaluates to a boolean.
+// The expression is not evaluated.
+func ValidateExpression(expression string) error {
+	_, err := compileExpression(expression)
+	return err
+}
+
+// compileExpression compiles the CEL expression into a program, which evaluates to a boolean. The type of the
+// expression must be known to be a boolean when it is compiled, e.g. a field of the resource, whose type is only
+// known at runtime, must be compared: object.spec.suspend == true.
+func compileExpression(expression string) (cel.Program, error) {
+	if program, ok := celPrograms.Load(expression); ok {
+		return program.(cel.Program), nil
+	}
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expression must evaluate to bool, not %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(celCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	celPrograms.Store(expression, program)
+
+	return program, nil
+}
+
+// evaluateExpression evaluates the CEL expression against the resource. Errors of the evaluation, unlike those
+// of the compilation, wrap errNotEvaluated.
+func evaluateExpression(ctx context.Context, expression string, resource client.Object) (bool, error) {
+	program, err := compileExpression(expression)
+	if err != nil {
+		return false, err
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
+	if err != nil {
+		return false, err
+	}
+
+	result, _, err := program.ContextEval(ctx, map[string]interface{}{"object": object})
+	if err != nil {
+		return false, fmt.Errorf("%w %q: %w", errNotEvaluated, expression, err)
+	}
+	value, ok := result.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q evaluated to %v, not bool", expression, result.Value())
+	}
+
+	return value, nil
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Labels: map[string]string{"app": "test"}},
+		Status:     v2.PodStatus{Phase: v2.PodPending},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:       "True expression",
+			expression: "object.status.phase == 'Pending' && object.metadata.labels.app == 'test'",
+			want:       true,
+		},
+		{
+			name:       "False expression",
+			expression: "object.status.phase == 'Running'",
+		},
+		{
+			name:       "Absent field tested with has",
+			expression: "!has(object.metadata.deletionTimestamp)",
+			want:       true,
+		},
+		{
+			name:       "Absent field",
+			expression: "object.metadata.deletionTimestamp != null",
+			wantErr:    true,
+		},
+		{
+			name:       "Syntax error",
+			expression: "object.status.phase ==",
+			wantErr:    true,
+		},
+		{
+			name:       "Not a boolean",
+			expression: "object.metadata.name",
+			wantErr:    true,
+		},
+		{
+			name:       "Undeclared variable",
+			expression: "pod.status.phase == 'Pending'",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluateExpression(context.Background(), tt.expression, pod)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompileExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    bool
+	}{
+		{
+			name:       "Boolean expression",
+			expression: "object.status.phase == 'Pending'",
+		},
+		{
+			name:       "Field compared to a boolean",
+			expression: "object.spec.suspend == true",
+		},
+		{
+			name:       "Field of unknown type",
+			expression: "object.spec.suspend",
+			wantErr:    true,
+		},
+		{
+			name:       "String",
+			expression: "object.metadata.name",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := compileExpression(tt.expression)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			// the compiled program is reused
+			again, err := compileExpression(tt.expression)
+			assert.Nil(t, err)
+			assert.Same(t, program, again)
+		})
+	}
+}
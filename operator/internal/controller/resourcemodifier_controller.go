@@ -19,16 +19,23 @@ package controller
 import (
 	"context"
 	errs "errors"
+	"fmt"
 	v1 "k8s.io/api/apps/v1"
 	v3 "k8s.io/api/batch/v1"
 	v2 "k8s.io/api/core/v1"
-	v1beta2 "k8s.io/api/networking/v1beta1"
+	networking "k8s.io/api/networking/v1"
 	rbac "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"strings"
+	"time"
 
 	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
 )
@@ -36,107 +43,731 @@ import (
 const (
 	// resourceNotFound is an error message indicating that specified resource was not found
 	resourceNotFound = "No matches found for specified resource: "
+
+	// invalidAnnotation is an error message indicating that annotation is malformed
+	invalidAnnotation = "Invalid annotation "
 )
 
 // ResourceModifierReconciler reconciles a ResourceModifier object
 type ResourceModifierReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// APIReader reads from the API server directly, bypassing the cache, for one-off lookups of resources, which
+	// should not start informers, e.g. owners of arbitrary kinds. Default - the Client.
+	APIReader client.Reader
+
+	// OperationTimeout limits a single request to the API server. Default - DefaultOperationTimeout.
+	OperationTimeout time.Duration
+
+	// ActionTimeout limits a single action, including waiting. Default - DefaultActionTimeout.
+	ActionTimeout time.Duration
+
+	// TargetLocks enables locking of resources with Leases, while actions are applied to them.
+	TargetLocks bool
+
+	// LockNamespace is a namespace of Leases locking cluster-scoped resources. Default - DefaultLockNamespace.
+	LockNamespace string
+
+	// LockDuration is how long a Lease locks a resource without being renewed. Leases are renewed every third of it,
+	// while actions run. Default - DefaultLockDuration, at least MinLockDuration.
+	LockDuration time.Duration
+
+	// LockIdentity identifies this replica of the controller as the holder of Leases, e.g. the name of its pod.
+	// It must differ between replicas. Default - NewLockIdentity.
+	LockIdentity string
+
+	// requestTimeout limits a single request to the API server for the reconciled ResourceModifier, set by
+	// reconcilerFor. Default - OperationTimeout.
+	requestTimeout time.Duration
+
+	// targetWatches keeps track of resource kinds, which are watched for ResourceModifiers in Enforce mode
+	targetWatches *targetWatches
 }
 
 // +kubebuilder:rbac:groups=annot-resource-modif.ericsson.com,resources=resourcemodifiers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=annot-resource-modif.ericsson.com,resources=resourcemodifiers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=annot-resource-modif.ericsson.com,resources=resourcemodifiers/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=pods;services,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets;replicasets,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=annot-resource-modif.ericsson.com,resources=maintenancewindows,verbs=get;list;watch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
+//
+// ResourceModifier is executed once per generation. When execution finishes, its phase, generation and value of
+// the RerunAnnotation are recorded in the status, and further reconciliations are no-ops, until the spec
+// (e.g. spec.runID) or the RerunAnnotation is changed.
+// In Enforce mode, the resource is watched, and ResourceModifier is executed again on every change of the resource.
+// If the execution modified the resource, the drift is recorded in the status, and reported as an Event.
+// In Selector mode, resources matching the labels are watched, and ResourceModifier is executed once on each of them.
+// With Rollout, they are modified in batches, paused between, and the rollout is aborted, when too many fail.
+// With RevertOnDelete, a finalizer is added to ResourceModifier, and the changes are reverted upon its deletion.
+// With Duration, the changes are reverted when the duration passes, and ResourceModifier is marked Expired.
+// With Schedule or ExecuteAt, ResourceModifier is executed at the scheduled times, rather than once per generation.
+// With DryRun, ResourceModifier is executed once in the API server's dry-run mode, and the diff is recorded instead.
+// With DependsOn, execution waits until the listed ResourceModifiers succeed.
+// With HealthCheck, the modified resource must become healthy in time, otherwise the changes may be rolled back.
+// The health is checked by following reconciliations, rather than waited for.
+// With Hooks, Jobs are run before and after the actions, and the execution is resumed, when they finish.
+// With a maintenance window, execution outside of the window is postponed until the window opens.
+// With When expressions, execution is postponed until the resource satisfies them.
+// All requests to the API server are derived from ctx, so they are cancelled when the controller shuts down.
+// With TargetLocks, the resource is locked while actions are applied, and execution on a resource locked by another
+// ResourceModifier is postponed with the Locked condition, without counting as a failed attempt.
+// Failed execution is retried with a backoff according to the RetryPolicy, until it succeeds or the attempts are
+// exhausted, and ResourceModifier is marked Failed.
 func (r *ResourceModifierReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	var resourceModifier annotresourcemodifv1.ResourceModifier
-	var err error
-	if err = r.Get(ctx, req.NamespacedName, &resourceModifier); err != nil {
+	if err := r.Get(ctx, req.NamespacedName, &resourceModifier); err != nil {
 		log.Error(err, "unable to fetch resourceModifier")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// requests to the API server are cancelled on shutdown, and limited by the operation timeout
+	r = r.reconcilerFor(&resourceModifier)
 
-	resource, err := r.determineResourceType(resourceModifier.Spec.ResourceData)
-	if err != nil {
-		log.Error(err, "Error determining resource type. Wrong resource type specified")
-		updateErr := r.updateErrorStatus(resourceModifier, err.Error())
-		if updateErr != nil {
-			log.Error(updateErr, "Error Updating Resource's Status")
-			return ctrl.Result{}, updateErr
+	if !resourceModifier.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &resourceModifier)
+	}
+
+	rerun := resourceModifier.Annotations[annotresourcemodifv1.RerunAnnotation]
+	completed := resourceModifier.Status.IsCompleted(resourceModifier.Generation, rerun)
+	if completed && resourceModifier.Status.Phase != annotresourcemodifv1.PhaseExpired && isExpired(&resourceModifier) {
+		if err := r.expire(ctx, &resourceModifier); err != nil {
+			log.Error(err, "Error reverting expired modification")
+			return ctrl.Result{}, err
 		}
-		return ctrl.Result{}, err
+		return ctrl.Result{RequeueAfter: untilNextRun(&resourceModifier)}, nil
 	}
 
-	objectKey, err := r.determineResourceSelector(resourceModifier.Spec.ResourceData)
+	previous := *resourceModifier.Status.DeepCopy()
+	if isScheduled(&resourceModifier) {
+		due, err := r.dueRun(&resourceModifier, time.Now())
+		if err != nil {
+			log.Error(err, "Error determining scheduled execution")
+			resourceModifier.Status.ErrorStatus(err.Error())
+			updateErr := r.updateCompletedStatus(ctx, &resourceModifier, annotresourcemodifv1.PhaseFailed, rerun)
+			if updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+
+		// changing the rerun annotation executes scheduled ResourceModifier immediately, failed execution is retried
+		manual := rerun != resourceModifier.Status.ObservedRerun
+		retrying := resourceModifier.Status.NextRetryTime != nil
+		resuming := resourceModifier.Status.HookStage != "" || verifyingHealth(&resourceModifier)
+		if due == nil && !manual && !retrying && !resuming {
+			requeueAfter, err := r.waitForSchedule(ctx, &resourceModifier, previous)
+			if err != nil {
+				log.Error(err, "Error Updating Resource's Status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: earliest(requeueAfter, untilExpiration(&resourceModifier))}, nil
+		}
+		completed = false
+	}
+
+	if completed && resourceModifier.Status.Phase == annotresourcemodifv1.PhaseExpired {
+		return ctrl.Result{}, nil
+	}
+	if completed && isRolloutAborted(&resourceModifier) {
+		return ctrl.Result{}, nil
+	}
+
+	dryRun := resourceModifier.Spec.DryRun
+	continuous := !dryRun && (resourceModifier.Spec.Mode == annotresourcemodifv1.EnforceMode ||
+		resourceModifier.Spec.Mode == annotresourcemodifv1.SelectorMode)
+	if completed && !continuous {
+		return ctrl.Result{RequeueAfter: untilExpiration(&resourceModifier)}, nil
+	}
+
+	waiting, err := r.waitForDependencies(ctx, &resourceModifier, previous)
 	if err != nil {
-		log.Error(err, "Error determining selector. Make sure that either Name or Labels are specified")
-		updateErr := r.updateErrorStatus(resourceModifier, err.Error())
-		if updateErr != nil {
-			log.Error(updateErr, "Error Updating Resource's Status")
+		log.Error(err, "Error checking dependencies")
+		if resourceModifier.Status.Conditions == nil {
+			r.initResourceModifierStatus(&resourceModifier)
+		}
+		if updateErr := r.updateErrorStatus(ctx, &resourceModifier, err.Error()); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
 		return ctrl.Result{}, err
 	}
+	if waiting {
+		return ctrl.Result{RequeueAfter: untilExpiration(&resourceModifier)}, nil
+	}
 
-	err = r.Client.Get(ctx, objectKey, resource)
+	waiting, untilWindow, err := r.waitForWindow(ctx, &resourceModifier, previous, time.Now())
 	if err != nil {
-		log.Error(err, "Error while trying to get an object")
-		updateErr := r.updateErrorStatus(resourceModifier, err.Error())
-		if updateErr != nil {
-			log.Error(updateErr, "Error Updating Resource's Status")
+		log.Error(err, "Error checking maintenance window")
+		if resourceModifier.Status.Conditions == nil {
+			r.initResourceModifierStatus(&resourceModifier)
+		}
+		if updateErr := r.updateErrorStatus(ctx, &resourceModifier, err.Error()); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
 		return ctrl.Result{}, err
 	}
+	if waiting {
+		return ctrl.Result{RequeueAfter: earliest(untilWindow, untilExpiration(&resourceModifier))}, nil
+	}
 
-	for _, annotation := range resourceModifier.Spec.Annotations {
-		err = r.executeAnnotation(annotation, resource, resourceModifier)
+	if continuous {
+		if err := r.watchTarget(resourceModifier.Spec.ResourceData); err != nil {
+			log.Error(err, "Error watching the resource")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// an execution, whose hooks are running, or whose health is verified, already passed its preconditions
+	resuming := resourceModifier.Status.HookStage != "" || verifyingHealth(&resourceModifier)
+	if !resuming {
+		waiting, err = r.waitForPreconditions(ctx, &resourceModifier, previous)
 		if err != nil {
-			updateErr := r.updateErrorStatus(resourceModifier, err.Error())
+			log.Error(err, "Error checking preconditions")
+			return ctrl.Result{}, err
+		}
+		if waiting {
+			return ctrl.Result{RequeueAfter: earliest(preconditionRecheckInterval, untilExpiration(&resourceModifier))}, nil
+		}
+	}
+
+	if resourceModifier.Spec.RevertOnDelete && !dryRun &&
+		controllerutil.AddFinalizer(&resourceModifier, annotresourcemodifv1.RevertFinalizer) {
+		if err := r.Update(ctx, &resourceModifier); err != nil {
+			log.Error(err, "Error adding finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if resourceModifier.Status.Conditions == nil {
+		r.initResourceModifierStatus(&resourceModifier)
+	}
+	// an execution, whose hooks are running, or whose health is verified, is resumed rather than started over
+	if !resuming {
+		resourceModifier.Status.AppliedPatches = nil
+		resourceModifier.Status.DryRunDiff = ""
+		resourceModifier.Status.Attempts = 0
+		resourceModifier.Status.Hooks = nil
+		resourceModifier.Status.Health = nil
+		if resourceModifier.Status.NextRetryTime == nil {
+			resourceModifier.Status.FailedAttempts = 0
+			if !completed {
+				resourceModifier.Status.Rollout = nil
+			}
+		}
+		delete(resourceModifier.Status.Conditions, annotresourcemodifv1.StatusFailed)
+		if !completed && !dryRun {
+			setExpirationTime(&resourceModifier)
+		}
+	}
+	// a resumed execution may wait for the lock as well, e.g. to roll back an unhealthy resource
+	resourceModifier.Status.NextRetryTime = nil
+	delete(resourceModifier.Status.Conditions, annotresourcemodifv1.StatusLocked)
+
+	phase := annotresourcemodifv1.PhaseSucceeded
+	var drift client.Object
+	var changed bool
+	if dryRun {
+		err = r.executeDryRun(ctx, &resourceModifier)
+	} else if resourceModifier.Spec.Mode == annotresourcemodifv1.SelectorMode {
+		changed, err = r.executeSelector(ctx, &resourceModifier)
+	} else if !continuous && (hasHooks(&resourceModifier) || resourceModifier.Spec.HealthCheck != nil) {
+		var wait time.Duration
+		wait, err = r.executeWithHooks(ctx, &resourceModifier)
+		if err == nil && wait > 0 {
+			updateCtx, cancel := r.operationContext(ctx)
+			updateErr := r.Status().Update(updateCtx, &resourceModifier)
+			cancel()
 			if updateErr != nil {
 				log.Error(updateErr, "Error Updating Resource's Status")
 				return ctrl.Result{}, updateErr
 			}
-			return ctrl.Result{}, err
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	} else {
+		drift, err = r.execute(ctx, &resourceModifier)
+		changed = drift != nil
+	}
+	if err != nil {
+		log.Error(err, "Error executing ResourceModifier")
+		var retryAfter time.Duration
+		switch {
+		case errs.Is(err, errLocked):
+			retryAfter = postpone(&resourceModifier, annotresourcemodifv1.StatusLocked, err,
+				lockRecheckInterval, time.Now())
+		case errs.Is(err, errPreconditionNotMet):
+			// the resource changed since the preconditions were checked
+			retryAfter = postpone(&resourceModifier, annotresourcemodifv1.StatusPreconditionNotMet, err,
+				preconditionRecheckInterval, time.Now())
+		}
+		if retryAfter > 0 {
+			if updateErr := r.updateErrorStatus(ctx, &resourceModifier, err.Error()); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{RequeueAfter: earliest(retryAfter, untilExpiration(&resourceModifier))}, nil
+		}
+		if retryAfter, retry := r.nextRetry(&resourceModifier, err, time.Now()); retry {
+			if updateErr := r.updateErrorStatus(ctx, &resourceModifier, err.Error()); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+		phase = annotresourcemodifv1.PhaseFailed
+	}
+	if completed && !changed && err == nil {
+		return ctrl.Result{RequeueAfter: earliest(untilExpiration(&resourceModifier), untilNextBatch(&resourceModifier))}, nil
+	}
+	if completed && drift != nil {
+		r.recordDrift(&resourceModifier, drift)
+	}
+
+	if updateErr := r.updateCompletedStatus(ctx, &resourceModifier, phase, rerun); updateErr != nil {
+		log.Error(updateErr, "Error Updating Resource's Status")
+		return ctrl.Result{}, updateErr
+	}
+
+	return ctrl.Result{RequeueAfter: earliest(untilExpiration(&resourceModifier), untilNextRun(&resourceModifier),
+		untilNextBatch(&resourceModifier))}, nil
+}
+
+// execute retrieves the resource specified by ResourceModifier, and applies its annotations and patches.
+// If the resource was modified, its state before the execution is returned.
+// With HealthCheck, the verification of health of the modified resource is started, see verifyHealth.
+// The resource is locked while the actions are applied, if TargetLocks are enabled.
+func (r *ResourceModifierReconciler) execute(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) (client.Object, error) {
+	resource, err := r.findTarget(ctx, rm.Spec.ResourceData)
+	if err != nil {
+		return nil, err
+	}
+
+	var original client.Object
+	err = r.withTargetLock(ctx, rm, resource, func() error {
+		original, err = r.apply(ctx, resource, rm)
+		return err
+	})
+	if err != nil || original == nil || rm.Spec.HealthCheck == nil {
+		return original, err
+	}
+
+	return original, r.startHealthCheck(rm, original, resource)
+}
+
+// apply applies annotations and patches of ResourceModifier to the resource, writing it once.
+// If the resource was modified, its state before the execution is returned.
+// With RevertOnDelete or Duration, the changes are recorded, even if the execution failed half-way.
+func (r *ResourceModifierReconciler) apply(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) (client.Object, error) {
+	original, err := r.applyBatch(ctx, r.Client, resource, rm)
+	if err != nil && recordsChanges(rm) {
+		// in-memory state of the resource may contain changes, which were not persisted
+		resetObject(resource)
+		getCtx, cancel := r.operationContext(ctx)
+		getErr := r.Client.Get(getCtx, client.ObjectKeyFromObject(original), resource)
+		cancel()
+		if getErr != nil {
+			return nil, err
+		}
+	}
+
+	if resource.GetResourceVersion() == original.GetResourceVersion() {
+		return nil, err
+	}
+	if recordsChanges(rm) {
+		if recordErr := r.recordChanges(rm, original, resource); recordErr != nil && err == nil {
+			err = fmt.Errorf("failed to record changes: %w", recordErr)
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
+	return original, nil
+}
+
+// applyActions executes annotations and patches of ResourceModifier on the resource, stopping at the first error.
+// Every annotation, and the patches together, are limited by the action timeout.
+func (r *ResourceModifierReconciler) applyActions(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) error {
+	timeout := r.actionTimeout(rm)
+	for _, annotation := range rm.Spec.Annotations {
+		actionCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.executeAnnotation(actionCtx, annotation, resource, rm)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	actionCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	return ctrl.Result{}, nil
+	return r.executePatches(actionCtx, resource, rm)
+}
+
+// finalize reverts the changes made by ResourceModifier, if RevertOnDelete is enabled, and removes its finalizer.
+func (r *ResourceModifierReconciler) finalize(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier) error {
+	if !controllerutil.ContainsFinalizer(rm, annotresourcemodifv1.RevertFinalizer) {
+		return nil
+	}
+
+	if rm.Spec.RevertOnDelete {
+		if err := r.revert(ctx, rm); err != nil {
+			log.FromContext(ctx).Error(err, "Error reverting changes")
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(rm, annotresourcemodifv1.RevertFinalizer)
+	return r.Update(ctx, rm)
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// Status updates do not change the generation, so they do not trigger reconciliation. Changes of
+// annotations do, so that the RerunAnnotation is observed.
+// Changes of hook Jobs trigger reconciliation of ResourceModifiers, which own them.
+// Finished executions of ResourceModifiers trigger reconciliation of ResourceModifiers, which depend on them.
+// Resources targeted by ResourceModifiers in Enforce and Selector modes are watched as well, but the watches are
+// added at runtime, when such ResourceModifier is reconciled for the first time.
 func (r *ResourceModifierReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&annotresourcemodifv1.ResourceModifier{}).
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&annotresourcemodifv1.ResourceModifier{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Owns(&v3.Job{}).
+		Watches(&annotresourcemodifv1.ResourceModifier{}, handler.EnqueueRequestsFromMapFunc(r.mapDependencyToDependents),
+			builder.WithPredicates(phaseChangedPredicate)).
 		Named("resourcemodifier").
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	r.targetWatches = newTargetWatches(c, mgr.GetCache())
+	return nil
+}
+
+// annotationFunc performs the action of a parsed annotation on the resource.
+// The context is cancelled, when the action times out, or the controller shuts down.
+type annotationFunc func(ctx context.Context, resource client.Object,
+	rm *annotresourcemodifv1.ResourceModifier) error
+
+// action is a parsed annotation: the function performing it, and the fields of the resource it changes.
+type action struct {
+	execute annotationFunc
+	effects []Effect
+}
+
+// actionParser parses arguments of the annotation into the action.
+type actionParser func(r *ResourceModifierReconciler, annotation string, args []string) (action, error)
+
+// actions is the registry of annotations by their name. Every parser describes the effects of the action next to
+// the function performing it, so that they can not diverge.
+var actions = map[string]actionParser{
+	"removeAnyFinalizers": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		return action{
+			execute: r.executeRemoveAnyFinalizerAnnotation,
+			effects: []Effect{removeEffect("/metadata/finalizers")},
+		}, nil
+	},
+	"deleteResource": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		return action{execute: r.executeDeleteResource, effects: []Effect{removeEffect("")}}, nil
+	},
+	"addFinalizer": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 1); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeAddFinalizer(ctx, resource, rm, args[0])
+			},
+			effects: []Effect{setEffect(pointer("metadata", "finalizers", args[0]), true)},
+		}, nil
+	},
+	"executeAddLabel": addLabelAction,
+	"addLabel":        addLabelAction,
+	"removeLabel": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 1); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveLabel(ctx, resource, rm, args[0])
+			},
+			effects: []Effect{removeEffect(pointer("metadata", "labels", args[0]))},
+		}, nil
+	},
+	"toleration":    addTolerationAction,
+	"addToleration": addTolerationAction,
+	"removeToleration": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		toleration, err := parseToleration(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveToleration(ctx, resource, rm, toleration)
+			},
+			effects: []Effect{removeEffect(podSpecPointer("tolerations", args[0]))},
+		}, nil
+	},
+	"addAffinity": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		affinity, err := parseAffinity(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeAddAffinity(ctx, resource, rm, affinity)
+			},
+			effects: []Effect{setEffect(podSpecPointer("affinity", args[0], args[1]), true)},
+		}, nil
+	},
+	"removeAffinity": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 2); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveAffinity(ctx, resource, rm, args[0], args[1])
+			},
+			effects: []Effect{removeEffect(podSpecPointer("affinity", args[0], args[1]))},
+		}, nil
+	},
+	"addOwnerReference": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		request, err := parseOwnerReference(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeAddOwnerReference(ctx, resource, rm, request)
+			},
+			effects: []Effect{setEffect(pointer("metadata", "ownerReferences", args[0], args[1]), true)},
+		}, nil
+	},
+	"removeOwnerReference": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 2); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveOwnerReference(ctx, resource, rm, args[0], args[1])
+			},
+			effects: []Effect{removeEffect(pointer("metadata", "ownerReferences", args[0], args[1]))},
+		}, nil
+	},
+	"setServiceType": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		serviceType, externalName, err := parseServiceType(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		effects := []Effect{setEffect("/spec/type", serviceType)}
+		if externalName != "" {
+			effects = append(effects, setEffect("/spec/externalName", externalName))
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeSetServiceType(ctx, resource, rm, serviceType, externalName)
+			},
+			effects: effects,
+		}, nil
+	},
+	"addServicePort": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		port, err := parseServicePort(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeAddServicePort(ctx, resource, rm, port)
+			},
+			effects: []Effect{setEffect(pointer("spec", "ports", port.Name), port)},
+		}, nil
+	},
+	"removeServicePort": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 1); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveServicePort(ctx, resource, rm, args[0])
+			},
+			effects: []Effect{removeEffect(pointer("spec", "ports", args[0]))},
+		}, nil
+	},
+	"setServiceSelector": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 2); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeSetServiceSelector(ctx, resource, rm, args[0], args[1])
+			},
+			effects: []Effect{setEffect(pointer("spec", "selector", args[0]), args[1])},
+		}, nil
+	},
+	"removeServiceSelector": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 1); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveServiceSelector(ctx, resource, rm, args[0])
+			},
+			effects: []Effect{removeEffect(pointer("spec", "selector", args[0]))},
+		}, nil
+	},
+	"setExternalTrafficPolicy": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		policy, err := parseExternalTrafficPolicy(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeSetExternalTrafficPolicy(ctx, resource, rm, policy)
+			},
+			effects: []Effect{setEffect("/spec/externalTrafficPolicy", args[0])},
+		}, nil
+	},
+	"setIngressHost": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 1); err != nil {
+			return action{}, err
+		}
+		if err := validateHost(annotation, args[0]); err != nil {
+			return action{}, err
+		}
+		oldHost := ""
+		if len(args) > 1 {
+			oldHost = args[1]
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeSetIngressHost(ctx, resource, rm, args[0], oldHost)
+			},
+			effects: []Effect{setEffect(pointer("spec", "rules", hostOrAny(oldHost), "host"), args[0])},
+		}, nil
+	},
+	"setIngressTLSSecret": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 1); err != nil {
+			return action{}, err
+		}
+		host := ""
+		if len(args) > 1 {
+			host = args[1]
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeSetIngressTLSSecret(ctx, resource, rm, args[0], host)
+			},
+			effects: []Effect{setEffect(pointer("spec", "tls", hostOrAny(host), "secretName"), args[0])},
+		}, nil
+	},
+	"addIngressPath": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		rule, err := parseIngressPath(annotation, args)
+		if err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeAddIngressPath(ctx, resource, rm, rule)
+			},
+			effects: []Effect{setEffect(pointer("spec", "rules", rule.host, "paths", rule.path.Path), rule.path)},
+		}, nil
+	},
+	"removeIngressPath": func(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+		if err := requireArgs(annotation, args, 2); err != nil {
+			return action{}, err
+		}
+		return action{
+			execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+				return r.executeRemoveIngressPath(ctx, resource, rm, args[0], args[1])
+			},
+			effects: []Effect{removeEffect(pointer("spec", "rules", args[0], "paths", args[1]))},
+		}, nil
+	},
+}
+
+// addLabelAction parses the executeAddLabel annotation, also known as addLabel.
+func addLabelAction(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+	if err := requireArgs(annotation, args, 2); err != nil {
+		return action{}, err
+	}
+	return action{
+		execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+			return r.executeAddLabel(ctx, resource, rm, args[0]+":"+args[1])
+		},
+		effects: []Effect{setEffect(pointer("metadata", "labels", args[0]), args[1])},
+	}, nil
+}
+
+// addTolerationAction parses the addToleration annotation, also known as toleration.
+func addTolerationAction(r *ResourceModifierReconciler, annotation string, args []string) (action, error) {
+	toleration, err := parseToleration(annotation, args)
+	if err != nil {
+		return action{}, err
+	}
+	return action{
+		execute: func(ctx context.Context, resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+			return r.executeAddToleration(ctx, resource, rm, toleration)
+		},
+		effects: []Effect{setEffect(podSpecPointer("tolerations", args[0]), true)},
+	}, nil
 }
 
 // executeAnnotation
 //
 // This function observes the given annotation, and performs provided action on the resource.
-func (r *ResourceModifierReconciler) executeAnnotation(annotation string, resource client.Object,
-	rm annotresourcemodifv1.ResourceModifier) error {
-	switch {
-	case contains(annotation, "removeAnyFinalizers"):
-		return r.executeRemoveAnyFinalizerAnnotation(resource, rm)
-	case contains(annotation, "addFinalizer"):
-		// TODO: check this in validator
-		finalizer := strings.Split(annotation, ":")[1]
-		return r.executeAddFinalizer(resource, rm, finalizer)
-	case contains(annotation, "executeAddLabel"):
-		// TODO: check this in validator
-		label := strings.Split(annotation, ":")
-		return r.executeAddLabel(resource, rm, label[1]+label[2])
+func (r *ResourceModifierReconciler) executeAnnotation(ctx context.Context, annotation string,
+	resource client.Object, rm *annotresourcemodifv1.ResourceModifier) error {
+	parsed, err := r.parseAnnotation(annotation)
+	if err != nil {
+		return err
+	}
+
+	return parsed.execute(ctx, resource, rm)
+}
+
+// ValidateAnnotation checks that the annotation is known, and that its arguments are well-formed.
+// The annotation is not executed.
+func ValidateAnnotation(annotation string) error {
+	_, err := (&ResourceModifierReconciler{}).parseAnnotation(annotation)
+	return err
+}
+
+// ValidateAnnotationFor checks the annotation like ValidateAnnotation, and that the action is supported by
+// the type of the resource, e.g. affinity of a Pod can not be changed.
+func ValidateAnnotationFor(annotation, resourceType string) error {
+	if err := ValidateAnnotation(annotation); err != nil {
+		return err
+	}
+
+	name, _ := splitAnnotation(annotation)
+	if _, ok := templateOnlyActions[name]; ok && strings.EqualFold(resourceType, "pod") {
+		return fmt.Errorf("%s%s: %s", invalidAnnotation, annotation, immutablePodSpec)
 	}
 
 	return nil
 }
 
+// parseAnnotation parses the annotation and its arguments with the parser registered in actions.
+func (r *ResourceModifierReconciler) parseAnnotation(annotation string) (action, error) {
+	name, args := splitAnnotation(annotation)
+	parse, ok := actions[name]
+	if !ok {
+		return action{}, fmt.Errorf("%s%s: unknown action %s", invalidAnnotation, annotation, name)
+	}
+
+	return parse(r, annotation, args)
+}
+
 // determineResourceType analyzes resourceData from the arguments, and returns the object which was specified
 // in resourceData.ResourceType field.
 // If no matches were found, it returns an empty object, and an resourceNotFound error.
@@ -148,6 +779,14 @@ func (r *ResourceModifierReconciler) determineResourceType(resourceData annotres
 		return &v1.Deployment{}, nil
 	case "node":
 		return &v2.Node{}, nil
+	case "statefulset":
+		return &v1.StatefulSet{}, nil
+	case "daemonset":
+		return &v1.DaemonSet{}, nil
+	case "replicaset":
+		return &v1.ReplicaSet{}, nil
+	case "job":
+		return &v3.Job{}, nil
 	case "cronjob":
 		return &v3.CronJob{}, nil
 	case "pv":
@@ -157,7 +796,7 @@ func (r *ResourceModifierReconciler) determineResourceType(resourceData annotres
 	case "service":
 		return &v2.Service{}, nil
 	case "ingress":
-		return &v1beta2.Ingress{}, nil
+		return &networking.Ingress{}, nil
 	case "role":
 		return &rbac.Role{}, nil
 	case "rb":
@@ -173,11 +812,8 @@ func (r *ResourceModifierReconciler) determineResourceType(resourceData annotres
 
 // determineResourceSelector
 // This function observes provided resourceData, and constructs an ObjectKey, to retrieve a resource.
+// Resources specified by labels are retrieved with listTargets instead.
 func (r *ResourceModifierReconciler) determineResourceSelector(resourceData annotresourcemodifv1.TargetResourceData) (client.ObjectKey, error) {
-	if resourceData.Labels != nil {
-		// TODO: implement listing by labels
-	}
-
 	objectKey := client.ObjectKey{}
 
 	if resourceData.Name != "" {
@@ -191,7 +827,16 @@ func (r *ResourceModifierReconciler) determineResourceSelector(resourceData anno
 	return objectKey, nil
 }
 
-// contains is a wrapper for strings.Contains
-func contains(a, b string) bool {
-	return strings.Contains(a, b)
+// splitAnnotation splits annotation into the name of the action, and it's colon-separated arguments.
+func splitAnnotation(annotation string) (string, []string) {
+	parts := strings.Split(annotation, ":")
+	return parts[0], parts[1:]
+}
+
+// requireArgs returns an error, if annotation was provided with less than n arguments.
+func requireArgs(annotation string, args []string, n int) error {
+	if len(args) < n {
+		return fmt.Errorf("%s%s: expected at least %d arguments, got %d", invalidAnnotation, annotation, n, len(args))
+	}
+	return nil
 }
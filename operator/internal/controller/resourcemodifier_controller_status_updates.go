@@ -3,20 +3,21 @@ package controller
 import (
 	"context"
 	v1 "ericsson.com/resource-modif-annotations/api/v1"
-	"time"
+	v2 "k8s.io/api/core/v1"
 )
 
-func (r *ResourceModifierReconciler) initResourceModifierStatus(resource v1.ResourceModifier) {
+func (r *ResourceModifierReconciler) initResourceModifierStatus(resource *v1.ResourceModifier) {
 	resource.Status.Conditions = make(map[string]string)
 }
 
 // updateErrorStatus updates resource's Conditions with appropriate message. If an error were returned, returns it.
-func (r *ResourceModifierReconciler) updateErrorStatus(resource v1.ResourceModifier, reason string) error {
+func (r *ResourceModifierReconciler) updateErrorStatus(ctx context.Context, resource *v1.ResourceModifier,
+	reason string) error {
 	resource.Status.ErrorStatus(reason)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := r.operationContext(ctx)
 	defer cancel()
 
-	err := r.Client.Update(ctx, &resource)
+	err := r.Client.Status().Update(ctx, resource)
 	if err != nil {
 		return err
 	}
@@ -26,15 +27,52 @@ func (r *ResourceModifierReconciler) updateErrorStatus(resource v1.ResourceModif
 
 // updateStatusSuccess updates resource's Conditions by adding new Successful status, and removing any previously added
 // error statuses (if applicable).
-func (r *ResourceModifierReconciler) updateStatusSuccess(resource v1.ResourceModifier, reason string) error {
+func (r *ResourceModifierReconciler) updateStatusSuccess(ctx context.Context, resource *v1.ResourceModifier,
+	reason string) error {
 	resource.Status.SuccessfulStatus(reason)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	updateCtx, cancel := r.operationContext(ctx)
 	defer cancel()
 
-	err := r.Client.Update(ctx, &resource)
+	err := r.Client.Status().Update(updateCtx, resource)
 	if err != nil {
-		return r.updateErrorStatus(resource, err.Error())
+		return r.updateErrorStatus(ctx, resource, err.Error())
 	}
 
 	return nil
 }
+
+// updateCompletedStatus records that the current generation of ResourceModifier was executed, and finished in
+// given phase, so it is not executed again, until its spec or rerun annotation changes.
+func (r *ResourceModifierReconciler) updateCompletedStatus(ctx context.Context, resource *v1.ResourceModifier,
+	phase v1.Phase, rerun string) error {
+	resource.Status.Completed(phase, resource.Generation, rerun)
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	return r.Client.Status().Update(ctx, resource)
+}
+
+// updateWaitingStatus resets the status of ResourceModifier to previous, so a due scheduled execution is postponed
+// rather than consumed, and records the message in given condition. The status is updated, and an Event is emitted,
+// only when the message changed.
+func (r *ResourceModifierReconciler) updateWaitingStatus(ctx context.Context, resource *v1.ResourceModifier,
+	previous v1.ResourceModifierStatus, condition, reason, message string) error {
+	resource.Status = previous
+	if resource.Status.Conditions == nil {
+		r.initResourceModifierStatus(resource)
+	}
+	if resource.Status.Conditions[condition] == message {
+		return nil
+	}
+	resource.Status.Conditions[condition] = message
+
+	updateCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	if err := r.Client.Status().Update(updateCtx, resource); err != nil {
+		return err
+	}
+	r.Recorder.Event(resource, v2.EventTypeNormal, reason, message)
+
+	return nil
+}
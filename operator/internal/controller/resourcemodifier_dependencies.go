@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"slices"
+	"strings"
+)
+
+const (
+	// reasonWaitingForDependencies is a reason of the Event, which is emitted when execution waits for
+	// ResourceModifiers listed in DependsOn
+	reasonWaitingForDependencies = "WaitingForDependencies"
+)
+
+// waitForDependencies checks, whether all ResourceModifiers listed in DependsOn succeeded with their current
+// generation and rerun annotation. If they did not, the status is reset to previous, so a due scheduled execution is postponed rather
+// than consumed, and the WaitingForDependencies condition is recorded. Dependencies are watched, so ResourceModifier
+// is reconciled again, when any of them finishes.
+func (r *ResourceModifierReconciler) waitForDependencies(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier, previous annotresourcemodifv1.ResourceModifierStatus) (bool, error) {
+	pending, err := r.pendingDependencies(ctx, rm)
+	if err != nil {
+		return false, err
+	}
+	if len(pending) == 0 {
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusWaitingForDependencies)
+		return false, nil
+	}
+
+	message := "Waiting for ResourceModifiers to succeed: " + strings.Join(pending, ", ")
+	err = r.updateWaitingStatus(ctx, rm, previous, annotresourcemodifv1.StatusWaitingForDependencies,
+		reasonWaitingForDependencies, message)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// pendingDependencies returns ResourceModifiers listed in DependsOn, which did not succeed with their current
+// generation and rerun annotation yet. Missing and failed ones are marked as such.
+func (r *ResourceModifierReconciler) pendingDependencies(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) ([]string, error) {
+	var pending []string
+	for _, name := range rm.Spec.DependsOn {
+		var dependency annotresourcemodifv1.ResourceModifier
+		getCtx, cancel := r.operationContext(ctx)
+		err := r.Client.Get(getCtx, client.ObjectKey{Name: name, Namespace: rm.Namespace}, &dependency)
+		cancel()
+
+		// a rerun dependency is pending, until it completes the rerun
+		current := dependency.Status.IsCompleted(dependency.Generation,
+			dependency.Annotations[annotresourcemodifv1.RerunAnnotation])
+		switch {
+		case apierrors.IsNotFound(err):
+			pending = append(pending, name+" (not found)")
+		case err != nil:
+			return nil, fmt.Errorf("failed to get dependency %s: %w", name, err)
+		case current && dependency.Status.Phase == annotresourcemodifv1.PhaseSucceeded:
+		case current && dependency.Status.Phase == annotresourcemodifv1.PhaseFailed:
+			pending = append(pending, name+" (failed)")
+		default:
+			pending = append(pending, name)
+		}
+	}
+
+	return pending, nil
+}
+
+// mapDependencyToDependents returns requests for all ResourceModifiers in the namespace of the dependency, which
+// list it in DependsOn.
+func (r *ResourceModifierReconciler) mapDependencyToDependents(ctx context.Context,
+	dependency client.Object) []reconcile.Request {
+	var list annotresourcemodifv1.ResourceModifierList
+	if err := r.List(ctx, &list, client.InNamespace(dependency.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list resourceModifiers")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, rm := range list.Items {
+		if slices.Contains(rm.Spec.DependsOn, dependency.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&rm)})
+		}
+	}
+
+	return requests
+}
+
+// phaseChangedPredicate passes updates of ResourceModifiers, which finished an execution, so their dependents are
+// reconciled. Creations and deletions are passed as well.
+var phaseChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldRM, ok := e.ObjectOld.(*annotresourcemodifv1.ResourceModifier)
+		if !ok {
+			return false
+		}
+		newRM, ok := e.ObjectNew.(*annotresourcemodifv1.ResourceModifier)
+		if !ok {
+			return false
+		}
+		return oldRM.Status.Phase != newRM.Status.Phase ||
+			oldRM.Status.ObservedGeneration != newRM.Status.ObservedGeneration ||
+			!oldRM.Status.CompletionTime.Equal(newRM.Status.CompletionTime)
+	},
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+func TestResourceModifierReconciler_Reconcile_dependsOn(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+		},
+	}
+	resourceModifier := func(name, label string, dependsOn ...string) *v1.ResourceModifier {
+		return &v1.ResourceModifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "test-ns",
+				Generation: 1,
+			},
+			Spec: v1.ResourceModifierSpec{
+				ResourceData: v1.TargetResourceData{
+					Name:         "test-pod",
+					Namespace:    "test-ns",
+					ResourceType: "pod",
+				},
+				Annotations: []string{"addLabel:" + label + ":true"},
+				DependsOn:   dependsOn,
+			},
+		}
+	}
+	cordon := resourceModifier("cordon", "cordoned")
+	evict := resourceModifier("evict", "evicted", "cordon")
+	unrelated := resourceModifier("unrelated", "unrelated")
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, cordon, evict, unrelated).
+			WithStatusSubresource(cordon, evict, unrelated).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	labelOf := func(key string) string {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+		return got.Labels[key]
+	}
+	reconcileRM := func(rm *v1.ResourceModifier) *v1.ResourceModifier {
+		request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+		_, err := r.Reconcile(ctx, request)
+		assert.Nil(t, err)
+
+		got := &v1.ResourceModifier{}
+		assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+		return got
+	}
+
+	// dependent waits, until its dependency succeeds
+	gotEvict := reconcileRM(evict)
+	assert.Empty(t, gotEvict.Status.Phase)
+	assert.Contains(t, gotEvict.Status.Conditions[v1.StatusWaitingForDependencies], "cordon")
+	assert.Empty(t, labelOf("evicted"))
+
+	gotCordon := reconcileRM(cordon)
+	assert.Equal(t, v1.PhaseSucceeded, gotCordon.Status.Phase)
+	assert.Equal(t, "true", labelOf("cordoned"))
+
+	// finished dependency triggers reconciliation of its dependents
+	assert.Equal(t, []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(evict)}},
+		r.mapDependencyToDependents(ctx, gotCordon))
+	assert.True(t, phaseChangedPredicate.Update(event.UpdateEvent{ObjectOld: cordon, ObjectNew: gotCordon}))
+	assert.False(t, phaseChangedPredicate.Update(event.UpdateEvent{ObjectOld: gotCordon, ObjectNew: gotCordon}))
+
+	gotEvict = reconcileRM(evict)
+	assert.Equal(t, v1.PhaseSucceeded, gotEvict.Status.Phase)
+	assert.NotContains(t, gotEvict.Status.Conditions, v1.StatusWaitingForDependencies)
+	assert.Equal(t, "true", labelOf("evicted"))
+}
+
+func TestResourceModifierReconciler_pendingDependencies(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+
+	dependency := func(name string, generation int64, status v1.ResourceModifierStatus) *v1.ResourceModifier {
+		return &v1.ResourceModifier{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", Generation: generation},
+			Status:     status,
+		}
+	}
+	rerun := dependency("rerun", 1, v1.ResourceModifierStatus{Phase: v1.PhaseSucceeded, ObservedGeneration: 1,
+		ObservedRerun: "1"})
+	rerun.Annotations = map[string]string{v1.RerunAnnotation: "2"}
+	rerunDone := dependency("rerun-done", 1, v1.ResourceModifierStatus{Phase: v1.PhaseSucceeded,
+		ObservedGeneration: 1, ObservedRerun: "2"})
+	rerunDone.Annotations = map[string]string{v1.RerunAnnotation: "2"}
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			dependency("succeeded", 1, v1.ResourceModifierStatus{Phase: v1.PhaseSucceeded, ObservedGeneration: 1}),
+			dependency("failed", 1, v1.ResourceModifierStatus{Phase: v1.PhaseFailed, ObservedGeneration: 1}),
+			dependency("changed", 2, v1.ResourceModifierStatus{Phase: v1.PhaseSucceeded, ObservedGeneration: 1}),
+			dependency("running", 1, v1.ResourceModifierStatus{}),
+			rerun,
+			rerunDone,
+		).Build(),
+		Scheme: scheme,
+	}
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns"},
+		Spec: v1.ResourceModifierSpec{
+			DependsOn: []string{"succeeded", "failed", "changed", "running", "rerun", "rerun-done", "missing"},
+		},
+	}
+
+	pending, err := r.pendingDependencies(context.Background(), rm)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"failed (failed)", "changed", "running", "rerun", "missing (not found)"}, pending)
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	// successDryRun
+	successDryRun = "Successfully executed in dry-run mode"
+
+	// maxDryRunDiffLength limits the size of the diff recorded in the status
+	maxDryRunDiffLength = 32 * 1024
+)
+
+// executeDryRun executes annotations and patches of ResourceModifier in dry-run mode on the resource, or on every
+// resource matching the labels and the When expressions in Selector mode, and records the diff of their states in
+// the status.
+func (r *ResourceModifierReconciler) executeDryRun(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) error {
+	var resources []client.Object
+	if rm.Spec.Mode == annotresourcemodifv1.SelectorMode {
+		var err error
+		if resources, err = r.listTargets(ctx, rm.Spec.ResourceData); err != nil {
+			return err
+		}
+	} else {
+		resource, err := r.findTarget(ctx, rm.Spec.ResourceData)
+		if err != nil {
+			return err
+		}
+		resources = []client.Object{resource}
+	}
+
+	var diff strings.Builder
+	for _, resource := range resources {
+		original, err := r.applyBatch(ctx, client.NewDryRunClient(r.Client), resource, rm)
+		if errors.Is(err, errPreconditionNotMet) && rm.Spec.Mode == annotresourcemodifv1.SelectorMode {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", rm.Spec.ResourceData.ResourceType, client.ObjectKeyFromObject(resource), err)
+		}
+
+		modified := resource
+		if deletesResource(rm) {
+			modified = nil
+		}
+		resourceDiff, err := r.diffResources(original, modified)
+		if err != nil {
+			return err
+		}
+		diff.WriteString(resourceDiff)
+	}
+
+	rm.Status.DryRunDiff = diff.String()
+	if len(rm.Status.DryRunDiff) > maxDryRunDiffLength {
+		rm.Status.DryRunDiff = rm.Status.DryRunDiff[:maxDryRunDiffLength] + "\n... diff truncated\n"
+	}
+	rm.Status.SuccessfulStatus(successDryRun)
+
+	return nil
+}
+
+// deletesResource returns true, if ResourceModifier deletes the resource.
+func deletesResource(rm *annotresourcemodifv1.ResourceModifier) bool {
+	for _, annotation := range rm.Spec.Annotations {
+		if name, _ := splitAnnotation(annotation); name == "deleteResource" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diffResources returns a unified diff of the resource before and after the modification, in YAML.
+// Status and metadata maintained by the API server are left out. A nil modified resource means deletion.
+func (r *ResourceModifierReconciler) diffResources(original, modified client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(original, r.Scheme)
+	if err != nil {
+		return "", err
+	}
+	name := strings.ToLower(gvk.Kind) + "/" + client.ObjectKeyFromObject(original).String()
+
+	before, err := diffableYAML(original)
+	if err != nil {
+		return "", err
+	}
+	after, toFile := "", "/dev/null"
+	if modified != nil {
+		if after, err = diffableYAML(modified); err != nil {
+			return "", err
+		}
+		toFile = "b/" + name
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "a/" + name,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
+
+// diffableYAML encodes the resource into YAML, without status and metadata maintained by the API server.
+func diffableYAML(resource client.Object) (string, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
+	if err != nil {
+		return "", err
+	}
+
+	delete(object, "status")
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		for key := range untrackedMetadata {
+			delete(metadata, key)
+		}
+	}
+
+	encoded, err := yaml.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestResourceModifierReconciler_Reconcile_dryRun(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	tests := []struct {
+		name        string
+		annotations []string
+		wantDiff    []string
+	}{
+		{
+			name:        "Label is not added",
+			annotations: []string{"addLabel:env:prod"},
+			wantDiff: []string{
+				"--- a/pod/test-ns/test-pod\n+++ b/pod/test-ns/test-pod\n",
+				"+    env: prod\n",
+			},
+		},
+		{
+			name:        "Resource is not deleted",
+			annotations: []string{"deleteResource"},
+			wantDiff: []string{
+				"--- a/pod/test-ns/test-pod\n+++ /dev/null\n",
+				"-  name: test-pod\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+					Labels:    map[string]string{"app": "test"},
+				},
+			}
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "rm-test",
+					Namespace:  "test-ns",
+					Generation: 1,
+				},
+				Spec: v1.ResourceModifierSpec{
+					ResourceData: v1.TargetResourceData{
+						Name:         "test-pod",
+						Namespace:    "test-ns",
+						ResourceType: "pod",
+					},
+					Annotations:    tt.annotations,
+					DryRun:         true,
+					RevertOnDelete: true,
+				},
+			}
+
+			r := &ResourceModifierReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			ctx := context.Background()
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+			_, err := r.Reconcile(ctx, request)
+			assert.Nil(t, err)
+
+			got := &v2.Pod{}
+			assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+			assert.Equal(t, map[string]string{"app": "test"}, got.Labels)
+
+			gotRM := &v1.ResourceModifier{}
+			assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+			assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+			assert.Empty(t, gotRM.Finalizers)
+			assert.Empty(t, gotRM.Status.RevertRecords)
+			for _, want := range tt.wantDiff {
+				assert.Contains(t, gotRM.Status.DryRunDiff, want)
+			}
+		})
+	}
+}
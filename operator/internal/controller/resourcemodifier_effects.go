@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"encoding/json"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// anyHost is a path segment standing for all hosts of an Ingress.
+	anyHost = "*"
+
+	// podSpecField is a placeholder of the field of the pod specification, relative to which actions changing it
+	// (e.g. tolerations) register their effects. SpecEffects replaces it with the field for the kind of the target.
+	podSpecField = "/$podSpec"
+)
+
+// podSpecFields maps resource types to the field of their pod specification. Workloads are changed in their pod
+// template.
+var podSpecFields = map[string]string{
+	"pod":         "/spec",
+	"deployment":  "/spec/template/spec",
+	"statefulset": "/spec/template/spec",
+	"daemonset":   "/spec/template/spec",
+	"replicaset":  "/spec/template/spec",
+	"job":         "/spec/template/spec",
+	"cronjob":     "/spec/jobTemplate/spec/template/spec",
+}
+
+// Effect describes how an action changes a single field of the resource. Fields are JSON pointers, e.g.
+// /metadata/labels/env. Items of lists are addressed by their identity rather than by their index, e.g.
+// /metadata/finalizers/example.com~1protect, so that actions and patches on the same item have the same field.
+type Effect struct {
+	// Field is a JSON pointer to the changed field. Empty for the whole resource.
+	Field string
+
+	// Value is the JSON-encoded value which is set, or nil if the field is removed.
+	Value *string
+}
+
+// TargetKey returns a key identifying the resource targeted by name, e.g. "pod/default/web". Cluster-scoped
+// resources are identified regardless of the namespace. Empty, if the resource is selected by labels.
+func TargetKey(resourceData annotresourcemodifv1.TargetResourceData) string {
+	if resourceData.Name == "" {
+		return ""
+	}
+
+	resourceType := strings.ToLower(resourceData.ResourceType)
+	namespace := resourceData.Namespace
+	switch resourceType {
+	case "node", "pv", "clusterrole", "crb":
+		namespace = ""
+	}
+
+	return resourceType + "/" + namespace + "/" + resourceData.Name
+}
+
+// SpecEffects returns the effects of all annotations and patches of the spec. Effects of actions on the pod
+// specification are resolved to the field used by the kind of the target, so they match patches of the same field.
+func SpecEffects(spec annotresourcemodifv1.ResourceModifierSpec) ([]Effect, error) {
+	podSpec, ok := podSpecFields[strings.ToLower(spec.ResourceData.ResourceType)]
+	if !ok {
+		podSpec = "/spec"
+	}
+
+	var effects []Effect
+	for _, annotation := range spec.Annotations {
+		annotationEffects, err := annotationEffects(annotation)
+		if err != nil {
+			return nil, err
+		}
+		for _, effect := range annotationEffects {
+			if isWithin(effect.Field, podSpecField) {
+				effect.Field = podSpec + strings.TrimPrefix(effect.Field, podSpecField)
+			}
+			effects = append(effects, effect)
+		}
+	}
+	for i, patch := range spec.Patches {
+		patchEffects, err := patchEffects(patch)
+		if err != nil {
+			return nil, fmt.Errorf("patch #%d: %w", i, err)
+		}
+		effects = append(effects, patchEffects...)
+	}
+
+	return effects, nil
+}
+
+// ConflictingFields returns fields, which are changed by both lists of effects with contradictory intent: set to
+// different values, or set by one and removed by the other. Removing a field conflicts with setting any field
+// within it, e.g. deleting the resource conflicts with every change of it.
+func ConflictingFields(a, b []Effect) []string {
+	var fields []string
+	for _, x := range a {
+		for _, y := range b {
+			if field, ok := conflict(x, y); ok && !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	slices.Sort(fields)
+
+	return fields
+}
+
+// conflict checks whether two effects contradict each other, and returns the field in question.
+func conflict(x, y Effect) (string, bool) {
+	switch {
+	case x.Field == y.Field:
+		if x.Value == nil || y.Value == nil {
+			return x.Field, x.Value != y.Value
+		}
+		return x.Field, *x.Value != *y.Value
+	case isWithin(y.Field, x.Field):
+		return y.Field, x.Value == nil && y.Value != nil
+	case isWithin(x.Field, y.Field):
+		return x.Field, y.Value == nil && x.Value != nil
+	}
+
+	return "", false
+}
+
+// isWithin returns true, if the field is nested within the parent field.
+func isWithin(field, parent string) bool {
+	return parent == "" || strings.HasPrefix(field, parent+"/")
+}
+
+// annotationEffects returns effects of the annotation, as registered with its parser in actions. Members of lists,
+// e.g. finalizers, are added with the value true, so adding the same member twice is not a conflict.
+func annotationEffects(annotation string) ([]Effect, error) {
+	parsed, err := (&ResourceModifierReconciler{}).parseAnnotation(annotation)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.effects, nil
+}
+
+// hostOrAny returns the host, or anyHost, if the action applies to all hosts.
+func hostOrAny(host string) string {
+	if host == "" {
+		return anyHost
+	}
+	return host
+}
+
+// patchEffects returns effects of the patch. Merge and strategic merge patches set their leaf fields, and remove
+// fields set to null. JSON patches set fields by add and replace operations, and remove them by remove operations.
+// Directives of strategic merge patches are ignored, except for "$patch: delete". Paths of JSON patches addressing
+// items of lists by their index are resolved by indexEffects.
+func patchEffects(patch annotresourcemodifv1.Patch) ([]Effect, error) {
+	var content any
+	if err := json.Unmarshal(patch.Patch.Raw, &content); err != nil {
+		return nil, err
+	}
+
+	if patch.Type != annotresourcemodifv1.JSONPatchType {
+		return objectEffects("", content), nil
+	}
+
+	operations, ok := content.([]any)
+	if !ok {
+		return nil, fmt.Errorf("JSON patch must be a list of operations")
+	}
+	var effects []Effect
+	for _, operation := range operations {
+		op, _ := operation.(map[string]any)
+		path, _ := op["path"].(string)
+		if list, rest, ok := splitAtIndex(path); ok {
+			effects = append(effects, indexEffects(list, rest, op["op"], op["value"])...)
+			continue
+		}
+		switch op["op"] {
+		case "add", "replace":
+			effects = append(effects, objectEffects(path, op["value"])...)
+		case "remove":
+			effects = append(effects, removeEffect(path))
+		}
+	}
+
+	return effects, nil
+}
+
+// splitAtIndex splits the path of a JSON patch at its first segment, which is an index of a list (or "-" for its
+// end), into the field of the list and the path within the item, e.g. /spec/tolerations/0/key into
+// /spec/tolerations and /key. Returns false, if the path does not address an item of a list.
+func splitAtIndex(path string) (string, string, bool) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if i == 0 || segment == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(segment); err == nil || segment == "-" {
+			return strings.Join(segments[:i], "/"), strings.Join(segments[i+1:], "/"), true
+		}
+	}
+
+	return "", "", false
+}
+
+// indexEffects returns effects of a JSON patch operation on an item of the list, addressed by its index. The item
+// at an index is not known in advance, so an item added as a whole is identified by its identity, like items added
+// by actions, and any other change is considered to remove items of the list.
+func indexEffects(list, rest string, op, value any) []Effect {
+	var effects []Effect
+	if op != "add" || rest != "" {
+		effects = append(effects, removeEffect(list))
+	}
+	if (op == "add" || op == "replace") && rest == "" {
+		effects = append(effects, setEffect(list+"/"+escapePointer(itemIdentity(value)), true))
+	}
+
+	return effects
+}
+
+// itemIdentity returns the identity of an item of a list: its name or key, or the item itself for strings,
+// otherwise its content.
+func itemIdentity(item any) string {
+	switch value := item.(type) {
+	case string:
+		return value
+	case map[string]any:
+		for _, key := range []string{"name", "key"} {
+			if identity, ok := value[key].(string); ok {
+				return identity
+			}
+		}
+	}
+
+	return *setEffect("", item).Value
+}
+
+// objectEffects flattens the content of a merge patch at the field into effects.
+func objectEffects(field string, content any) []Effect {
+	switch value := content.(type) {
+	case nil:
+		return []Effect{removeEffect(field)}
+	case map[string]any:
+		if value["$patch"] == "delete" {
+			return []Effect{removeEffect(field)}
+		}
+		var effects []Effect
+		for key, nested := range value {
+			if strings.HasPrefix(key, "$") {
+				continue
+			}
+			effects = append(effects, objectEffects(field+"/"+escapePointer(key), nested)...)
+		}
+		return effects
+	case []any:
+		return listEffects(field, value)
+	}
+
+	return []Effect{setEffect(field, content)}
+}
+
+// listEffects returns effects of a list in a merge patch, which is set as a whole. Items with "$patch: delete" of
+// a strategic merge patch are removed instead, identified by itemIdentity.
+func listEffects(field string, items []any) []Effect {
+	var effects []Effect
+	var kept []any
+	for _, item := range items {
+		member, ok := item.(map[string]any)
+		if !ok || member["$patch"] != "delete" {
+			kept = append(kept, item)
+			continue
+		}
+
+		delete(member, "$patch")
+		effects = append(effects, removeEffect(field+"/"+escapePointer(itemIdentity(member))))
+	}
+	if len(effects) == 0 {
+		return []Effect{setEffect(field, items)}
+	}
+	if len(kept) > 0 {
+		effects = append(effects, setEffect(field, kept))
+	}
+
+	return effects
+}
+
+// setEffect returns an effect, which sets the field to the value.
+func setEffect(field string, value any) Effect {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded = []byte(fmt.Sprint(value))
+	}
+	s := string(encoded)
+
+	return Effect{Field: field, Value: &s}
+}
+
+// removeEffect returns an effect, which removes the field.
+func removeEffect(field string) Effect {
+	return Effect{Field: field}
+}
+
+// podSpecPointer joins path segments within the pod specification into a JSON pointer, see podSpecField.
+func podSpecPointer(segments ...string) string {
+	return podSpecField + pointer(segments...)
+}
+
+// pointer joins path segments into a JSON pointer.
+func pointer(segments ...string) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		b.WriteString("/")
+		b.WriteString(escapePointer(segment))
+	}
+	return b.String()
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"testing"
+)
+
+func TestConflictingFields(t *testing.T) {
+	patch := func(patchType v1.PatchType, raw string) v1.Patch {
+		return v1.Patch{Type: patchType, Patch: apiextensionsv1.JSON{Raw: []byte(raw)}}
+	}
+
+	tests := []struct {
+		name string
+		a    v1.ResourceModifierSpec
+		b    v1.ResourceModifierSpec
+		want []string
+	}{
+		{
+			name: "Label added and removed",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"removeLabel:x"}},
+			want: []string{"/metadata/labels/x"},
+		},
+		{
+			name: "Label set to different values",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:app.kubernetes.io/tier:web"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:app.kubernetes.io/tier:db"}},
+			want: []string{"/metadata/labels/app.kubernetes.io~1tier"},
+		},
+		{
+			name: "Label set to the same value",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+		},
+		{
+			name: "Different labels",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"removeLabel:y"}},
+		},
+		{
+			name: "Label added by annotation, removed by merge patch",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.MergePatchType, `{"metadata":{"labels":{"x":null}}}`),
+			}},
+			want: []string{"/metadata/labels/x"},
+		},
+		{
+			name: "Label set to the same value by JSON patch",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.JSONPatchType, `[{"op":"add","path":"/metadata/labels/x","value":"true"}]`),
+			}},
+		},
+		{
+			name: "Cordon and uncordon",
+			a: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.StrategicMergePatchType, `{"spec":{"unschedulable":true}}`),
+			}},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.JSONPatchType, `[{"op":"replace","path":"/spec/unschedulable","value":false}]`),
+			}},
+			want: []string{"/spec/unschedulable"},
+		},
+		{
+			name: "Finalizer added and all finalizers removed",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addFinalizer:example.com/protect"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"removeAnyFinalizers"}},
+			want: []string{"/metadata/finalizers/example.com~1protect"},
+		},
+		{
+			name: "Resource deleted and modified",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"deleteResource"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true", "removeLabel:y"}},
+			want: []string{"/metadata/labels/x"},
+		},
+		{
+			name: "Resource deleted twice",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"deleteResource"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"deleteResource"}},
+		},
+		{
+			name: "Toleration added and removed",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"addToleration:node.kubernetes.io/unreachable::NoExecute"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"removeToleration:node.kubernetes.io/unreachable"}},
+			want: []string{"/spec/tolerations/node.kubernetes.io~1unreachable"},
+		},
+		{
+			name: "Toleration of Deployment added, and removed by JSON patch by index",
+			a: v1.ResourceModifierSpec{
+				ResourceData: v1.TargetResourceData{ResourceType: "deployment"},
+				Annotations:  []string{"addToleration:dedicated:gpu:NoSchedule"},
+			},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.JSONPatchType, `[{"op":"remove","path":"/spec/template/spec/tolerations/0"}]`),
+			}},
+			want: []string{"/spec/template/spec/tolerations/dedicated"},
+		},
+		{
+			name: "Toleration of Deployment removed, and added by JSON patch",
+			a: v1.ResourceModifierSpec{
+				ResourceData: v1.TargetResourceData{ResourceType: "Deployment"},
+				Annotations:  []string{"removeToleration:dedicated"},
+			},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.JSONPatchType, `[{"op":"add","path":"/spec/template/spec/tolerations/-",`+
+					`"value":{"key":"dedicated","operator":"Exists"}}]`),
+			}},
+			want: []string{"/spec/template/spec/tolerations/dedicated"},
+		},
+		{
+			name: "Same toleration of Deployment added by action and JSON patch",
+			a: v1.ResourceModifierSpec{
+				ResourceData: v1.TargetResourceData{ResourceType: "deployment"},
+				Annotations:  []string{"addToleration:dedicated:gpu:NoSchedule"},
+			},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.JSONPatchType, `[{"op":"add","path":"/spec/template/spec/tolerations/0",`+
+					`"value":{"key":"dedicated","value":"gpu","effect":"NoSchedule"}}]`),
+			}},
+		},
+		{
+			name: "Toleration of CronJob changed by JSON patch by index",
+			a: v1.ResourceModifierSpec{
+				ResourceData: v1.TargetResourceData{ResourceType: "cronjob"},
+				Annotations:  []string{"addToleration:dedicated:gpu:NoSchedule"},
+			},
+			b: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.JSONPatchType, `[{"op":"replace","path":"/spec/jobTemplate/spec/template/spec/tolerations/1/value",`+
+					`"value":"cpu"}]`),
+			}},
+			want: []string{"/spec/jobTemplate/spec/template/spec/tolerations/dedicated"},
+		},
+		{
+			name: "Service type set to different values",
+			a:    v1.ResourceModifierSpec{Annotations: []string{"setServiceType:NodePort"}},
+			b:    v1.ResourceModifierSpec{Annotations: []string{"setServiceType:ClusterIP"}},
+			want: []string{"/spec/type"},
+		},
+		{
+			name: "Strategic merge patch directives are ignored",
+			a: v1.ResourceModifierSpec{Patches: []v1.Patch{
+				patch(v1.StrategicMergePatchType, `{"metadata":{"$retainKeys":["labels"],"labels":{"x":"true"}}}`),
+			}},
+			b: v1.ResourceModifierSpec{Annotations: []string{"addLabel:x:true"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := SpecEffects(tt.a)
+			assert.Nil(t, err)
+			b, err := SpecEffects(tt.b)
+			assert.Nil(t, err)
+
+			assert.Equal(t, tt.want, ConflictingFields(a, b))
+			assert.Equal(t, tt.want, ConflictingFields(b, a))
+		})
+	}
+}
+
+func TestSpecEffects_invalid(t *testing.T) {
+	_, err := SpecEffects(v1.ResourceModifierSpec{Annotations: []string{"addLabel:x"}})
+	assert.NotNil(t, err)
+
+	_, err = SpecEffects(v1.ResourceModifierSpec{Patches: []v1.Patch{
+		{Type: v1.JSONPatchType, Patch: apiextensionsv1.JSON{Raw: []byte(`{"op":"remove"}`)}},
+	}})
+	assert.NotNil(t, err)
+}
+
+func TestTargetKey(t *testing.T) {
+	assert.Equal(t, "pod/test-ns/web", TargetKey(v1.TargetResourceData{ResourceType: "Pod", Namespace: "test-ns", Name: "web"}))
+	assert.Equal(t, "node//worker-1", TargetKey(v1.TargetResourceData{ResourceType: "node", Namespace: "test-ns", Name: "worker-1"}))
+	assert.Empty(t, TargetKey(v1.TargetResourceData{ResourceType: "pod", Namespace: "test-ns", Labels: map[string]string{"app": "web"}}))
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	v2 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sync"
+)
+
+const (
+	// reasonDriftCorrected is a reason of the Event, which is emitted when the drift of the resource was corrected
+	reasonDriftCorrected = "DriftCorrected"
+)
+
+// targetWatches adds watches of resource kinds, targeted by ResourceModifiers in Enforce or Selector mode, to the
+// controller.
+// Each kind is watched at most once.
+type targetWatches struct {
+	lock       sync.Mutex
+	controller controller.Controller
+	cache      cache.Cache
+	watched    map[schema.GroupVersionKind]struct{}
+}
+
+func newTargetWatches(c controller.Controller, cache cache.Cache) *targetWatches {
+	return &targetWatches{
+		controller: c,
+		cache:      cache,
+		watched:    make(map[schema.GroupVersionKind]struct{}),
+	}
+}
+
+// watchTarget makes sure, that changes of resources of the kind specified in resourceData trigger reconciliation
+// of ResourceModifiers in Enforce or Selector mode, which target them.
+// It is a no-op, when the reconciler was not set up with a manager.
+func (r *ResourceModifierReconciler) watchTarget(resourceData annotresourcemodifv1.TargetResourceData) error {
+	if r.targetWatches == nil {
+		return nil
+	}
+
+	resource, err := r.determineResourceType(resourceData)
+	if err != nil {
+		return err
+	}
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	r.targetWatches.lock.Lock()
+	defer r.targetWatches.lock.Unlock()
+
+	if _, exists := r.targetWatches.watched[gvk]; exists {
+		return nil
+	}
+
+	err = r.targetWatches.controller.Watch(source.Kind(r.targetWatches.cache, resource,
+		handler.EnqueueRequestsFromMapFunc(r.mapTargetToResourceModifiers),
+		predicate.ResourceVersionChangedPredicate{}))
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", gvk, err)
+	}
+	r.targetWatches.watched[gvk] = struct{}{}
+
+	return nil
+}
+
+// mapTargetToResourceModifiers returns requests for all ResourceModifiers in Enforce or Selector mode, which target
+// the resource.
+func (r *ResourceModifierReconciler) mapTargetToResourceModifiers(ctx context.Context, resource client.Object) []reconcile.Request {
+	var list annotresourcemodifv1.ResourceModifierList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list resourceModifiers")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, rm := range list.Items {
+		watched := rm.Spec.Mode == annotresourcemodifv1.EnforceMode || rm.Spec.Mode == annotresourcemodifv1.SelectorMode
+		if watched && r.isTarget(rm.Spec.ResourceData, resource) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&rm)})
+		}
+	}
+
+	return requests
+}
+
+// isTarget returns true, if resourceData specifies the resource.
+func (r *ResourceModifierReconciler) isTarget(resourceData annotresourcemodifv1.TargetResourceData,
+	resource client.Object) bool {
+	target, err := r.determineResourceType(resourceData)
+	if err != nil {
+		return false
+	}
+	targetGVK, err := apiutil.GVKForObject(target, r.Scheme)
+	if err != nil {
+		return false
+	}
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil || gvk != targetGVK {
+		return false
+	}
+
+	if resource.GetNamespace() != "" && resource.GetNamespace() != resourceData.Namespace {
+		return false
+	}
+
+	if len(resourceData.Labels) > 0 {
+		return labels.SelectorFromSet(resourceData.Labels).Matches(labels.Set(resource.GetLabels()))
+	}
+	return resource.GetName() == resourceData.Name
+}
+
+// recordDrift records in the status of ResourceModifier, that the resource drifted from the desired state and was
+// corrected, and emits an Event. The manager, who modified the resource last before the correction, is reported,
+// if it is known.
+func (r *ResourceModifierReconciler) recordDrift(rm *annotresourcemodifv1.ResourceModifier, original client.Object) {
+	rm.Status.DriftCorrected()
+
+	message := fmt.Sprintf("%s %s drifted from the desired state and was corrected",
+		rm.Spec.ResourceData.ResourceType, client.ObjectKeyFromObject(original))
+	if manager := lastManager(original); manager != "" {
+		message += ", last modified by " + manager
+	}
+	r.Recorder.Event(rm, v2.EventTypeWarning, reasonDriftCorrected, message)
+}
+
+// lastManager returns the name of the manager, which modified the resource last, according to its managed fields.
+func lastManager(resource client.Object) string {
+	manager := ""
+	var lastTime int64
+	for _, entry := range resource.GetManagedFields() {
+		if entry.Time == nil {
+			continue
+		}
+		if t := entry.Time.Unix(); manager == "" || t >= lastTime {
+			manager, lastTime = entry.Manager, t
+		}
+	}
+
+	return manager
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceModifierReconciler_Reconcile_enforce(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+		},
+	}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "test-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:env:prod"},
+			Mode:        v1.EnforceMode,
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	getRM := func() *v1.ResourceModifier {
+		got := &v1.ResourceModifier{}
+		assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+		return got
+	}
+
+	// First execution is not a drift
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, v1.PhaseSucceeded, getRM().Status.Phase)
+	assert.Equal(t, int64(0), getRM().Status.DriftCount)
+
+	// Resource in the desired state is not modified
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), getRM().Status.DriftCount)
+	assert.Empty(t, recorder.Events)
+
+	// Someone removes the enforced label
+	got := &v2.Pod{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+	got.Labels = nil
+	now := metav1.Now()
+	got.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "manager", Operation: metav1.ManagedFieldsOperationUpdate, Time: &metav1.Time{Time: now.Add(-time.Minute)}},
+		{Manager: "kubectl-edit", Operation: metav1.ManagedFieldsOperationUpdate, Time: &now},
+	}
+	assert.Nil(t, r.Update(ctx, got))
+
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+	assert.Equal(t, "prod", got.Labels["env"])
+	assert.Equal(t, int64(1), getRM().Status.DriftCount)
+	assert.NotNil(t, getRM().Status.LastDriftTime)
+
+	assert.Len(t, recorder.Events, 1)
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Warning "+reasonDriftCorrected))
+	assert.Contains(t, event, "kubectl-edit")
+}
+
+func TestResourceModifierReconciler_mapTargetToResourceModifiers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, appsv1.AddToScheme(scheme))
+
+	newRM := func(name, resourceType, target string, mode v1.Mode) *v1.ResourceModifier {
+		return &v1.ResourceModifier{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Spec: v1.ResourceModifierSpec{
+				ResourceData: v1.TargetResourceData{Name: target, Namespace: "test-ns", ResourceType: resourceType},
+				Mode:         mode,
+			},
+		}
+	}
+
+	selectorRM := func(name string, labels map[string]string) *v1.ResourceModifier {
+		rm := newRM(name, "pod", "", v1.SelectorMode)
+		rm.Spec.ResourceData.Labels = labels
+		return rm
+	}
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newRM("enforce", "pod", "test-pod", v1.EnforceMode),
+			selectorRM("selector", map[string]string{"app": "web"}),
+			selectorRM("other-selector", map[string]string{"app": "db"}),
+			newRM("one-shot", "pod", "test-pod", v1.OneShotMode),
+			newRM("other-pod", "pod", "other-pod", v1.EnforceMode),
+			newRM("other-kind", "deployment", "test-pod", v1.EnforceMode),
+		).Build(),
+		Scheme: scheme,
+	}
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "test-pod",
+		Namespace: "test-ns",
+		Labels:    map[string]string{"app": "web", "tier": "frontend"},
+	}}
+	requests := r.mapTargetToResourceModifiers(context.Background(), pod)
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: client.ObjectKey{Name: "enforce", Namespace: "test-ns"}},
+		{NamespacedName: client.ObjectKey{Name: "selector", Namespace: "test-ns"}},
+	}, requests)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	// successExpired
+	successExpired = "Modification expired and was reverted"
+
+	// reasonExpired is a reason of the Event, which is emitted when the modification expired
+	reasonExpired = "Expired"
+)
+
+// recordsChanges returns true, if changes made by ResourceModifier must be recorded, so they can be reverted later.
+func recordsChanges(rm *annotresourcemodifv1.ResourceModifier) bool {
+	return rm.Spec.RevertOnDelete || rm.Spec.Duration != nil
+}
+
+// setExpirationTime sets the time, when the modification made by the current execution expires.
+func setExpirationTime(rm *annotresourcemodifv1.ResourceModifier) {
+	if rm.Spec.Duration == nil {
+		rm.Status.ExpirationTime = nil
+		return
+	}
+
+	expiration := metav1.NewTime(time.Now().Add(rm.Spec.Duration.Duration))
+	rm.Status.ExpirationTime = &expiration
+}
+
+// isExpired returns true, if the modification made by ResourceModifier has expired.
+func isExpired(rm *annotresourcemodifv1.ResourceModifier) bool {
+	return rm.Spec.Duration != nil && rm.Status.ExpirationTime != nil && !time.Now().Before(rm.Status.ExpirationTime.Time)
+}
+
+// untilExpiration returns the time left until the modification expires, or zero if it does not expire.
+func untilExpiration(rm *annotresourcemodifv1.ResourceModifier) time.Duration {
+	if rm.Spec.Duration == nil || rm.Status.ExpirationTime == nil {
+		return 0
+	}
+
+	left := time.Until(rm.Status.ExpirationTime.Time)
+	if left <= 0 {
+		// requeue immediately, zero would mean no requeue at all
+		return time.Nanosecond
+	}
+	return left
+}
+
+// expire reverts the changes made by ResourceModifier, and marks it Expired. Records of the reverted changes
+// are dropped, so they are not reverted once more upon deletion.
+func (r *ResourceModifierReconciler) expire(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier) error {
+	if err := r.revert(ctx, rm); err != nil {
+		return err
+	}
+
+	if rm.Status.Conditions == nil {
+		r.initResourceModifierStatus(rm)
+	}
+	rm.Status.RevertRecords = nil
+	rm.Status.Phase = annotresourcemodifv1.PhaseExpired
+	rm.Status.SuccessfulStatus(successExpired)
+
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	if err := r.Client.Status().Update(ctx, rm); err != nil {
+		return err
+	}
+	r.Recorder.Event(rm, v2.EventTypeNormal, reasonExpired, successExpired)
+
+	return nil
+}
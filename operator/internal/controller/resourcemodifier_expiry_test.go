@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestResourceModifierReconciler_Reconcile_duration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+			UID:       "test-uid",
+			Labels:    map[string]string{"app": "web"},
+		},
+	}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "test-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:log-level:debug"},
+			Duration:    &metav1.Duration{Duration: time.Hour},
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	labels := func() map[string]string {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+		return got.Labels
+	}
+
+	result, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+	assert.Equal(t, "debug", labels()["log-level"])
+
+	gotRM := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+	assert.NotNil(t, gotRM.Status.ExpirationTime)
+
+	// Requeued before the expiration
+	result, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.True(t, result.RequeueAfter > 0)
+	assert.Equal(t, "debug", labels()["log-level"])
+
+	// Duration passes
+	expired := metav1.NewTime(time.Now().Add(-time.Second))
+	gotRM.Status.ExpirationTime = &expired
+	assert.Nil(t, r.Status().Update(ctx, gotRM))
+
+	result, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, map[string]string{"app": "web"}, labels())
+
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseExpired, gotRM.Status.Phase)
+	assert.Empty(t, gotRM.Status.RevertRecords)
+	assert.Equal(t, successExpired, gotRM.Status.Conditions[v1.StatusSuccess])
+
+	// Expired ResourceModifier is not executed again
+	result, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, map[string]string{"app": "web"}, labels())
+}
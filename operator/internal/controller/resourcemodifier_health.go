@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// DefaultHealthCheckTimeout limits how long to wait for the resource to become healthy, unless
+	// the HealthCheck specifies its own timeout.
+	DefaultHealthCheckTimeout = 5 * time.Minute
+
+	// healthCheckInterval is how often the health of the resource is checked, while it is not healthy yet
+	healthCheckInterval = 10 * time.Second
+
+	// reasonUnhealthy is a reason of the Event, which is emitted when the resource did not become healthy
+	reasonUnhealthy = "Unhealthy"
+
+	// reasonRolledBack is a reason of the Event, which is emitted when the changes of an unhealthy resource were
+	// reverted
+	reasonRolledBack = "RolledBack"
+)
+
+// errUnhealthy is returned, when the resource did not become healthy after it was modified.
+var errUnhealthy = errors.New("resource is not healthy")
+
+// healthCheckTimeout returns how long to wait for the resource to become healthy.
+func healthCheckTimeout(check *annotresourcemodifv1.HealthCheck) time.Duration {
+	if check.Timeout != nil {
+		return check.Timeout.Duration
+	}
+	return DefaultHealthCheckTimeout
+}
+
+// verifyingHealth returns true, if the execution modified the resource, and waits for it to become healthy.
+func verifyingHealth(rm *annotresourcemodifv1.ResourceModifier) bool {
+	_, ok := rm.Status.Conditions[annotresourcemodifv1.StatusHealthCheckStarted]
+	return ok
+}
+
+// startHealthCheck records in the status, that the resource was modified, so that its health is verified by
+// verifyHealth. With Rollback, fields changed by the execution are recorded, so that they can be restored later.
+// original is the state of the resource before the execution, resource is the state written by it.
+func (r *ResourceModifierReconciler) startHealthCheck(rm *annotresourcemodifv1.ResourceModifier, original,
+	resource client.Object) error {
+	now := metav1.Now()
+	rm.Status.Health = &annotresourcemodifv1.HealthStatus{StartedAt: &now, CheckedAt: now}
+	if rm.Spec.HealthCheck.Rollback {
+		changes, err := changedFields(original, resource)
+		if err != nil {
+			return err
+		}
+		record, err := r.newRevertRecord(original, changes)
+		if err != nil {
+			return err
+		}
+		rm.Status.Health.Changes = &record
+	}
+	rm.Status.Conditions[annotresourcemodifv1.StatusHealthCheckStarted] = fmt.Sprintf(
+		"Waiting up to %s for the resource to become healthy", healthCheckTimeout(rm.Spec.HealthCheck))
+
+	return nil
+}
+
+// verifyHealth checks, whether the resource modified by the execution became healthy, and records the outcome in
+// the status. The resource is not waited for within the reconciliation: while it is not healthy yet, the time until
+// the next check is returned, and the verification is resumed by the next reconciliation. If it does not become
+// healthy in time, the changes of the execution are rolled back, when the HealthCheck says so, and an error wrapping
+// errUnhealthy is returned.
+func (r *ResourceModifierReconciler) verifyHealth(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) (time.Duration, error) {
+	check, health := rm.Spec.HealthCheck, rm.Status.Health
+	if !verifyingHealth(rm) || check == nil || health == nil || health.StartedAt == nil {
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusHealthCheckStarted)
+		return 0, nil
+	}
+	timeout := healthCheckTimeout(check)
+
+	var healthy bool
+	var message string
+	resource, err := r.findTarget(ctx, rm.Spec.ResourceData)
+	switch {
+	case err == nil:
+		healthy, message, err = checkHealth(ctx, resource, check.Expression)
+	case isRetryable(err):
+		// the resource is checked again, until the timeout passes
+		message, err = err.Error(), nil
+	default:
+		resource = nil
+	}
+	health.CheckedAt = metav1.Now()
+
+	switch {
+	case healthy:
+		health.Healthy, health.Message, health.Changes = true, "", nil
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusHealthCheckStarted)
+		return 0, nil
+	case err == nil:
+		if left := time.Until(health.StartedAt.Add(timeout)); left > 0 {
+			health.Message = message
+			return min(left, healthCheckInterval), nil
+		}
+		message = fmt.Sprintf("not healthy within %s: %s", timeout, message)
+	case errors.Is(err, errUnhealthy):
+		// the resource cannot become healthy anymore, message describes why
+	default:
+		message = err.Error()
+	}
+
+	return 0, r.failHealthCheck(ctx, rm, resource, message)
+}
+
+// failHealthCheck records, that the resource did not become healthy, and restores the fields changed by
+// the execution, if they were recorded for Rollback, and the resource still exists. Fields changed by others since
+// are skipped, as when reverting. The resource is locked meanwhile, as when the actions are applied. If it is locked
+// by another ResourceModifier, the verification is kept in progress, so the rollback is attempted again.
+func (r *ResourceModifierReconciler) failHealthCheck(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	resource client.Object, message string) error {
+	health := rm.Status.Health
+	health.Healthy, health.Message = false, message
+
+	var err error
+	if health.Changes != nil && resource != nil {
+		err = r.withTargetLock(ctx, rm, resource, func() error {
+			return r.revertRecords(ctx, rm, []annotresourcemodifv1.RevertRecord{*health.Changes})
+		})
+		if errors.Is(err, errLocked) {
+			// the verification is resumed, and the rollback attempted again, once the lock is released
+			return fmt.Errorf("%w: %s, and rollback is waiting: %w", errUnhealthy, message, err)
+		}
+		health.RolledBack = err == nil
+	}
+	health.Changes = nil
+	delete(rm.Status.Conditions, annotresourcemodifv1.StatusHealthCheckStarted)
+	r.Recorder.Event(rm, v2.EventTypeWarning, reasonUnhealthy, message)
+
+	if err != nil {
+		return fmt.Errorf("%w: %s, and rollback failed: %w", errUnhealthy, message, err)
+	}
+	if health.RolledBack {
+		r.Recorder.Event(rm, v2.EventTypeNormal, reasonRolledBack, "Changes of the unhealthy resource were reverted")
+	}
+	return fmt.Errorf("%w: %s", errUnhealthy, message)
+}
+
+// checkHealth returns true, if the resource is healthy, or a message describing why it is not. An error is
+// returned, if the resource cannot become healthy anymore (errUnhealthy), or the expression does not compile.
+// An expression, which fails to be evaluated, e.g. because a field of the status is not set yet, does not hold.
+func checkHealth(ctx context.Context, resource client.Object, expression string) (bool, string, error) {
+	healthy, message, err := checkReadiness(resource)
+	if err != nil || !healthy {
+		return false, message, err
+	}
+	if expression == "" {
+		return true, "", nil
+	}
+
+	healthy, err = evaluateExpression(ctx, expression, resource)
+	switch {
+	case errors.Is(err, errNotEvaluated):
+		return false, err.Error(), nil
+	case err != nil:
+		return false, "", err
+	case !healthy:
+		return false, fmt.Sprintf("expression %q is false", expression), nil
+	}
+	return true, "", nil
+}
+
+// checkReadiness checks that all replicas of a workload are updated and available, or that a Pod is Ready.
+// Resources of other kinds are considered ready.
+func checkReadiness(resource client.Object) (bool, string, error) {
+	switch resource := resource.(type) {
+	case *v1.Deployment:
+		for _, condition := range resource.Status.Conditions {
+			if condition.Type == v1.DeploymentProgressing && condition.Status == v2.ConditionFalse &&
+				condition.Reason == "ProgressDeadlineExceeded" {
+				return false, "rollout exceeded its progress deadline", errUnhealthy
+			}
+		}
+		return workloadReady(resource.Generation, resource.Status.ObservedGeneration, replicasOf(resource.Spec.Replicas),
+			resource.Status.UpdatedReplicas, resource.Status.AvailableReplicas)
+	case *v1.StatefulSet:
+		return workloadReady(resource.Generation, resource.Status.ObservedGeneration, replicasOf(resource.Spec.Replicas),
+			resource.Status.UpdatedReplicas, resource.Status.AvailableReplicas)
+	case *v1.DaemonSet:
+		return workloadReady(resource.Generation, resource.Status.ObservedGeneration,
+			resource.Status.DesiredNumberScheduled, resource.Status.UpdatedNumberScheduled,
+			resource.Status.NumberAvailable)
+	case *v2.Pod:
+		if resource.Status.Phase == v2.PodFailed {
+			return false, "pod failed", errUnhealthy
+		}
+		for _, condition := range resource.Status.Conditions {
+			if condition.Type == v2.PodReady && condition.Status == v2.ConditionTrue {
+				return true, "", nil
+			}
+		}
+		return false, "pod is not ready", nil
+	}
+
+	return true, "", nil
+}
+
+// workloadReady checks that the workload controller observed the current generation, and all replicas are updated
+// and available.
+func workloadReady(generation, observedGeneration int64, replicas, updated, available int32) (bool, string, error) {
+	switch {
+	case observedGeneration < generation:
+		return false, "update was not observed yet", nil
+	case updated < replicas:
+		return false, fmt.Sprintf("%d of %d replicas updated", updated, replicas), nil
+	case available < replicas:
+		return false, fmt.Sprintf("%d of %d replicas available", available, replicas), nil
+	}
+
+	return true, "", nil
+}
+
+// replicasOf returns the desired number of replicas, which defaults to 1.
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
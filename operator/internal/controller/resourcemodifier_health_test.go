@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	deployment := func(replicas, updated, available int32, conditions ...appsv1.DeploymentCondition) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(replicas)},
+			Status: appsv1.DeploymentStatus{
+				ObservedGeneration: 2,
+				UpdatedReplicas:    updated,
+				AvailableReplicas:  available,
+				Conditions:         conditions,
+			},
+		}
+	}
+	pod := func(phase v2.PodPhase, ready v2.ConditionStatus) *v2.Pod {
+		return &v2.Pod{Status: v2.PodStatus{
+			Phase:      phase,
+			Conditions: []v2.PodCondition{{Type: v2.PodReady, Status: ready}},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		resource    client.Object
+		wantHealthy bool
+		wantErr     bool
+	}{
+		{
+			name:        "Deployment with all replicas available",
+			resource:    deployment(3, 3, 3),
+			wantHealthy: true,
+		},
+		{
+			name:     "Deployment during rollout",
+			resource: deployment(3, 2, 3),
+		},
+		{
+			name:     "Deployment with unavailable replicas",
+			resource: deployment(3, 3, 1),
+		},
+		{
+			name: "Deployment whose update was not observed",
+			resource: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 3},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+		},
+		{
+			name: "Deployment exceeded its progress deadline",
+			resource: deployment(3, 1, 1, appsv1.DeploymentCondition{Type: appsv1.DeploymentProgressing,
+				Status: v2.ConditionFalse, Reason: "ProgressDeadlineExceeded"}),
+			wantErr: true,
+		},
+		{
+			name: "StatefulSet with all replicas available",
+			resource: &appsv1.StatefulSet{
+				Status: appsv1.StatefulSetStatus{UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+			wantHealthy: true,
+		},
+		{
+			name: "DaemonSet with pods not available",
+			resource: &appsv1.DaemonSet{
+				Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 5, UpdatedNumberScheduled: 5, NumberAvailable: 4},
+			},
+		},
+		{
+			name:        "Ready pod",
+			resource:    pod(v2.PodRunning, v2.ConditionTrue),
+			wantHealthy: true,
+		},
+		{
+			name:     "Pod not ready",
+			resource: pod(v2.PodRunning, v2.ConditionFalse),
+		},
+		{
+			name:     "Failed pod",
+			resource: pod(v2.PodFailed, v2.ConditionFalse),
+			wantErr:  true,
+		},
+		{
+			name:        "Resource without readiness",
+			resource:    &v2.Service{},
+			wantHealthy: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy, message, err := checkReadiness(tt.resource)
+			assert.Equal(t, tt.wantHealthy, healthy)
+			assert.Equal(t, tt.wantHealthy, message == "")
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errUnhealthy)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestResourceModifierReconciler_Reconcile_healthCheck(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	tests := []struct {
+		name           string
+		ready          v2.ConditionStatus
+		readyLater     bool
+		healthCheck    *v1.HealthCheck
+		wantPhase      v1.Phase
+		wantHealthy    bool
+		wantRolledBack bool
+		wantLabel      string
+	}{
+		{
+			name:        "Healthy pod",
+			ready:       v2.ConditionTrue,
+			healthCheck: &v1.HealthCheck{Expression: "object.metadata.labels.restarted == 'true'", Rollback: true},
+			wantPhase:   v1.PhaseSucceeded,
+			wantHealthy: true,
+			wantLabel:   "true",
+		},
+		{
+			name:        "Pod becoming ready is checked by the next reconciliation",
+			ready:       v2.ConditionFalse,
+			readyLater:  true,
+			healthCheck: &v1.HealthCheck{Timeout: &metav1.Duration{Duration: time.Minute}, Rollback: true},
+			wantPhase:   v1.PhaseSucceeded,
+			wantHealthy: true,
+			wantLabel:   "true",
+		},
+		{
+			name:           "Pod not ready in time is rolled back",
+			ready:          v2.ConditionFalse,
+			healthCheck:    &v1.HealthCheck{Timeout: &metav1.Duration{Duration: 100 * time.Millisecond}, Rollback: true},
+			wantPhase:      v1.PhaseFailed,
+			wantRolledBack: true,
+		},
+		{
+			name:  "Unhealthy pod is kept without rollback",
+			ready: v2.ConditionTrue,
+			healthCheck: &v1.HealthCheck{
+				Timeout:    &metav1.Duration{Duration: 100 * time.Millisecond},
+				Expression: "object.status.phase == 'Succeeded'",
+			},
+			wantPhase: v1.PhaseFailed,
+			wantLabel: "true",
+		},
+		{
+			name:  "Expression on a missing field is checked until the timeout",
+			ready: v2.ConditionTrue,
+			healthCheck: &v1.HealthCheck{
+				Timeout:    &metav1.Duration{Duration: 100 * time.Millisecond},
+				Expression: "object.status.readyReplicas >= 2",
+				Rollback:   true,
+			},
+			wantPhase:      v1.PhaseFailed,
+			wantRolledBack: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+					Labels:    map[string]string{"app": "test"},
+				},
+				Status: v2.PodStatus{
+					Phase:      v2.PodRunning,
+					Conditions: []v2.PodCondition{{Type: v2.PodReady, Status: tt.ready}},
+				},
+			}
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "rm-test",
+					Namespace:  "test-ns",
+					Generation: 1,
+				},
+				Spec: v1.ResourceModifierSpec{
+					ResourceData: v1.TargetResourceData{
+						Name:         "test-pod",
+						Namespace:    "test-ns",
+						ResourceType: "pod",
+					},
+					Annotations: []string{"addLabel:restarted:true"},
+					HealthCheck: tt.healthCheck,
+				},
+			}
+
+			r := &ResourceModifierReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(pod, rm).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			ctx := context.Background()
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+			result, err := r.Reconcile(ctx, request)
+			assert.Nil(t, err)
+
+			got := &v1.ResourceModifier{}
+			if tt.readyLater || !tt.wantHealthy {
+				// the worker is not blocked, the health is checked again by the next reconciliation
+				assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+				assert.Empty(t, got.Status.Phase)
+				assert.Contains(t, got.Status.Conditions, v1.StatusHealthCheckStarted)
+				assert.Equal(t, tt.healthCheck.Rollback, got.Status.Health.Changes != nil)
+				assert.Positive(t, result.RequeueAfter)
+				assert.LessOrEqual(t, result.RequeueAfter, healthCheckInterval)
+
+				if tt.readyLater {
+					readyPod := &v2.Pod{}
+					assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), readyPod))
+					readyPod.Status.Conditions[0].Status = v2.ConditionTrue
+					assert.Nil(t, r.Status().Update(ctx, readyPod))
+				} else {
+					time.Sleep(result.RequeueAfter)
+				}
+				_, err = r.Reconcile(ctx, request)
+				assert.Nil(t, err)
+			}
+
+			assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+			assert.Equal(t, tt.wantPhase, got.Status.Phase)
+			assert.NotContains(t, got.Status.Conditions, v1.StatusHealthCheckStarted)
+			assert.Nil(t, got.Status.Health.Changes)
+			assert.NotNil(t, got.Status.Health)
+			assert.Equal(t, tt.wantHealthy, got.Status.Health.Healthy)
+			assert.Equal(t, tt.wantHealthy, got.Status.Health.Message == "")
+			assert.Equal(t, tt.wantRolledBack, got.Status.Health.RolledBack)
+
+			gotPod := &v2.Pod{}
+			assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+			assert.Equal(t, tt.wantLabel, gotPod.Labels["restarted"])
+			assert.Equal(t, "test", gotPod.Labels["app"])
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	v3 "k8s.io/api/batch/v1"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHookTimeout limits how long a hook Job may run, unless the hook specifies its own timeout.
+	DefaultHookTimeout = 10 * time.Minute
+
+	// reasonHookStarted is a reason of the Event, which is emitted when a hook Job was created
+	reasonHookStarted = "HookStarted"
+
+	// reasonHookSucceeded is a reason of the Event, which is emitted when a hook Job completed
+	reasonHookSucceeded = "HookSucceeded"
+
+	// reasonHookFailed is a reason of the Event, which is emitted when a hook Job failed, or timed out
+	reasonHookFailed = "HookFailed"
+
+	// hookLabel is a label of hook Jobs, containing the name of ResourceModifier
+	hookLabel = "annot-resource-modif.ericsson.com/resource-modifier"
+
+	// maxJobNameLength keeps names of hook Jobs short enough for the labels, which the Job controller derives from them
+	maxJobNameLength = 63
+)
+
+// hasHooks returns true, if ResourceModifier runs any hooks.
+func hasHooks(rm *annotresourcemodifv1.ResourceModifier) bool {
+	return rm.Spec.Hooks != nil && (len(rm.Spec.Hooks.Pre) > 0 || len(rm.Spec.Hooks.Post) > 0)
+}
+
+// hooksOf returns hooks of the stage.
+func hooksOf(rm *annotresourcemodifv1.ResourceModifier, stage annotresourcemodifv1.HookStage) []annotresourcemodifv1.Hook {
+	if rm.Spec.Hooks == nil {
+		return nil
+	}
+	if stage == annotresourcemodifv1.PreHookStage {
+		return rm.Spec.Hooks.Pre
+	}
+	return rm.Spec.Hooks.Post
+}
+
+// hookTimeout returns how long the hook Job may run.
+func hookTimeout(hook annotresourcemodifv1.Hook) time.Duration {
+	if hook.Timeout != nil {
+		return hook.Timeout.Duration
+	}
+	return DefaultHookTimeout
+}
+
+// executeWithHooks runs pre hooks, executes ResourceModifier, verifies health of the modified resource, and runs
+// post hooks. Neither hook Jobs, nor the resource are waited for within the reconciliation: while a hook is running,
+// the time left until its timeout is returned, and the execution is resumed by the next reconciliation, triggered by
+// the change of the Job, or when the timeout passes. Likewise, the time until the next health check is returned.
+// A failed pre hook aborts the execution before any action is performed.
+func (r *ResourceModifierReconciler) executeWithHooks(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) (time.Duration, error) {
+	if rm.Status.HookStage != annotresourcemodifv1.PostHookStage {
+		if wait, err := r.runHooks(ctx, rm, annotresourcemodifv1.PreHookStage); err != nil || wait > 0 {
+			return wait, err
+		}
+		if !verifyingHealth(rm) {
+			if _, err := r.execute(ctx, rm); err != nil {
+				return 0, err
+			}
+		}
+		if wait, err := r.verifyHealth(ctx, rm); err != nil || wait > 0 {
+			return wait, err
+		}
+	}
+
+	return r.runHooks(ctx, rm, annotresourcemodifv1.PostHookStage)
+}
+
+// runHooks runs hooks of the stage one after another. Returns the time to wait for a running hook, or zero, when
+// all hooks of the stage finished. HookStage of the status is kept, while the hooks of the stage are running.
+func (r *ResourceModifierReconciler) runHooks(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	stage annotresourcemodifv1.HookStage) (time.Duration, error) {
+	for _, hook := range hooksOf(rm, stage) {
+		rm.Status.HookStage = stage
+		status, err := r.runHook(ctx, rm, stage, hook)
+		if err != nil {
+			rm.Status.HookStage = ""
+			return 0, err
+		}
+
+		switch status.Phase {
+		case annotresourcemodifv1.HookRunning:
+			left := time.Until(status.StartTime.Add(hookTimeout(hook)))
+			if left <= 0 {
+				left = time.Nanosecond
+			}
+			return left, nil
+		case annotresourcemodifv1.HookFailed:
+			rm.Status.HookStage = ""
+			return 0, fmt.Errorf("%s hook %s failed: %s", strings.ToLower(string(stage)), hook.Name, status.Message)
+		}
+	}
+	rm.Status.HookStage = ""
+
+	return 0, nil
+}
+
+// runHook creates the Job of the hook, or observes the Job created before, and records its state in the status.
+func (r *ResourceModifierReconciler) runHook(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	stage annotresourcemodifv1.HookStage, hook annotresourcemodifv1.Hook) (*annotresourcemodifv1.HookStatus, error) {
+	status := findHookStatus(rm, stage, hook.Name)
+	if status == nil {
+		job, err := r.createHookJob(ctx, rm, stage, hook)
+		if err != nil {
+			return nil, err
+		}
+		rm.Status.Hooks = append(rm.Status.Hooks, annotresourcemodifv1.HookStatus{
+			Name:      hook.Name,
+			Stage:     stage,
+			JobName:   job.Name,
+			Phase:     annotresourcemodifv1.HookRunning,
+			StartTime: job.CreationTimestamp,
+		})
+		status = &rm.Status.Hooks[len(rm.Status.Hooks)-1]
+		if status.StartTime.IsZero() {
+			status.StartTime = metav1.Now()
+		}
+	}
+	if status.Phase != annotresourcemodifv1.HookRunning {
+		return status, nil
+	}
+
+	job := &v3.Job{}
+	getCtx, cancel := r.operationContext(ctx)
+	err := r.Client.Get(getCtx, client.ObjectKey{Name: status.JobName, Namespace: rm.Namespace}, job)
+	cancel()
+	switch {
+	case apierrors.IsNotFound(err):
+		status.Phase, status.Message = annotresourcemodifv1.HookFailed, "Job was deleted"
+	case err != nil:
+		return nil, err
+	default:
+		status.Phase, status.Message = jobPhase(job)
+		if status.Phase == annotresourcemodifv1.HookRunning && time.Since(status.StartTime.Time) >= hookTimeout(hook) {
+			status.Phase = annotresourcemodifv1.HookFailed
+			status.Message = fmt.Sprintf("Job did not finish within %s", hookTimeout(hook))
+		}
+	}
+	if status.Phase == annotresourcemodifv1.HookRunning {
+		return status, nil
+	}
+
+	now := metav1.Now()
+	status.CompletionTime = &now
+	if status.Phase == annotresourcemodifv1.HookSucceeded {
+		r.Recorder.Eventf(rm, v2.EventTypeNormal, reasonHookSucceeded, "Hook %s completed", status.JobName)
+	} else {
+		r.Recorder.Eventf(rm, v2.EventTypeWarning, reasonHookFailed, "Hook %s failed: %s", status.JobName,
+			status.Message)
+	}
+
+	return status, r.cleanupHookJob(ctx, rm, status)
+}
+
+// createHookJob creates the Job of the hook, owned by ResourceModifier. The name of the Job is derived from
+// the execution, so the Job is adopted, if it was created by a reconciliation, which failed to record it.
+func (r *ResourceModifierReconciler) createHookJob(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	stage annotresourcemodifv1.HookStage, hook annotresourcemodifv1.Hook) (*v3.Job, error) {
+	job := &v3.Job{
+		ObjectMeta: *hook.Template.ObjectMeta.DeepCopy(),
+		Spec:       *hook.Template.Spec.DeepCopy(),
+	}
+	job.Name = hookJobName(rm, stage, hook.Name)
+	job.GenerateName = ""
+	job.Namespace = rm.Namespace
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[hookLabel] = rm.Name
+	if job.Spec.ActiveDeadlineSeconds == nil {
+		// the Job is stopped, when the hook times out
+		deadline := int64(hookTimeout(hook).Seconds())
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+	if err := controllerutil.SetControllerReference(rm, job, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	createCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	err := r.Client.Create(createCtx, job)
+	if apierrors.IsAlreadyExists(err) {
+		if err = r.Client.Get(createCtx, client.ObjectKeyFromObject(job), job); err != nil {
+			return nil, err
+		}
+		if !metav1.IsControlledBy(job, rm) {
+			return nil, fmt.Errorf("job %s already exists, and is not owned by ResourceModifier", job.Name)
+		}
+		return job, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s hook %s: %w", strings.ToLower(string(stage)), hook.Name, err)
+	}
+	r.Recorder.Eventf(rm, v2.EventTypeNormal, reasonHookStarted, "Created Job %s", job.Name)
+
+	return job, nil
+}
+
+// cleanupHookJob deletes the finished Job of the hook, if the cleanup policy says so.
+func (r *ResourceModifierReconciler) cleanupHookJob(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	status *annotresourcemodifv1.HookStatus) error {
+	policy := annotresourcemodifv1.OnSuccessCleanupPolicy
+	if rm.Spec.Hooks != nil && rm.Spec.Hooks.CleanupPolicy != "" {
+		policy = rm.Spec.Hooks.CleanupPolicy
+	}
+	switch {
+	case policy == annotresourcemodifv1.AlwaysCleanupPolicy:
+	case policy == annotresourcemodifv1.OnSuccessCleanupPolicy && status.Phase == annotresourcemodifv1.HookSucceeded:
+	default:
+		return nil
+	}
+
+	job := &v3.Job{ObjectMeta: metav1.ObjectMeta{Name: status.JobName, Namespace: rm.Namespace}}
+	deleteCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	return client.IgnoreNotFound(r.Client.Delete(deleteCtx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+// jobPhase returns the phase of the hook according to conditions of the Job.
+func jobPhase(job *v3.Job) (annotresourcemodifv1.HookPhase, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v2.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v3.JobComplete:
+			return annotresourcemodifv1.HookSucceeded, ""
+		case v3.JobFailed:
+			return annotresourcemodifv1.HookFailed, condition.Message
+		}
+	}
+
+	return annotresourcemodifv1.HookRunning, ""
+}
+
+// findHookStatus returns the status of the hook of the current execution, or nil, if it was not started yet.
+func findHookStatus(rm *annotresourcemodifv1.ResourceModifier, stage annotresourcemodifv1.HookStage,
+	name string) *annotresourcemodifv1.HookStatus {
+	for i := range rm.Status.Hooks {
+		if rm.Status.Hooks[i].Stage == stage && rm.Status.Hooks[i].Name == name {
+			return &rm.Status.Hooks[i]
+		}
+	}
+
+	return nil
+}
+
+// hookJobName returns the name of the Job of the hook. It contains a hash of the execution, so each execution
+// (a new generation, rerun, scheduled run or retry) creates new Jobs.
+func hookJobName(rm *annotresourcemodifv1.ResourceModifier, stage annotresourcemodifv1.HookStage, name string) string {
+	execution := fmt.Sprintf("%s/%d/%s/%d", rm.UID, rm.Generation, rm.Annotations[annotresourcemodifv1.RerunAnnotation],
+		rm.Status.FailedAttempts)
+	if rm.Status.LastScheduleTime != nil {
+		execution += "/" + rm.Status.LastScheduleTime.UTC().Format(time.RFC3339)
+	}
+	sum := sha256.Sum256([]byte(execution))
+	suffix := fmt.Sprintf("-%s-%s-%s", strings.ToLower(string(stage)), name, hex.EncodeToString(sum[:])[:8])
+
+	prefix := rm.Name
+	if len(prefix)+len(suffix) > maxJobNameLength {
+		prefix = strings.TrimRight(prefix[:maxJobNameLength-len(suffix)], "-.")
+	}
+	return prefix + suffix
+}
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceModifierReconciler_Reconcile_hooks(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, batchv1.AddToScheme(scheme))
+
+	template := batchv1.JobTemplateSpec{
+		Spec: batchv1.JobSpec{
+			Template: v2.PodTemplateSpec{
+				Spec: v2.PodSpec{
+					Containers:    []v2.Container{{Name: "backup", Image: "busybox"}},
+					RestartPolicy: v2.RestartPolicyNever,
+				},
+			},
+		},
+	}
+	finish := func(conditionType batchv1.JobConditionType) func(*batchv1.Job) {
+		return func(job *batchv1.Job) {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: v2.ConditionTrue,
+				Message: "BackoffLimitExceeded"}}
+		}
+	}
+	// runForever leaves the Job running, so the hook times out
+	runForever := func(*batchv1.Job) {}
+
+	tests := []struct {
+		name          string
+		cleanupPolicy v1.HookCleanupPolicy
+		pre           func(*batchv1.Job)
+		post          func(*batchv1.Job)
+		wantPhase     v1.Phase
+		wantLabel     bool
+		wantHooks     []v1.HookPhase
+		wantJobs      int
+	}{
+		{
+			name:      "Pre and post hooks succeed",
+			pre:       finish(batchv1.JobComplete),
+			post:      finish(batchv1.JobComplete),
+			wantPhase: v1.PhaseSucceeded,
+			wantLabel: true,
+			wantHooks: []v1.HookPhase{v1.HookSucceeded, v1.HookSucceeded},
+		},
+		{
+			name:      "Failed pre hook aborts the actions",
+			pre:       finish(batchv1.JobFailed),
+			wantPhase: v1.PhaseFailed,
+			wantHooks: []v1.HookPhase{v1.HookFailed},
+			wantJobs:  1,
+		},
+		{
+			name:          "Failed pre hook is cleaned up with Always policy",
+			cleanupPolicy: v1.AlwaysCleanupPolicy,
+			pre:           finish(batchv1.JobFailed),
+			wantPhase:     v1.PhaseFailed,
+			wantHooks:     []v1.HookPhase{v1.HookFailed},
+		},
+		{
+			name:          "Succeeded hooks are kept with Never policy",
+			cleanupPolicy: v1.NeverCleanupPolicy,
+			pre:           finish(batchv1.JobComplete),
+			post:          finish(batchv1.JobComplete),
+			wantPhase:     v1.PhaseSucceeded,
+			wantLabel:     true,
+			wantHooks:     []v1.HookPhase{v1.HookSucceeded, v1.HookSucceeded},
+			wantJobs:      2,
+		},
+		{
+			name:      "Post hook times out",
+			pre:       finish(batchv1.JobComplete),
+			post:      runForever,
+			wantPhase: v1.PhaseFailed,
+			wantLabel: true,
+			wantHooks: []v1.HookPhase{v1.HookSucceeded, v1.HookFailed},
+			wantJobs:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+				},
+			}
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "rm-test",
+					Namespace:  "test-ns",
+					UID:        "rm-uid",
+					Generation: 1,
+				},
+				Spec: v1.ResourceModifierSpec{
+					ResourceData: v1.TargetResourceData{
+						Name:         "test-pod",
+						Namespace:    "test-ns",
+						ResourceType: "pod",
+					},
+					Annotations: []string{"addLabel:modified:true"},
+					Hooks: &v1.Hooks{
+						Pre:           []v1.Hook{{Name: "backup", Template: template}},
+						Post:          []v1.Hook{{Name: "verify", Template: template}},
+						CleanupPolicy: tt.cleanupPolicy,
+					},
+				},
+			}
+
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).
+					WithStatusSubresource(rm).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			ctx := context.Background()
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+			got := &v1.ResourceModifier{}
+			labelOf := func() string {
+				gotPod := &v2.Pod{}
+				assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+				return gotPod.Labels["modified"]
+			}
+			// runStage starts the hook of the stage, finishes its Job, and resumes the execution. Job, which is
+			// left running, is treated as started before the timeout
+			runStage := func(stage v1.HookStage, finishJob func(*batchv1.Job)) {
+				result, err := r.Reconcile(ctx, request)
+				assert.Nil(t, err)
+				assert.Positive(t, result.RequeueAfter)
+				assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+				assert.Equal(t, stage, got.Status.HookStage)
+				assert.Empty(t, got.Status.Phase)
+
+				status := got.Status.Hooks[len(got.Status.Hooks)-1]
+				assert.Equal(t, v1.HookRunning, status.Phase)
+				job := &batchv1.Job{}
+				assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: status.JobName, Namespace: "test-ns"}, job))
+				assert.True(t, metav1.IsControlledBy(job, got))
+				assert.Equal(t, "rm-test", job.Labels[hookLabel])
+				assert.NotNil(t, job.Spec.ActiveDeadlineSeconds)
+
+				// nothing changes, while the Job is running
+				started := len(got.Status.Hooks)
+				_, err = r.Reconcile(ctx, request)
+				assert.Nil(t, err)
+				assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+				assert.Equal(t, stage, got.Status.HookStage)
+				assert.Len(t, got.Status.Hooks, started)
+
+				finishJob(job)
+				assert.Nil(t, r.Status().Update(ctx, job))
+				if job.Status.Conditions == nil {
+					got.Status.Hooks[len(got.Status.Hooks)-1].StartTime = metav1.NewTime(time.Now().Add(-DefaultHookTimeout))
+					assert.Nil(t, r.Status().Update(ctx, got))
+				}
+				_, err = r.Reconcile(ctx, request)
+				assert.Nil(t, err)
+			}
+
+			runStage(v1.PreHookStage, tt.pre)
+			assert.Equal(t, tt.wantLabel, labelOf() == "true")
+			if tt.post != nil {
+				runStage(v1.PostHookStage, tt.post)
+			}
+
+			assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+			assert.Equal(t, tt.wantPhase, got.Status.Phase)
+			assert.Empty(t, got.Status.HookStage)
+			assert.Equal(t, tt.wantLabel, labelOf() == "true")
+			var phases []v1.HookPhase
+			for _, hook := range got.Status.Hooks {
+				phases = append(phases, hook.Phase)
+				assert.NotNil(t, hook.CompletionTime)
+			}
+			assert.Equal(t, tt.wantHooks, phases)
+
+			jobs := &batchv1.JobList{}
+			assert.Nil(t, r.List(ctx, jobs, client.InNamespace("test-ns")))
+			assert.Len(t, jobs.Items, tt.wantJobs)
+
+			// completed execution does not run the hooks again
+			_, err := r.Reconcile(ctx, request)
+			assert.Nil(t, err)
+			assert.Nil(t, r.List(ctx, jobs, client.InNamespace("test-ns")))
+			assert.Len(t, jobs.Items, tt.wantJobs)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_createHookJob(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, batchv1.AddToScheme(scheme))
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns", UID: "rm-uid"},
+	}
+	hook := v1.Hook{Name: "backup"}
+	foreign := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: hookJobName(rm, v1.PreHookStage, "backup"), Namespace: "test-ns"},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(rm).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+
+	// Job created by a reconciliation, which failed to record it, is adopted
+	created, err := r.createHookJob(ctx, rm, v1.PreHookStage, hook)
+	assert.Nil(t, err)
+	adopted, err := r.createHookJob(ctx, rm, v1.PreHookStage, hook)
+	assert.Nil(t, err)
+	assert.Equal(t, created.UID, adopted.UID)
+
+	// Job of someone else is not
+	assert.Nil(t, r.Delete(ctx, created))
+	assert.Nil(t, r.Create(ctx, foreign))
+	_, err = r.createHookJob(ctx, rm, v1.PreHookStage, hook)
+	assert.NotNil(t, err)
+
+	// missing Job fails the hook
+	rm.Status.Hooks = []v1.HookStatus{{Name: "verify", Stage: v1.PostHookStage, JobName: "missing",
+		Phase: v1.HookRunning, StartTime: metav1.Now()}}
+	status, err := r.runHook(ctx, rm, v1.PostHookStage, v1.Hook{Name: "verify"})
+	assert.Nil(t, err)
+	assert.Equal(t, v1.HookFailed, status.Phase)
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKey{Name: "missing", Namespace: "test-ns"},
+		&batchv1.Job{})))
+}
+
+func TestHookJobName(t *testing.T) {
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("long-name", 10), UID: "rm-uid", Generation: 1},
+	}
+
+	name := hookJobName(rm, v1.PreHookStage, "backup")
+	assert.LessOrEqual(t, len(name), maxJobNameLength)
+	assert.Contains(t, name, "-pre-backup-")
+	assert.Equal(t, name, hookJobName(rm, v1.PreHookStage, "backup"))
+
+	// every execution runs new Jobs
+	rm.Status.FailedAttempts = 1
+	assert.NotEqual(t, name, hookJobName(rm, v1.PreHookStage, "backup"))
+	rm.Status.FailedAttempts = 0
+	rm.Generation = 2
+	assert.NotEqual(t, name, hookJobName(rm, v1.PreHookStage, "backup"))
+	assert.NotEqual(t, hookJobName(rm, v1.PreHookStage, "backup"), hookJobName(rm, v1.PostHookStage, "backup"))
+}
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/utils/ptr"
+	"math"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+const (
+	// DefaultLockNamespace is a namespace of Leases, which lock cluster-scoped resources, unless the reconciler
+	// specifies its own.
+	DefaultLockNamespace = "default"
+
+	// lockPrefix is a prefix of names of Leases, which lock resources
+	lockPrefix = "annot-resource-modif-lock-"
+
+	// lockTargetAnnotation is an annotation of the Lease, describing the locked resource
+	lockTargetAnnotation = "annot-resource-modif.ericsson.com/target"
+
+	// DefaultLockDuration is how long a Lease locks the resource without being renewed, unless the reconciler
+	// specifies its own.
+	DefaultLockDuration = time.Minute
+
+	// MinLockDuration is the shortest duration of a Lease, which is specified in whole seconds
+	MinLockDuration = time.Second
+
+	// lockRecheckInterval is how often the execution on a resource locked by another ResourceModifier is attempted
+	lockRecheckInterval = 10 * time.Second
+)
+
+// errLocked is returned, when the resource is locked by another ResourceModifier.
+var errLocked = errors.New("resource is locked")
+
+// processLockIdentity identifies this process as the holder of Leases, unless the reconciler specifies its own.
+var processLockIdentity = NewLockIdentity()
+
+// NewLockIdentity returns an identity unique to the process: its hostname, i.e. the name of the pod, and a random
+// suffix, so that replicas of the controller, or its restarts, do not take over Leases of each other.
+func NewLockIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return hostname + "_" + string(uuid.NewUUID())
+}
+
+// withTargetLock runs fn, while the resource is locked by ResourceModifier, so no other ResourceModifier (or another
+// replica of the controller) modifies it meanwhile. The lock is a Lease, which is renewed while fn runs, and deleted
+// when fn returns. It expires after the lock duration, if the controller fails to renew or delete it.
+// If the resource is locked by someone else, an error wrapping errLocked is returned.
+func (r *ResourceModifierReconciler) withTargetLock(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	resource client.Object, fn func() error) error {
+	if !r.TargetLocks {
+		return fn()
+	}
+
+	lease, err := r.acquireLock(ctx, rm, resource)
+	if err != nil {
+		return err
+	}
+	stop, renewed := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(renewed)
+		r.renewLock(ctx, lease, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-renewed
+		r.releaseLock(ctx, lease)
+	}()
+
+	return fn()
+}
+
+// acquireLock creates the Lease locking the resource, or takes over a Lease, which expired, or which is already
+// held by ResourceModifier in this replica of the controller. The holder of the Lease is the ResourceModifier and the
+// lock identity of the reconciler, so two replicas reconciling the same ResourceModifier do not both hold the lock.
+func (r *ResourceModifierReconciler) acquireLock(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	resource client.Object) (*coordinationv1.Lease, error) {
+	target, err := r.describeTarget(resource)
+	if err != nil {
+		return nil, err
+	}
+	holder := rm.Namespace + "/" + rm.Name + "@" + r.lockIdentity()
+	now := metav1.NewMicroTime(time.Now())
+	duration := r.lockDuration()
+
+	lease := &coordinationv1.Lease{}
+	key := client.ObjectKey{Name: lockName(target), Namespace: r.leaseNamespace(resource)}
+	lockCtx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	err = r.Client.Get(lockCtx, key, lease)
+	switch {
+	case apierrors.IsNotFound(err):
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Namespace:   key.Namespace,
+				Annotations: map[string]string{lockTargetAnnotation: target},
+			},
+		}
+	case err != nil:
+		return nil, err
+	case ptr.Deref(lease.Spec.HolderIdentity, "") != holder && !leaseExpired(lease, now.Time):
+		return nil, fmt.Errorf("%w: %s is locked by %s", errLocked, target, ptr.Deref(lease.Spec.HolderIdentity, ""))
+	}
+
+	lease.Spec = coordinationv1.LeaseSpec{
+		HolderIdentity:       &holder,
+		LeaseDurationSeconds: ptr.To(int32(math.Ceil(duration.Seconds()))),
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}
+	if lease.ResourceVersion == "" {
+		err = r.Client.Create(lockCtx, lease)
+	} else {
+		err = r.Client.Update(lockCtx, lease)
+	}
+	if apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err) {
+		// someone else acquired the lock meanwhile
+		return nil, fmt.Errorf("%w: %s is being locked by another holder", errLocked, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// renewLock renews the Lease every third of its duration, until stop is closed, so that it does not expire, while
+// the actions run longer than expected, e.g. because writes are retried. The renewed Lease is stored in lease.
+// The renewal stops, when the Lease was taken over by someone else, other failures are only logged.
+func (r *ResourceModifierReconciler) renewLock(ctx context.Context, lease *coordinationv1.Lease, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewal := lease.DeepCopy()
+		renewal.Spec.RenewTime = ptr.To(metav1.NewMicroTime(time.Now()))
+		renewCtx, cancel := r.operationContext(ctx)
+		err := r.Client.Update(renewCtx, renewal)
+		cancel()
+		switch {
+		case apierrors.IsConflict(err), apierrors.IsNotFound(err):
+			log.FromContext(ctx).Error(err, "Lock was taken over", "lease", client.ObjectKeyFromObject(lease))
+			return
+		case err != nil:
+			log.FromContext(ctx).Error(err, "Error renewing lock", "lease", client.ObjectKeyFromObject(lease))
+		default:
+			*lease = *renewal
+		}
+	}
+}
+
+// releaseLock deletes the Lease, unless it was taken over by someone else meanwhile. The Lease expires anyway,
+// so failures are only logged.
+func (r *ResourceModifierReconciler) releaseLock(ctx context.Context, lease *coordinationv1.Lease) {
+	releaseCtx, cancel := r.operationContext(context.WithoutCancel(ctx))
+	defer cancel()
+
+	err := r.Client.Delete(releaseCtx, lease, client.Preconditions{
+		UID:             &lease.UID,
+		ResourceVersion: &lease.ResourceVersion,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Error releasing lock", "lease", client.ObjectKeyFromObject(lease))
+	}
+}
+
+// lockDuration returns how long a Lease locks the resource without being renewed. Leases are specified in whole
+// seconds, so shorter durations are rounded up to MinLockDuration.
+func (r *ResourceModifierReconciler) lockDuration() time.Duration {
+	if r.LockDuration > 0 {
+		return max(r.LockDuration, MinLockDuration)
+	}
+
+	return DefaultLockDuration
+}
+
+// lockIdentity returns the identity of this replica of the controller as the holder of Leases.
+func (r *ResourceModifierReconciler) lockIdentity() string {
+	if r.LockIdentity != "" {
+		return r.LockIdentity
+	}
+
+	return processLockIdentity
+}
+
+// leaseNamespace returns the namespace of the Lease, which locks the resource: its own namespace, or the lock
+// namespace of the reconciler for cluster-scoped resources.
+func (r *ResourceModifierReconciler) leaseNamespace(resource client.Object) string {
+	if resource.GetNamespace() != "" {
+		return resource.GetNamespace()
+	}
+	if r.LockNamespace != "" {
+		return r.LockNamespace
+	}
+
+	return DefaultLockNamespace
+}
+
+// describeTarget returns the kind, namespace and name of the resource, e.g. "Pod default/web".
+func (r *ResourceModifierReconciler) describeTarget(resource client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %s", gvk.GroupKind(), client.ObjectKeyFromObject(resource)), nil
+}
+
+// lockName returns the name of the Lease locking the target. Names of resources may be too long for a Lease, so
+// a hash of the target is used.
+func lockName(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return lockPrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+// leaseExpired returns true, if the holder of the Lease did not renew it within its duration.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return !now.Before(lease.Spec.RenewTime.Add(duration))
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func newTestLease(name, namespace, holder string, renewed time.Time) *coordinationv1.Lease {
+	renewTime := metav1.NewMicroTime(renewed)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(holder),
+			LeaseDurationSeconds: ptr.To(int32(60)),
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+func TestResourceModifierReconciler_acquireLock(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, coordinationv1.AddToScheme(scheme))
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	node := &v2.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+	rm := &v1.ResourceModifier{ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns"}}
+	podLock := lockName("Pod test-ns/test-pod")
+
+	tests := []struct {
+		name          string
+		resource      client.Object
+		lease         *coordinationv1.Lease
+		wantNamespace string
+		wantLocked    bool
+	}{
+		{
+			name:          "Unlocked resource",
+			resource:      pod,
+			wantNamespace: "test-ns",
+		},
+		{
+			name:          "Cluster-scoped resource is locked in the lock namespace",
+			resource:      node,
+			wantNamespace: "locks",
+		},
+		{
+			name:          "Lock held by the same ResourceModifier",
+			resource:      pod,
+			lease:         newTestLease(podLock, "test-ns", "test-ns/rm-test@replica-a", time.Now()),
+			wantNamespace: "test-ns",
+		},
+		{
+			name:       "Lock held by the same ResourceModifier in another replica",
+			resource:   pod,
+			lease:      newTestLease(podLock, "test-ns", "test-ns/rm-test@replica-b", time.Now()),
+			wantLocked: true,
+		},
+		{
+			name:       "Lock held by another ResourceModifier",
+			resource:   pod,
+			lease:      newTestLease(podLock, "test-ns", "test-ns/other", time.Now()),
+			wantLocked: true,
+		},
+		{
+			name:          "Expired lock is taken over",
+			resource:      pod,
+			lease:         newTestLease(podLock, "test-ns", "test-ns/other", time.Now().Add(-time.Hour)),
+			wantNamespace: "test-ns",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.lease != nil {
+				builder = builder.WithObjects(tt.lease)
+			}
+			r := &ResourceModifierReconciler{
+				Client:        builder.Build(),
+				Scheme:        scheme,
+				TargetLocks:   true,
+				LockNamespace: "locks",
+				LockIdentity:  "replica-a",
+			}
+			ctx := context.Background()
+
+			called := false
+			err := r.withTargetLock(ctx, rm, tt.resource, func() error {
+				called = true
+
+				leases := &coordinationv1.LeaseList{}
+				assert.Nil(t, r.List(ctx, leases))
+				assert.Len(t, leases.Items, 1)
+				assert.Equal(t, tt.wantNamespace, leases.Items[0].Namespace)
+				assert.Equal(t, "test-ns/rm-test@replica-a", *leases.Items[0].Spec.HolderIdentity)
+				assert.Equal(t, int32(DefaultLockDuration.Seconds()), *leases.Items[0].Spec.LeaseDurationSeconds)
+				return nil
+			})
+			if tt.wantLocked {
+				assert.ErrorIs(t, err, errLocked)
+				assert.Contains(t, err.Error(), *tt.lease.Spec.HolderIdentity)
+				assert.False(t, called)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, called)
+
+			// the lock is released
+			leases := &coordinationv1.LeaseList{}
+			assert.Nil(t, r.List(ctx, leases))
+			assert.Empty(t, leases.Items)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_withTargetLock_replicas(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, coordinationv1.AddToScheme(scheme))
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	rm := &v1.ResourceModifier{ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	first := &ResourceModifierReconciler{Client: c, Scheme: scheme, TargetLocks: true, LockIdentity: "replica-a"}
+	second := &ResourceModifierReconciler{Client: c, Scheme: scheme, TargetLocks: true, LockIdentity: "replica-b"}
+	ctx := context.Background()
+
+	// both replicas reconcile the same ResourceModifier, only one of them may modify the resource at once
+	called := false
+	err := first.withTargetLock(ctx, rm, pod, func() error {
+		err := second.withTargetLock(ctx, rm, pod, func() error {
+			called = true
+			return nil
+		})
+		assert.ErrorIs(t, err, errLocked)
+		assert.Contains(t, err.Error(), "test-ns/rm-test@replica-a")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.False(t, called)
+
+	// once released, the lock is acquired by the other replica
+	err = second.withTargetLock(ctx, rm, pod, func() error {
+		called = true
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.True(t, called)
+}
+
+func TestResourceModifierReconciler_withTargetLock_renewal(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, coordinationv1.AddToScheme(scheme))
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	rm := &v1.ResourceModifier{ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns"}}
+	r := &ResourceModifierReconciler{
+		Client:       fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Scheme:       scheme,
+		TargetLocks:  true,
+		LockDuration: time.Second,
+	}
+	ctx := context.Background()
+
+	err := r.withTargetLock(ctx, rm, pod, func() error {
+		// the actions take longer than a third of the lock duration
+		time.Sleep(500 * time.Millisecond)
+
+		lease := &coordinationv1.Lease{}
+		key := client.ObjectKey{Name: lockName("Pod test-ns/test-pod"), Namespace: "test-ns"}
+		assert.Nil(t, r.Get(ctx, key, lease))
+		assert.True(t, lease.Spec.RenewTime.After(lease.Spec.AcquireTime.Time))
+		assert.False(t, leaseExpired(lease, time.Now()))
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// the renewed lock is released
+	leases := &coordinationv1.LeaseList{}
+	assert.Nil(t, r.List(ctx, leases))
+	assert.Empty(t, leases.Items)
+}
+
+func TestResourceModifierReconciler_withTargetLock_shortDuration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, coordinationv1.AddToScheme(scheme))
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	rm := &v1.ResourceModifier{ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns"}}
+	r := &ResourceModifierReconciler{
+		Client:       fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Scheme:       scheme,
+		TargetLocks:  true,
+		LockDuration: 100 * time.Millisecond,
+	}
+	ctx := context.Background()
+
+	// Leases are specified in whole seconds, so the duration is rounded up, rather than truncated to zero
+	err := r.withTargetLock(ctx, rm, pod, func() error {
+		lease := &coordinationv1.Lease{}
+		key := client.ObjectKey{Name: lockName("Pod test-ns/test-pod"), Namespace: "test-ns"}
+		assert.Nil(t, r.Get(ctx, key, lease))
+		assert.Equal(t, int32(1), *lease.Spec.LeaseDurationSeconds)
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestResourceModifierReconciler_Reconcile_locked(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+	assert.Nil(t, coordinationv1.AddToScheme(scheme))
+
+	pod := &v2.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "test-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:cordoned:true"},
+		},
+	}
+	lease := newTestLease(lockName("Pod test-ns/test-pod"), "test-ns", "test-ns/other", time.Now())
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm, lease).
+			WithStatusSubresource(rm).Build(),
+		Scheme:      scheme,
+		Recorder:    record.NewFakeRecorder(10),
+		TargetLocks: true,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+	got := &v1.ResourceModifier{}
+	gotPod := &v2.Pod{}
+
+	// execution on the locked resource is postponed, without counting as a failed attempt
+	for range 2 {
+		result, err := r.Reconcile(ctx, request)
+		assert.Nil(t, err)
+		assert.Equal(t, lockRecheckInterval, result.RequeueAfter)
+	}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+	assert.Empty(t, got.Status.Phase)
+	assert.Zero(t, got.Status.FailedAttempts)
+	assert.NotNil(t, got.Status.NextRetryTime)
+	assert.Contains(t, got.Status.Conditions[v1.StatusLocked], "locked by test-ns/other")
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+	assert.Empty(t, gotPod.Labels["cordoned"])
+
+	// the other ResourceModifier released the lock
+	assert.Nil(t, r.Delete(ctx, lease))
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+	assert.Equal(t, v1.PhaseSucceeded, got.Status.Phase)
+	assert.NotContains(t, got.Status.Conditions, v1.StatusLocked)
+	assert.Nil(t, got.Status.NextRetryTime)
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+	assert.Equal(t, "true", gotPod.Labels["cordoned"])
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(lease), &coordinationv1.Lease{})))
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+const (
+	// reasonPreconditionNotMet is a reason of the Event, which is emitted when the resource does not satisfy
+	// the When expressions
+	reasonPreconditionNotMet = "PreconditionNotMet"
+
+	// preconditionRecheckInterval is how often the When expressions are evaluated again, while they do not hold
+	preconditionRecheckInterval = 30 * time.Second
+)
+
+// errPreconditionNotMet is returned, when the resource does not satisfy the When expressions at the time it is
+// modified.
+var errPreconditionNotMet = errors.New("precondition not met")
+
+// waitForPreconditions checks, whether the resource targeted by ResourceModifier satisfies its When expressions.
+// If it does not, the status is reset to previous, so a due scheduled execution is postponed rather than consumed,
+// and the PreconditionNotMet condition is recorded. Errors finding the resource are left to the execution to report.
+// This only avoids starting an execution (e.g. its hooks), which can not proceed; the expressions are evaluated
+// again by applyBatch, under the lock, on the state of the resource which is modified.
+// In Selector mode, the expressions are evaluated per resource by executeSelector instead.
+func (r *ResourceModifierReconciler) waitForPreconditions(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier, previous annotresourcemodifv1.ResourceModifierStatus) (bool, error) {
+	if len(rm.Spec.When) == 0 || rm.Spec.Mode == annotresourcemodifv1.SelectorMode {
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusPreconditionNotMet)
+		return false, nil
+	}
+
+	resource, err := r.findTarget(ctx, rm.Spec.ResourceData)
+	if err != nil {
+		return false, nil
+	}
+	unmet := unmetPrecondition(ctx, rm, resource)
+	if unmet == "" {
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusPreconditionNotMet)
+		return false, nil
+	}
+
+	err = r.updateWaitingStatus(ctx, rm, previous, annotresourcemodifv1.StatusPreconditionNotMet,
+		reasonPreconditionNotMet, "Precondition not met: "+unmet)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// unmetPrecondition evaluates the When expressions of ResourceModifier against the resource, and describes the first
+// one, which does not hold. Empty, if all of them hold.
+func unmetPrecondition(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier, resource client.Object) string {
+	for _, expression := range rm.Spec.When {
+		holds, err := evaluateExpression(ctx, expression, resource)
+		if err != nil {
+			return err.Error()
+		}
+		if !holds {
+			return fmt.Sprintf("%q is false", expression)
+		}
+	}
+
+	return ""
+}
+
+// describeUnmet describes resources, which did not satisfy the When expressions in Selector mode.
+func describeUnmet(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Precondition not met by %d resources: %s", len(names), strings.Join(names, ", "))
+}
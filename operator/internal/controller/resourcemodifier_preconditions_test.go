@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"testing"
+)
+
+func TestUnmetPrecondition(t *testing.T) {
+	now := metav1.Now()
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns", DeletionTimestamp: &now},
+		Status:     v2.PodStatus{Phase: v2.PodPending},
+	}
+
+	tests := []struct {
+		name      string
+		when      []string
+		wantUnmet string
+	}{
+		{
+			name: "No preconditions",
+		},
+		{
+			name: "All preconditions hold",
+			when: []string{"object.status.phase == 'Pending'", "object.metadata.deletionTimestamp != null"},
+		},
+		{
+			name:      "Precondition does not hold",
+			when:      []string{"object.status.phase == 'Pending'", "object.status.phase == 'Running'"},
+			wantUnmet: `"object.status.phase == 'Running'" is false`,
+		},
+		{
+			name:      "Missing field",
+			when:      []string{"object.spec.nodeName == 'worker-1'"},
+			wantUnmet: "failed to evaluate",
+		},
+		{
+			name:      "Missing field tested with has()",
+			when:      []string{"has(object.spec.nodeName) && object.spec.nodeName == 'worker-1'"},
+			wantUnmet: `is false`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &v1.ResourceModifier{Spec: v1.ResourceModifierSpec{When: tt.when}}
+			got := unmetPrecondition(context.Background(), rm, pod)
+			if tt.wantUnmet == "" {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Contains(t, got, tt.wantUnmet)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_Reconcile_when(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	now := metav1.Now()
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-pod",
+			Namespace:         "test-ns",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"example.com/protect"},
+		},
+		Status: v2.PodStatus{Phase: v2.PodRunning},
+	}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns", Generation: 1},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{Name: "test-pod", Namespace: "test-ns", ResourceType: "pod"},
+			Annotations:  []string{"addLabel:stuck:true"},
+			When:         []string{"object.status.phase == 'Pending' && object.metadata.deletionTimestamp != null"},
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(pod, rm).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+	reconcileRM := func() (ctrl.Result, *v1.ResourceModifier, *v2.Pod) {
+		result, err := r.Reconcile(ctx, request)
+		assert.Nil(t, err)
+
+		gotRM := &v1.ResourceModifier{}
+		assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+		gotPod := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+		return result, gotRM, gotPod
+	}
+
+	// the pod is not in the expected state, so it is not modified
+	result, gotRM, gotPod := reconcileRM()
+	assert.Equal(t, preconditionRecheckInterval, result.RequeueAfter)
+	assert.Empty(t, gotRM.Status.Phase)
+	assert.Contains(t, gotRM.Status.Conditions[v1.StatusPreconditionNotMet], "is false")
+	assert.Empty(t, gotPod.Labels["stuck"])
+
+	gotPod.Status.Phase = v2.PodPending
+	assert.Nil(t, r.Status().Update(ctx, gotPod))
+
+	_, gotRM, gotPod = reconcileRM()
+	assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+	assert.NotContains(t, gotRM.Status.Conditions, v1.StatusPreconditionNotMet)
+	assert.Equal(t, "true", gotPod.Labels["stuck"])
+}
+
+func TestResourceModifierReconciler_Reconcile_whenSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pending := newSelectorTestPod("pending", "uid-1", map[string]string{"app": "web"})
+	pending.Status.Phase = v2.PodPending
+	running := newSelectorTestPod("running", "uid-2", map[string]string{"app": "web"})
+	running.Status.Phase = v2.PodRunning
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns", Generation: 1},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Labels:       map[string]string{"app": "web"},
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:stuck:true"},
+			Mode:        v1.SelectorMode,
+			When:        []string{"object.status.phase == 'Pending'"},
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pending, running, rm).
+			WithStatusSubresource(pending, running, rm).Build(),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+	labelOf := func(name string) string {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: name, Namespace: "test-ns"}, got))
+		return got.Labels["stuck"]
+	}
+
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", labelOf("pending"))
+	assert.Empty(t, labelOf("running"))
+
+	got := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+	assert.Equal(t, []string{"uid-1"}, got.Status.ProcessedUIDs)
+	assert.Equal(t, "Precondition not met by 1 resources: test-ns/running",
+		got.Status.Conditions[v1.StatusPreconditionNotMet])
+
+	// the resource is processed, once it satisfies the preconditions
+	running.Status.Phase = v2.PodPending
+	assert.Nil(t, r.Status().Update(ctx, running))
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", labelOf("running"))
+
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+	assert.ElementsMatch(t, []string{"uid-1", "uid-2"}, got.Status.ProcessedUIDs)
+	assert.NotContains(t, got.Status.Conditions, v1.StatusPreconditionNotMet)
+}
+
+func TestResourceModifierReconciler_Reconcile_whenChangedMeanwhile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"},
+		Status:     v2.PodStatus{Phase: v2.PodPending},
+	}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns", Generation: 1},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{Name: "test-pod", Namespace: "test-ns", ResourceType: "pod"},
+			Annotations:  []string{"addLabel:stuck:true"},
+			When:         []string{"object.status.phase == 'Pending'"},
+		},
+	}
+
+	// the pod starts running after the preconditions were checked, so the first write conflicts
+	conflicted := false
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(pod, rm).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+					opts ...client.PatchOption) error {
+					if _, ok := obj.(*v2.Pod); ok && !conflicted {
+						conflicted = true
+						running := &v2.Pod{}
+						assert.Nil(t, c.Get(ctx, client.ObjectKeyFromObject(obj), running))
+						running.Status.Phase = v2.PodRunning
+						assert.Nil(t, c.Status().Update(ctx, running))
+						return apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, obj.GetName(), nil)
+					}
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	result, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.True(t, conflicted)
+	assert.Equal(t, preconditionRecheckInterval, result.RequeueAfter)
+
+	gotRM := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Contains(t, gotRM.Status.Conditions[v1.StatusPreconditionNotMet], "is false")
+	assert.NotNil(t, gotRM.Status.NextRetryTime)
+	assert.Zero(t, gotRM.Status.FailedAttempts)
+	gotPod := &v2.Pod{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+	assert.Empty(t, gotPod.Labels["stuck"])
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestResourceModifierReconciler_Reconcile_runsOncePerGeneration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+		},
+	}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "test-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:env:prod"},
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	// dropLabel removes the label added by the ResourceModifier, so that the re-execution is observable
+	dropLabel := func() {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+		got.Labels = nil
+		assert.Nil(t, r.Update(ctx, got))
+	}
+	labelOf := func() string {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+		return got.Labels["env"]
+	}
+
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", labelOf())
+
+	gotRM := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+	assert.Equal(t, int64(1), gotRM.Status.ObservedGeneration)
+	assert.NotNil(t, gotRM.Status.CompletionTime)
+
+	// Same generation is not executed again
+	dropLabel()
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Empty(t, labelOf())
+
+	// Changing the rerun annotation executes it again
+	gotRM.Annotations = map[string]string{v1.RerunAnnotation: "1"}
+	assert.Nil(t, r.Update(ctx, gotRM))
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", labelOf())
+
+	// Changing the spec (e.g. runID) executes it again
+	dropLabel()
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, "1", gotRM.Status.ObservedRerun)
+	gotRM.Spec.RunID = "second"
+	gotRM.Generation = 2
+	assert.Nil(t, r.Update(ctx, gotRM))
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", labelOf())
+
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, int64(2), gotRM.Status.ObservedGeneration)
+}
+
+func TestResourceModifierReconciler_Reconcile_failed(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "missing-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:env:prod"},
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(rm).WithStatusSubresource(rm).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+
+	gotRM := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseFailed, gotRM.Status.Phase)
+	assert.NotEmpty(t, gotRM.Status.Conditions[v1.StatusError])
+
+	// Failed execution is terminal as well
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, int64(1), gotRM.Status.ObservedGeneration)
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"slices"
+	"time"
+)
+
+const (
+	// reasonRetrying is a reason of the Event, which is emitted when the failed execution is going to be retried
+	reasonRetrying = "Retrying"
+
+	// reasonFailed is a reason of the Event, which is emitted when the execution failed, and is not retried
+	reasonFailed = "Failed"
+
+	// defaultMaxAttempts is a number of executions, when RetryPolicy does not specify it
+	defaultMaxAttempts = 5
+
+	// defaultInitialBackoff is a delay before the first retry, when RetryPolicy does not specify it
+	defaultInitialBackoff = 10 * time.Second
+
+	// defaultMaxBackoff is a maximum delay between retries, when RetryPolicy does not specify it
+	defaultMaxBackoff = 5 * time.Minute
+)
+
+// defaultRetryOn lists error classes, which are retried, when RetryPolicy does not specify them.
+var defaultRetryOn = []annotresourcemodifv1.ErrorClass{
+	annotresourcemodifv1.ConflictErrorClass,
+	annotresourcemodifv1.UnavailableErrorClass,
+}
+
+// writeBackoff bounds retries of an execution, which failed with a retryable error. The jitter spreads retries of
+// writers competing for the same resource, e.g. a ResourceModifier and HPA updating a Deployment.
+var writeBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 20 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+	Cap:      time.Second,
+}
+
+// classifyError returns the class of the error, which failed the execution.
+func classifyError(err error) annotresourcemodifv1.ErrorClass {
+	switch {
+	case apierrors.IsConflict(err), errors.Is(err, errLocked):
+		return annotresourcemodifv1.ConflictErrorClass
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err):
+		return annotresourcemodifv1.UnavailableErrorClass
+	case apierrors.IsNotFound(err), errors.Is(err, errNoMatchingResource):
+		return annotresourcemodifv1.NotFoundErrorClass
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return annotresourcemodifv1.ForbiddenErrorClass
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return annotresourcemodifv1.InvalidErrorClass
+	}
+
+	return annotresourcemodifv1.OtherErrorClass
+}
+
+// isRetryable returns true, if the error is transient: the resource changed after it was read, or the API server
+// was temporarily unable to handle the request. Such errors are retried immediately, within the execution.
+// Other errors, like invalid actions, missing resources or conflicts with fields owned by other managers, are not.
+func isRetryable(err error) bool {
+	return slices.Contains(defaultRetryOn, classifyError(err))
+}
+
+// postpone records in the condition, why the execution can not proceed yet (e.g. the resource is locked by another
+// ResourceModifier), and schedules it again after the interval. Unlike a failed execution, a postponed one does not
+// count towards the attempts of the RetryPolicy. Returns the time until the next attempt.
+func postpone(rm *annotresourcemodifv1.ResourceModifier, condition string, err error, interval time.Duration,
+	now time.Time) time.Duration {
+	rm.Status.Conditions[condition] = err.Error()
+	retryTime := metav1.NewTime(now.Add(interval))
+	rm.Status.NextRetryTime = &retryTime
+
+	return interval
+}
+
+// getRetryPolicy returns the RetryPolicy of ResourceModifier, with defaults for unspecified fields.
+func getRetryPolicy(rm *annotresourcemodifv1.ResourceModifier) annotresourcemodifv1.RetryPolicy {
+	policy := annotresourcemodifv1.RetryPolicy{}
+	if rm.Spec.RetryPolicy != nil {
+		policy = *rm.Spec.RetryPolicy.DeepCopy()
+	}
+
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	if policy.InitialBackoff == nil {
+		policy.InitialBackoff = &metav1.Duration{Duration: defaultInitialBackoff}
+	}
+	if policy.MaxBackoff == nil {
+		policy.MaxBackoff = &metav1.Duration{Duration: defaultMaxBackoff}
+	}
+	if len(policy.RetryOn) == 0 {
+		policy.RetryOn = defaultRetryOn
+	}
+
+	return policy
+}
+
+// nextRetry records the failed execution in the status, and decides according to the RetryPolicy, whether it is
+// retried. If it is, NextRetryTime is set, and the delay is returned. Otherwise, the Failed condition is set.
+func (r *ResourceModifierReconciler) nextRetry(rm *annotresourcemodifv1.ResourceModifier, err error,
+	now time.Time) (time.Duration, bool) {
+	policy := getRetryPolicy(rm)
+	class := classifyError(err)
+	rm.Status.FailedAttempts++
+	rm.Status.ErrorStatus(err.Error())
+
+	if !slices.Contains(policy.RetryOn, class) {
+		rm.Status.Conditions[annotresourcemodifv1.StatusFailed] = fmt.Sprintf("%s error is not retried: %s", class, err)
+		r.Recorder.Event(rm, v2.EventTypeWarning, reasonFailed, rm.Status.Conditions[annotresourcemodifv1.StatusFailed])
+		return 0, false
+	}
+	if rm.Status.FailedAttempts >= policy.MaxAttempts {
+		rm.Status.Conditions[annotresourcemodifv1.StatusFailed] = fmt.Sprintf("Failed after %d attempts: %s",
+			rm.Status.FailedAttempts, err)
+		r.Recorder.Event(rm, v2.EventTypeWarning, reasonFailed, rm.Status.Conditions[annotresourcemodifv1.StatusFailed])
+		return 0, false
+	}
+
+	delay := retryBackoff(policy, rm.Status.FailedAttempts)
+	retryTime := metav1.NewTime(now.Add(delay))
+	rm.Status.NextRetryTime = &retryTime
+	r.Recorder.Eventf(rm, v2.EventTypeWarning, reasonRetrying, "Attempt %d of %d failed with %s error, retrying in %s",
+		rm.Status.FailedAttempts, policy.MaxAttempts, class, delay.Round(time.Second))
+
+	return delay, true
+}
+
+// retryBackoff returns the delay before the retry, which follows given number of failed attempts. The delay
+// doubles with every attempt, up to MaxBackoff.
+func retryBackoff(policy annotresourcemodifv1.RetryPolicy, failedAttempts int32) time.Duration {
+	delay := policy.InitialBackoff.Duration
+	for attempt := int32(1); attempt < failedAttempts && delay < policy.MaxBackoff.Duration; attempt++ {
+		delay *= 2
+	}
+
+	return min(delay, policy.MaxBackoff.Duration)
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantClass     v1.ErrorClass
+		wantRetryable bool
+	}{
+		{
+			name:          "Conflict",
+			err:           apierrors.NewConflict(pods, "test-pod", nil),
+			wantClass:     v1.ConflictErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:          "Wrapped conflict",
+			err:           fmt.Errorf("pods test-ns/test-pod: %w", apierrors.NewConflict(pods, "test-pod", nil)),
+			wantClass:     v1.ConflictErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:          "Locked resource",
+			err:           fmt.Errorf("%w: Pod test-ns/test-pod is locked by test-ns/other", errLocked),
+			wantClass:     v1.ConflictErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:          "Server timeout",
+			err:           apierrors.NewServerTimeout(pods, "patch", 1),
+			wantClass:     v1.UnavailableErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:          "Too many requests",
+			err:           apierrors.NewTooManyRequests("slow down", 1),
+			wantClass:     v1.UnavailableErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:          "Service unavailable",
+			err:           apierrors.NewServiceUnavailable("unavailable"),
+			wantClass:     v1.UnavailableErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:          "Internal error",
+			err:           apierrors.NewInternalError(errors.New("etcd")),
+			wantClass:     v1.UnavailableErrorClass,
+			wantRetryable: true,
+		},
+		{
+			name:      "Not found",
+			err:       apierrors.NewNotFound(pods, "test-pod"),
+			wantClass: v1.NotFoundErrorClass,
+		},
+		{
+			name:      "No resource matches labels",
+			err:       fmt.Errorf("%w%v", errNoMatchingResource, map[string]string{"app": "test"}),
+			wantClass: v1.NotFoundErrorClass,
+		},
+		{
+			name:      "Forbidden",
+			err:       apierrors.NewForbidden(pods, "test-pod", nil),
+			wantClass: v1.ForbiddenErrorClass,
+		},
+		{
+			name:      "Invalid",
+			err:       apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "test-pod", nil),
+			wantClass: v1.InvalidErrorClass,
+		},
+		{
+			name:      "Field manager conflict",
+			err:       &fieldConflictError{},
+			wantClass: v1.OtherErrorClass,
+		},
+		{
+			name:      "Invalid annotation",
+			err:       errors.New("Invalid annotation sleep:50"),
+			wantClass: v1.OtherErrorClass,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantClass, classifyError(tt.err))
+			assert.Equal(t, tt.wantRetryable, isRetryable(tt.err))
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := getRetryPolicy(&v1.ResourceModifier{Spec: v1.ResourceModifierSpec{
+		RetryPolicy: &v1.RetryPolicy{
+			InitialBackoff: &metav1.Duration{Duration: time.Second},
+			MaxBackoff:     &metav1.Duration{Duration: 5 * time.Second},
+		},
+	}})
+
+	tests := []struct {
+		failedAttempts int32
+		want           time.Duration
+	}{
+		{failedAttempts: 1, want: time.Second},
+		{failedAttempts: 2, want: 2 * time.Second},
+		{failedAttempts: 3, want: 4 * time.Second},
+		{failedAttempts: 4, want: 5 * time.Second},
+		{failedAttempts: 100, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.failedAttempts), func(t *testing.T) {
+			assert.Equal(t, tt.want, retryBackoff(policy, tt.failedAttempts))
+		})
+	}
+}
+
+func TestResourceModifierReconciler_Reconcile_retryPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "missing-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:env:prod"},
+			RetryPolicy: &v1.RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: &metav1.Duration{Duration: time.Minute},
+				RetryOn:        []v1.ErrorClass{v1.NotFoundErrorClass},
+			},
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(rm).WithStatusSubresource(rm).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+	gotRM := &v1.ResourceModifier{}
+
+	// missing target is retried with a doubling backoff
+	for attempt, wantBackoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		result, err := r.Reconcile(ctx, request)
+		assert.Nil(t, err)
+		assert.Equal(t, wantBackoff, result.RequeueAfter)
+
+		assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+		assert.Empty(t, gotRM.Status.Phase)
+		assert.Equal(t, int32(attempt+1), gotRM.Status.FailedAttempts)
+		assert.NotNil(t, gotRM.Status.NextRetryTime)
+		assert.NotEmpty(t, gotRM.Status.Conditions[v1.StatusError])
+		assert.NotContains(t, gotRM.Status.Conditions, v1.StatusFailed)
+	}
+
+	// the last attempt fails the execution
+	result, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseFailed, gotRM.Status.Phase)
+	assert.Equal(t, int32(3), gotRM.Status.FailedAttempts)
+	assert.Nil(t, gotRM.Status.NextRetryTime)
+	assert.Contains(t, gotRM.Status.Conditions[v1.StatusFailed], "Failed after 3 attempts")
+
+	// failed execution is not retried anymore
+	result, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, int32(3), gotRM.Status.FailedAttempts)
+}
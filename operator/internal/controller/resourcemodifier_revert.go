@@ -0,0 +1,336 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sort"
+	"strings"
+)
+
+const (
+	// reasonReverted is a reason of the Event, which is emitted when modified fields were restored
+	reasonReverted = "Reverted"
+
+	// reasonRevertSkipped is a reason of the Event, which is emitted when fields changed by others were not restored
+	reasonRevertSkipped = "RevertSkipped"
+)
+
+// untrackedMetadata lists metadata fields, which are maintained by the API server, and are never reverted.
+var untrackedMetadata = map[string]struct{}{
+	"resourceVersion":            {},
+	"managedFields":              {},
+	"generation":                 {},
+	"uid":                        {},
+	"creationTimestamp":          {},
+	"deletionTimestamp":          {},
+	"deletionGracePeriodSeconds": {},
+	"selfLink":                   {},
+}
+
+// recordChanges records fields of the resource, which were changed by ResourceModifier, into its RevertRecords.
+// If a field was already recorded, its previous value is kept, so reverting restores the state before the
+// first modification.
+func (r *ResourceModifierReconciler) recordChanges(rm *annotresourcemodifv1.ResourceModifier, original,
+	modified client.Object) error {
+	changes, err := changedFields(original, modified)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	uid := string(original.GetUID())
+	for i := range rm.Status.RevertRecords {
+		if rm.Status.RevertRecords[i].UID == uid {
+			rm.Status.RevertRecords[i].Fields = mergeFieldChanges(rm.Status.RevertRecords[i].Fields, changes)
+			return nil
+		}
+	}
+	record, err := r.newRevertRecord(original, changes)
+	if err != nil {
+		return err
+	}
+	rm.Status.RevertRecords = append(rm.Status.RevertRecords, record)
+
+	return nil
+}
+
+// newRevertRecord returns a record of the changes of the resource, identifying it by its kind, so it is reverted,
+// even if the resource type of ResourceModifier changes meanwhile.
+func (r *ResourceModifierReconciler) newRevertRecord(resource client.Object,
+	changes []annotresourcemodifv1.FieldChange) (annotresourcemodifv1.RevertRecord, error) {
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		return annotresourcemodifv1.RevertRecord{}, err
+	}
+
+	return annotresourcemodifv1.RevertRecord{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		UID:        string(resource.GetUID()),
+		Name:       resource.GetName(),
+		Namespace:  resource.GetNamespace(),
+		Fields:     changes,
+	}, nil
+}
+
+// recordKind returns the kind of the resource of the record, or of the resource type of ResourceModifier for records
+// written before kinds were recorded.
+func (r *ResourceModifierReconciler) recordKind(rm *annotresourcemodifv1.ResourceModifier,
+	record annotresourcemodifv1.RevertRecord) (schema.GroupVersionKind, error) {
+	if record.Kind != "" {
+		return schema.FromAPIVersionAndKind(record.APIVersion, record.Kind), nil
+	}
+	resource, err := r.determineResourceType(rm.Spec.ResourceData)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+
+	return apiutil.GVKForObject(resource, r.Scheme)
+}
+
+// changedFields returns fields of the resource, which differ between original and modified. Status and metadata
+// maintained by the API server are ignored.
+func changedFields(original, modified client.Object) ([]annotresourcemodifv1.FieldChange, error) {
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(original)
+	if err != nil {
+		return nil, err
+	}
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []annotresourcemodifv1.FieldChange
+	for _, key := range unionKeys(before, after) {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			beforeMeta, _ := before[key].(map[string]interface{})
+			afterMeta, _ := after[key].(map[string]interface{})
+			for _, metaKey := range unionKeys(beforeMeta, afterMeta) {
+				if _, untracked := untrackedMetadata[metaKey]; untracked {
+					continue
+				}
+				if err = diffField("/metadata/"+escapePointer(metaKey), beforeMeta, afterMeta, metaKey, &changes); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if err = diffField("/"+escapePointer(key), before, after, key, &changes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// diffField compares values of key in before and after, and appends the changes to changes. Nested objects are
+// compared field by field, any other values (including lists) are compared as a whole.
+func diffField(path string, before, after map[string]interface{}, key string,
+	changes *[]annotresourcemodifv1.FieldChange) error {
+	beforeValue, beforeExists := before[key]
+	afterValue, afterExists := after[key]
+
+	beforeMap, beforeIsMap := beforeValue.(map[string]interface{})
+	afterMap, afterIsMap := afterValue.(map[string]interface{})
+	if beforeIsMap && afterIsMap {
+		for _, nestedKey := range unionKeys(beforeMap, afterMap) {
+			if err := diffField(path+"/"+escapePointer(nestedKey), beforeMap, afterMap, nestedKey, changes); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	previous, err := encodeField(beforeValue, beforeExists)
+	if err != nil {
+		return err
+	}
+	applied, err := encodeField(afterValue, afterExists)
+	if err != nil {
+		return err
+	}
+	if equalFields(previous, applied) {
+		return nil
+	}
+
+	*changes = append(*changes, annotresourcemodifv1.FieldChange{Path: path, Previous: previous, Applied: applied})
+	return nil
+}
+
+// mergeFieldChanges merges changes into recorded changes, keeping the previous values of already recorded fields.
+func mergeFieldChanges(recorded, changes []annotresourcemodifv1.FieldChange) []annotresourcemodifv1.FieldChange {
+	for _, change := range changes {
+		found := false
+		for i := range recorded {
+			if recorded[i].Path == change.Path {
+				recorded[i].Applied = change.Applied
+				found = true
+				break
+			}
+		}
+		if !found {
+			recorded = append(recorded, change)
+		}
+	}
+
+	return recorded
+}
+
+// revert restores fields recorded in RevertRecords of ResourceModifier. A field is restored only if its current
+// value is still the one set by ResourceModifier, other fields are reported in an Event and skipped.
+func (r *ResourceModifierReconciler) revert(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier) error {
+	return r.revertRecords(ctx, rm, rm.Status.RevertRecords)
+}
+
+// revertRecords restores fields of the records, as revert does.
+func (r *ResourceModifierReconciler) revertRecords(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	records []annotresourcemodifv1.RevertRecord) error {
+	for _, record := range records {
+		gvk, err := r.recordKind(rm, record)
+		if err != nil {
+			return err
+		}
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(gvk)
+
+		getCtx, cancel := r.operationContext(ctx)
+		err = r.Client.Get(getCtx, client.ObjectKey{Name: record.Name, Namespace: record.Namespace}, current)
+		cancel()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if current.GetUID() != types.UID(record.UID) {
+			continue
+		}
+
+		before := current.DeepCopy()
+		var reverted, skipped []string
+		for _, change := range record.Fields {
+			restored, err := restoreField(current.Object, change)
+			if err != nil {
+				return fmt.Errorf("failed to revert %s of %s/%s: %w", change.Path, record.Namespace, record.Name, err)
+			}
+			if restored {
+				reverted = append(reverted, change.Path)
+			} else {
+				skipped = append(skipped, change.Path)
+			}
+		}
+
+		if len(reverted) > 0 {
+			patchCtx, cancel := r.operationContext(ctx)
+			patch := client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{})
+			err = r.Client.Patch(patchCtx, current, patch, client.FieldOwner(FieldManager))
+			cancel()
+			if err != nil {
+				return err
+			}
+			r.Recorder.Eventf(rm, v2.EventTypeNormal, reasonReverted, "Restored %s of %s/%s",
+				strings.Join(reverted, ", "), record.Namespace, record.Name)
+		}
+		if len(skipped) > 0 {
+			r.Recorder.Eventf(rm, v2.EventTypeWarning, reasonRevertSkipped,
+				"Not restored %s of %s/%s, which were changed by others", strings.Join(skipped, ", "),
+				record.Namespace, record.Name)
+		}
+	}
+
+	return nil
+}
+
+// restoreField sets the field at the path of the change to its previous value, if its current value is the
+// applied one. Returns false, if the field was changed by someone else.
+func restoreField(object map[string]interface{}, change annotresourcemodifv1.FieldChange) (bool, error) {
+	segments := splitPointer(change.Path)
+
+	value, exists, err := unstructured.NestedFieldNoCopy(object, segments...)
+	if err != nil {
+		return false, err
+	}
+	current, err := encodeField(value, exists)
+	if err != nil {
+		return false, err
+	}
+	if !equalFields(current, change.Applied) {
+		return false, nil
+	}
+
+	if change.Previous == nil {
+		unstructured.RemoveNestedField(object, segments...)
+		return true, nil
+	}
+
+	var previous interface{}
+	if err = json.Unmarshal([]byte(*change.Previous), &previous); err != nil {
+		return false, err
+	}
+	return true, unstructured.SetNestedField(object, previous, segments...)
+}
+
+// encodeField returns the JSON encoding of the value, or nil if the field does not exist.
+func encodeField(value interface{}, exists bool) (*string, error) {
+	if !exists {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	result := string(encoded)
+	return &result, nil
+}
+
+// equalFields compares JSON-encoded fields, nil meaning absent field.
+func equalFields(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// unionKeys returns sorted keys present in any of the maps.
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// escapePointer escapes a key to be used as a segment of a JSON pointer (RFC 6901).
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// splitPointer splits a JSON pointer into unescaped segments.
+func splitPointer(path string) []string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+
+	return segments
+}
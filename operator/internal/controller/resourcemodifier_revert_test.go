@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+)
+
+func TestResourceModifierReconciler_recordChanges(t *testing.T) {
+	original := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-pod",
+			Namespace:       "test-ns",
+			UID:             "test-uid",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app.kubernetes.io/name": "web", "env": "dev"},
+		},
+	}
+	modified := original.DeepCopy()
+	modified.ResourceVersion = "2"
+	modified.Labels = map[string]string{"app.kubernetes.io/name": "api", "team": "sre"}
+	modified.Finalizers = []string{"test-finalizer"}
+
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v2.AddToScheme(scheme))
+	rm := &v1.ResourceModifier{}
+	r := &ResourceModifierReconciler{Scheme: scheme}
+	assert.Nil(t, r.recordChanges(rm, original, modified))
+
+	assert.Len(t, rm.Status.RevertRecords, 1)
+	record := rm.Status.RevertRecords[0]
+	assert.Equal(t, "test-uid", record.UID)
+	assert.Equal(t, "v1", record.APIVersion)
+	assert.Equal(t, "Pod", record.Kind)
+
+	changes := make(map[string]v1.FieldChange)
+	for _, change := range record.Fields {
+		changes[change.Path] = change
+	}
+	assert.Len(t, changes, 4)
+	assert.Equal(t, `"web"`, *changes["/metadata/labels/app.kubernetes.io~1name"].Previous)
+	assert.Nil(t, changes["/metadata/labels/env"].Applied)
+	assert.Nil(t, changes["/metadata/labels/team"].Previous)
+	assert.Equal(t, `["test-finalizer"]`, *changes["/metadata/finalizers"].Applied)
+
+	// Second modification keeps the value before the first one
+	again := modified.DeepCopy()
+	again.ResourceVersion = "3"
+	again.Labels["app.kubernetes.io/name"] = "worker"
+	assert.Nil(t, r.recordChanges(rm, modified, again))
+
+	assert.Len(t, rm.Status.RevertRecords, 1)
+	for _, change := range rm.Status.RevertRecords[0].Fields {
+		if change.Path == "/metadata/labels/app.kubernetes.io~1name" {
+			assert.Equal(t, `"web"`, *change.Previous)
+			assert.Equal(t, `"worker"`, *change.Applied)
+		}
+	}
+}
+
+func TestResourceModifierReconciler_Reconcile_revertOnDelete(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-pod",
+			Namespace:  "test-ns",
+			UID:        "test-uid",
+			Labels:     map[string]string{"env": "dev", "app.kubernetes.io/name": "web"},
+			Finalizers: []string{"test-finalizer"},
+		},
+	}
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "test-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{
+				"removeAnyFinalizers",
+				"removeLabel:env",
+				"removeLabel:app.kubernetes.io/name",
+				"addLabel:team:sre",
+				"addLabel:incident:42",
+			},
+			RevertOnDelete: true,
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+
+	gotRM := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+	assert.Equal(t, []string{v1.RevertFinalizer}, gotRM.Finalizers)
+	assert.Len(t, gotRM.Status.RevertRecords, 1)
+
+	got := &v2.Pod{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+	assert.Empty(t, got.Finalizers)
+	assert.Equal(t, map[string]string{"team": "sre", "incident": "42"}, got.Labels)
+
+	// Someone else changes one of the modified fields in the meantime
+	got.Labels["team"] = "platform"
+	assert.Nil(t, r.Update(ctx, got))
+
+	assert.Nil(t, r.Delete(ctx, gotRM))
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+	assert.Equal(t, []string{"test-finalizer"}, got.Finalizers)
+	assert.Equal(t, map[string]string{"env": "dev", "app.kubernetes.io/name": "web", "team": "platform"}, got.Labels)
+
+	err = r.Get(ctx, request.NamespacedName, gotRM)
+	assert.True(t, apierrors.IsNotFound(err))
+
+	assert.Len(t, recorder.Events, 2)
+	reverted := <-recorder.Events
+	assert.True(t, strings.HasPrefix(reverted, "Normal "+reasonReverted))
+	skipped := <-recorder.Events
+	assert.True(t, strings.HasPrefix(skipped, "Warning "+reasonRevertSkipped))
+	assert.Contains(t, skipped, "/metadata/labels/team")
+}
+
+func TestResourceModifierReconciler_revertRecords_changedResourceType(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+			UID:       "test-uid",
+			Labels:    map[string]string{"team": "sre"},
+		},
+	}
+	// the resource type was changed, after the pod was modified
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "rm-test", Namespace: "test-ns"},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{Name: "test-pod", Namespace: "test-ns", ResourceType: "service"},
+		},
+	}
+	applied := `"sre"`
+	records := []v1.RevertRecord{{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		UID:        "test-uid",
+		Name:       "test-pod",
+		Namespace:  "test-ns",
+		Fields:     []v1.FieldChange{{Path: "/metadata/labels/team", Applied: &applied}},
+	}}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	assert.Nil(t, r.revertRecords(ctx, rm, records))
+
+	got := &v2.Pod{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+	assert.Empty(t, got.Labels["team"])
+}
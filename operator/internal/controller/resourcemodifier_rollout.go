@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"cmp"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"slices"
+	"time"
+)
+
+const (
+	// reasonRolloutBatch is a reason of the Event, which is emitted when a batch of the rollout starts
+	reasonRolloutBatch = "RolloutBatch"
+
+	// reasonRolloutAborted is a reason of the Event, which is emitted when too many resources failed
+	reasonRolloutAborted = "RolloutAborted"
+)
+
+// isRolloutAborted returns true, if the rollout of ResourceModifier was aborted.
+func isRolloutAborted(rm *annotresourcemodifv1.ResourceModifier) bool {
+	return rm.Status.Rollout != nil && rm.Status.Rollout.Aborted
+}
+
+// untilNextBatch returns the time left until the pause after the last batch ends, or zero if there is no pause.
+func untilNextBatch(rm *annotresourcemodifv1.ResourceModifier) time.Duration {
+	if rm.Status.Rollout == nil || rm.Status.Rollout.NextBatchTime == nil {
+		return 0
+	}
+
+	left := time.Until(rm.Status.Rollout.NextBatchTime.Time)
+	if left <= 0 {
+		// requeue immediately, zero would mean no requeue at all
+		return time.Nanosecond
+	}
+	return left
+}
+
+// scaledValue resolves a number or a percentage of total. Nil resolves to def.
+func scaledValue(value *intstr.IntOrString, total int, roundUp bool, def int) (int, error) {
+	if value == nil {
+		return def, nil
+	}
+	return intstr.GetScaledValueFromIntOrPercent(value, total, roundUp)
+}
+
+// nextBatch selects resources of the next batch from the pending ones, in the order of their names, and records
+// the progress of the rollout. Resources, which failed before, are skipped. No resources are selected, while
+// the pause after the previous batch did not end.
+func (r *ResourceModifierReconciler) nextBatch(rm *annotresourcemodifv1.ResourceModifier, pending []client.Object,
+	targets int, now time.Time) ([]client.Object, error) {
+	if rm.Status.Rollout == nil {
+		rm.Status.Rollout = &annotresourcemodifv1.RolloutStatus{}
+	}
+	status := rm.Status.Rollout
+	status.Targets = int32(targets)
+	if status.Aborted {
+		return nil, fmt.Errorf("rollout aborted: %d resources failed", len(status.FailedUIDs))
+	}
+
+	pending = slices.DeleteFunc(slices.Clone(pending), func(resource client.Object) bool {
+		return slices.Contains(status.FailedUIDs, string(resource.GetUID()))
+	})
+	if len(pending) == 0 {
+		status.NextBatchTime = nil
+		return nil, nil
+	}
+	if status.NextBatchTime != nil && now.Before(status.NextBatchTime.Time) {
+		return nil, nil
+	}
+
+	rollout := rm.Spec.Rollout
+	size, err := scaledValue(rollout.BatchSize, targets, true, targets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batchSize: %w", err)
+	}
+	if status.Batch == 0 {
+		if size, err = scaledValue(rollout.Canary, targets, true, size); err != nil {
+			return nil, fmt.Errorf("invalid canary: %w", err)
+		}
+	}
+	size = min(max(size, 1), len(pending))
+
+	slices.SortFunc(pending, func(a, b client.Object) int {
+		return cmp.Compare(a.GetName(), b.GetName())
+	})
+	batch := pending[:size]
+
+	status.Batch++
+	status.NextBatchTime = nil
+	if len(pending) > size && rollout.Pause != nil {
+		next := metav1.NewTime(now.Add(rollout.Pause.Duration))
+		status.NextBatchTime = &next
+	}
+	r.Recorder.Eventf(rm, v2.EventTypeNormal, reasonRolloutBatch, "Started batch %d with %d of %d pending resources",
+		status.Batch, size, len(pending))
+
+	return batch, nil
+}
+
+// recordRolloutFailures records resources, which failed in the batch, and aborts the rollout, if more resources
+// failed than allowed. Returns an error, if the rollout was aborted.
+func (r *ResourceModifierReconciler) recordRolloutFailures(rm *annotresourcemodifv1.ResourceModifier,
+	failedUIDs []string, processed int, firstErr error) error {
+	status := rm.Status.Rollout
+	status.Processed = int32(processed)
+	if len(failedUIDs) == 0 {
+		return nil
+	}
+	for _, uid := range failedUIDs {
+		if !slices.Contains(status.FailedUIDs, uid) {
+			status.FailedUIDs = append(status.FailedUIDs, uid)
+		}
+	}
+
+	maxFailures, err := scaledValue(rm.Spec.Rollout.MaxFailures, int(status.Targets), false, 0)
+	if err != nil {
+		return fmt.Errorf("invalid maxFailures: %w", err)
+	}
+	if len(status.FailedUIDs) <= maxFailures {
+		return nil
+	}
+
+	status.Aborted = true
+	status.NextBatchTime = nil
+	message := fmt.Sprintf("Rollout aborted: %d of %d resources failed, at most %d allowed", len(status.FailedUIDs),
+		status.Targets, maxFailures)
+	r.Recorder.Event(rm, v2.EventTypeWarning, reasonRolloutAborted, message)
+
+	// the rollout is not retried, whatever the error of the resource
+	return fmt.Errorf("%s: %v", message, firstErr)
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"testing"
+	"time"
+)
+
+func TestResourceModifierReconciler_Reconcile_rollout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	tests := []struct {
+		name        string
+		rollout     v1.Rollout
+		failing     map[string]bool
+		wantBatches [][]string
+		wantAborted bool
+	}{
+		{
+			name: "Canary followed by batches",
+			rollout: v1.Rollout{
+				Canary:    &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+				BatchSize: &intstr.IntOrString{Type: intstr.String, StrVal: "40%"},
+				Pause:     &metav1.Duration{Duration: time.Hour},
+			},
+			wantBatches: [][]string{{"node-0"}, {"node-1", "node-2"}, {"node-3", "node-4"}},
+		},
+		{
+			name: "Failures within the threshold",
+			rollout: v1.Rollout{
+				BatchSize:   &intstr.IntOrString{Type: intstr.Int, IntVal: 3},
+				MaxFailures: &intstr.IntOrString{Type: intstr.String, StrVal: "20%"},
+			},
+			failing:     map[string]bool{"node-1": true},
+			wantBatches: [][]string{{"node-0", "node-2"}, {"node-3", "node-4"}},
+		},
+		{
+			name: "Too many failures abort the rollout",
+			rollout: v1.Rollout{
+				BatchSize:   &intstr.IntOrString{Type: intstr.Int, IntVal: 2},
+				MaxFailures: &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+			},
+			failing:     map[string]bool{"node-1": true, "node-2": true},
+			wantBatches: [][]string{{"node-0"}, {"node-3"}},
+			wantAborted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "rm-test",
+					Namespace:  "test-ns",
+					Generation: 1,
+				},
+				Spec: v1.ResourceModifierSpec{
+					ResourceData: v1.TargetResourceData{
+						Labels:       map[string]string{"app": "web"},
+						Namespace:    "test-ns",
+						ResourceType: "pod",
+					},
+					Annotations: []string{"addLabel:drained:true"},
+					Mode:        v1.SelectorMode,
+					Rollout:     &tt.rollout,
+				},
+			}
+			objects := []client.Object{rm}
+			// created in reverse, so the batches do not depend on the order of creation
+			for i := 4; i >= 0; i-- {
+				name := fmt.Sprintf("node-%d", i)
+				objects = append(objects, newSelectorTestPod(name, types.UID("uid-"+name), map[string]string{"app": "web"}))
+			}
+
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithStatusSubresource(rm).
+					WithInterceptorFuncs(interceptor.Funcs{
+						Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+							opts ...client.PatchOption) error {
+							if tt.failing[obj.GetName()] {
+								return apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, obj.GetName(), nil)
+							}
+							return c.Patch(ctx, obj, patch, opts...)
+						},
+					}).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(20),
+			}
+			ctx := context.Background()
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+			got := &v1.ResourceModifier{}
+			drained := func() []string {
+				pods := &v2.PodList{}
+				assert.Nil(t, r.List(ctx, pods))
+				var names []string
+				for _, pod := range pods.Items {
+					if pod.Labels["drained"] == "true" {
+						names = append(names, pod.Name)
+					}
+				}
+				return names
+			}
+
+			var wantDrained []string
+			for i, batch := range tt.wantBatches {
+				result, err := r.Reconcile(ctx, request)
+				assert.Nil(t, err)
+				wantDrained = append(wantDrained, batch...)
+				assert.ElementsMatch(t, wantDrained, drained(), "batch %d", i+1)
+
+				assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+				assert.Equal(t, int32(i+1), got.Status.Rollout.Batch)
+				assert.Equal(t, int32(5), got.Status.Rollout.Targets)
+				assert.Equal(t, int32(len(wantDrained)), got.Status.Rollout.Processed)
+				if tt.rollout.Pause == nil || i == len(tt.wantBatches)-1 {
+					assert.Nil(t, got.Status.Rollout.NextBatchTime)
+					continue
+				}
+				assert.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+
+				// nothing is modified during the pause
+				_, err = r.Reconcile(ctx, request)
+				assert.Nil(t, err)
+				assert.ElementsMatch(t, wantDrained, drained())
+
+				past := metav1.NewTime(time.Now().Add(-time.Second))
+				got.Status.Rollout.NextBatchTime = &past
+				assert.Nil(t, r.Status().Update(ctx, got))
+			}
+
+			assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+			assert.Equal(t, tt.wantAborted, got.Status.Rollout.Aborted)
+			assert.Len(t, got.Status.Rollout.FailedUIDs, len(tt.failing))
+			if tt.wantAborted {
+				assert.Equal(t, v1.PhaseFailed, got.Status.Phase)
+			} else {
+				assert.Equal(t, v1.PhaseSucceeded, got.Status.Phase)
+			}
+
+			// the rollout is finished or aborted, nothing else is modified
+			_, err := r.Reconcile(ctx, request)
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, wantDrained, drained())
+		})
+	}
+}
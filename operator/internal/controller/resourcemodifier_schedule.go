@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	v2 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"time"
+)
+
+const (
+	// reasonMissedSchedule is a reason of the Event, which is emitted when a scheduled execution was skipped
+	reasonMissedSchedule = "MissedSchedule"
+
+	// invalidSchedule is an error message indicating that schedule or time zone is malformed
+	invalidSchedule = "Invalid schedule "
+
+	// maxMissedSchedules limits how many missed scheduled times are looked through one by one, as CronJob does,
+	// so that a frequent schedule missed for long does not stall the reconciliation
+	maxMissedSchedules = 100
+)
+
+// ValidateSchedule checks that the schedule is a valid cron expression, and that the time zone is known.
+func ValidateSchedule(schedule string, timeZone *string) error {
+	_, _, err := parseSchedule(schedule, timeZone)
+	return err
+}
+
+// parseSchedule parses the cron expression, and loads the time zone in which it is interpreted.
+func parseSchedule(schedule string, timeZone *string) (cron.Schedule, *time.Location, error) {
+	if strings.Contains(schedule, "TZ=") {
+		return nil, nil, fmt.Errorf("%s%s: use timeZone instead of TZ or CRON_TZ", invalidSchedule, schedule)
+	}
+
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s%s: %w", invalidSchedule, schedule, err)
+	}
+
+	location := time.Local
+	if timeZone != nil {
+		location, err = time.LoadLocation(*timeZone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s%s: unknown time zone %s", invalidSchedule, schedule, *timeZone)
+		}
+	}
+
+	return parsed, location, nil
+}
+
+// isScheduled returns true, if ResourceModifier is executed at scheduled times, rather than immediately.
+func isScheduled(rm *annotresourcemodifv1.ResourceModifier) bool {
+	return rm.Spec.Schedule != "" || rm.Spec.ExecuteAt != nil
+}
+
+// scheduledTimes returns the latest scheduled time in (since, now], and the first scheduled time after now.
+// If more than maxMissedSchedules times passed in between, the latest one is searched for from now instead, and
+// tooMany is true.
+func scheduledTimes(rm *annotresourcemodifv1.ResourceModifier, since, now time.Time) (*time.Time, *time.Time, bool,
+	error) {
+	if rm.Spec.ExecuteAt != nil {
+		executeAt := rm.Spec.ExecuteAt.Time
+		if executeAt.After(now) {
+			return nil, &executeAt, false, nil
+		}
+		if executeAt.After(since) {
+			return &executeAt, nil, false, nil
+		}
+		return nil, nil, false, nil
+	}
+
+	schedule, location, err := parseSchedule(rm.Spec.Schedule, rm.Spec.TimeZone)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var latest *time.Time
+	tooMany := false
+	upcoming := schedule.Next(since.In(location))
+	for missed := 0; !upcoming.After(now); missed++ {
+		if missed == maxMissedSchedules {
+			scheduled, following := latestScheduledTime(schedule, upcoming, now)
+			latest, upcoming, tooMany = &scheduled, following, true
+			break
+		}
+		scheduled := upcoming
+		latest = &scheduled
+		upcoming = schedule.Next(upcoming)
+	}
+	if upcoming.IsZero() {
+		return latest, nil, tooMany, nil
+	}
+
+	return latest, &upcoming, tooMany, nil
+}
+
+// latestScheduledTime returns the latest scheduled time not after now, and the first one after it, given that first
+// is a scheduled time not after now. It is searched for in a window before now, as long as an interval of
+// the schedule at first, which is doubled until it contains a scheduled time, rather than going through all
+// scheduled times since first.
+func latestScheduledTime(schedule cron.Schedule, first, now time.Time) (time.Time, time.Time) {
+	window := max(schedule.Next(first).Sub(first), time.Second)
+	for {
+		start := now.Add(-window).In(first.Location())
+		if start.Before(first) {
+			start = first.Add(-time.Nanosecond)
+		}
+		latest := schedule.Next(start)
+		if !latest.After(now) {
+			next := schedule.Next(latest)
+			for !next.IsZero() && !next.After(now) {
+				latest, next = next, schedule.Next(next)
+			}
+			return latest, next
+		}
+		window *= 2
+	}
+}
+
+// dueRun returns the scheduled time of the execution, which is due now, or nil if no execution is due.
+// It updates LastScheduleTime and NextScheduleTime in the status. A due execution, which was missed by more than
+// StartingDeadlineSeconds, is skipped. If too many executions were missed, the TooManyMissedSchedules condition is
+// set, and only the latest of them is due.
+func (r *ResourceModifierReconciler) dueRun(rm *annotresourcemodifv1.ResourceModifier, now time.Time) (*time.Time, error) {
+	// ExecuteAt which passed before ResourceModifier was created is executed, cron schedule starts at the creation
+	since := now
+	if rm.Status.LastScheduleTime != nil {
+		since = rm.Status.LastScheduleTime.Time
+	} else if rm.Spec.ExecuteAt != nil {
+		since = time.Time{}
+	} else if !rm.CreationTimestamp.IsZero() {
+		since = rm.CreationTimestamp.Time
+	}
+
+	due, next, tooMany, err := scheduledTimes(rm, since, now)
+	if err != nil {
+		return nil, err
+	}
+	if rm.Status.Conditions == nil {
+		r.initResourceModifierStatus(rm)
+	}
+	delete(rm.Status.Conditions, annotresourcemodifv1.StatusTooManyMissedSchedules)
+	if tooMany {
+		message := fmt.Sprintf("Missed more than %d scheduled executions since %s, only the latest one at %s is "+
+			"considered; check the schedule, or set startingDeadlineSeconds", maxMissedSchedules,
+			since.Format(time.RFC3339), due.Format(time.RFC3339))
+		rm.Status.Conditions[annotresourcemodifv1.StatusTooManyMissedSchedules] = message
+		r.Recorder.Event(rm, v2.EventTypeWarning, reasonMissedSchedule, message)
+	}
+
+	rm.Status.NextScheduleTime = nil
+	if next != nil {
+		nextTime := metav1.NewTime(*next)
+		rm.Status.NextScheduleTime = &nextTime
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	dueTime := metav1.NewTime(*due)
+	rm.Status.LastScheduleTime = &dueTime
+
+	deadline := rm.Spec.StartingDeadlineSeconds
+	if deadline != nil && now.Sub(*due) > time.Duration(*deadline)*time.Second {
+		rm.Status.SkippedRuns++
+		r.Recorder.Eventf(rm, v2.EventTypeWarning, reasonMissedSchedule,
+			"Skipped execution scheduled at %s, which was missed by more than %ds",
+			due.Format(time.RFC3339), *deadline)
+		return nil, nil
+	}
+
+	return due, nil
+}
+
+// waitForSchedule updates scheduling fields in the status, if they were changed while no execution was due,
+// and returns the time left until the next scheduled execution.
+func (r *ResourceModifierReconciler) waitForSchedule(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	previous annotresourcemodifv1.ResourceModifierStatus) (time.Duration, error) {
+	if !equality.Semantic.DeepEqual(previous, rm.Status) {
+		ctx, cancel := r.operationContext(ctx)
+		defer cancel()
+
+		if err := r.Client.Status().Update(ctx, rm); err != nil {
+			return 0, err
+		}
+	}
+
+	return untilNextRun(rm), nil
+}
+
+// untilNextRun returns the time left until the next scheduled execution, or zero if there is none.
+func untilNextRun(rm *annotresourcemodifv1.ResourceModifier) time.Duration {
+	if rm.Status.NextScheduleTime == nil {
+		return 0
+	}
+
+	left := time.Until(rm.Status.NextScheduleTime.Time)
+	if left <= 0 {
+		return time.Nanosecond
+	}
+	return left
+}
+
+// earliest returns the shortest positive duration, or zero if none of them is positive.
+func earliest(durations ...time.Duration) time.Duration {
+	var result time.Duration
+	for _, duration := range durations {
+		if duration > 0 && (result == 0 || duration < result) {
+			result = duration
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestScheduledTimes(t *testing.T) {
+	stockholm := "Europe/Stockholm"
+	since := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 21, 17, 0, 0, 0, time.UTC)
+	executeAt := metav1.NewTime(time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name        string
+		spec        v1.ResourceModifierSpec
+		since       time.Time
+		now         time.Time
+		wantLatest  *time.Time
+		wantNext    *time.Time
+		wantTooMany bool
+	}{
+		{
+			name:       "Cron in time zone",
+			spec:       v1.ResourceModifierSpec{Schedule: "0 20 * * *", TimeZone: &stockholm},
+			since:      since,
+			wantLatest: ptrTime(time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC)),
+			wantNext:   ptrTime(time.Date(2026, 10, 21, 18, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "Nothing scheduled since",
+			spec:     v1.ResourceModifierSpec{Schedule: "0 20 * * *", TimeZone: &stockholm},
+			since:    time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC),
+			wantNext: ptrTime(time.Date(2026, 10, 21, 18, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:        "Too many missed times of frequent cron",
+			spec:        v1.ResourceModifierSpec{Schedule: "* * * * *"},
+			since:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			now:         now.Add(30 * time.Second),
+			wantLatest:  ptrTime(now),
+			wantNext:    ptrTime(now.Add(time.Minute)),
+			wantTooMany: true,
+		},
+		{
+			name:        "Too many missed times of irregular cron",
+			spec:        v1.ResourceModifierSpec{Schedule: "0 9 * * 1-5", TimeZone: &stockholm},
+			since:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantLatest:  ptrTime(time.Date(2026, 10, 21, 7, 0, 0, 0, time.UTC)),
+			wantNext:    ptrTime(time.Date(2026, 10, 22, 7, 0, 0, 0, time.UTC)),
+			wantTooMany: true,
+		},
+		{
+			name:       "Execute at passed time",
+			spec:       v1.ResourceModifierSpec{ExecuteAt: &executeAt},
+			since:      since,
+			wantLatest: &executeAt.Time,
+		},
+		{
+			name:  "Execute at already executed time",
+			spec:  v1.ResourceModifierSpec{ExecuteAt: &executeAt},
+			since: executeAt.Time,
+		},
+		{
+			name:     "Execute at future time",
+			spec:     v1.ResourceModifierSpec{ExecuteAt: &executeAt},
+			since:    since,
+			now:      since,
+			wantNext: ptrTime(executeAt.Time),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := tt.now
+			if current.IsZero() {
+				current = now
+			}
+
+			latest, next, tooMany, err := scheduledTimes(&v1.ResourceModifier{Spec: tt.spec}, tt.since, current)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantTooMany, tooMany)
+			assertTime(t, tt.wantLatest, latest)
+			assertTime(t, tt.wantNext, next)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_dueRun(t *testing.T) {
+	deadline := int64(60)
+	lastRun := metav1.NewTime(time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		deadline    *int64
+		wantDue     bool
+		wantSkipped int64
+	}{
+		{
+			name:    "Missed execution is caught up",
+			wantDue: true,
+		},
+		{
+			name:        "Missed execution after the deadline is skipped",
+			deadline:    &deadline,
+			wantSkipped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &ResourceModifierReconciler{Recorder: recorder}
+			rm := &v1.ResourceModifier{
+				Spec: v1.ResourceModifierSpec{Schedule: "0 * * * *", StartingDeadlineSeconds: tt.deadline},
+			}
+			rm.Status.LastScheduleTime = lastRun.DeepCopy()
+
+			due, err := r.dueRun(rm, now)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantDue, due != nil)
+			assert.Equal(t, tt.wantSkipped, rm.Status.SkippedRuns)
+			assert.Len(t, recorder.Events, int(tt.wantSkipped))
+			assert.Equal(t, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), rm.Status.LastScheduleTime.UTC())
+			assert.Equal(t, time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC), rm.Status.NextScheduleTime.UTC())
+		})
+	}
+}
+
+func TestResourceModifierReconciler_dueRun_tooManyMissed(t *testing.T) {
+	lastRun := metav1.NewTime(time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC))
+	now := time.Date(2026, 10, 19, 12, 30, 10, 0, time.UTC)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ResourceModifierReconciler{Recorder: recorder}
+	rm := &v1.ResourceModifier{Spec: v1.ResourceModifierSpec{Schedule: "* * * * *"}}
+	rm.Status.LastScheduleTime = lastRun.DeepCopy()
+
+	// only the latest of the missed executions is due
+	due, err := r.dueRun(rm, now)
+	assert.Nil(t, err)
+	assertTime(t, ptrTime(time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)), due)
+	assert.Contains(t, rm.Status.Conditions[v1.StatusTooManyMissedSchedules], "Missed more than 100")
+	assert.Len(t, recorder.Events, 1)
+
+	due, err = r.dueRun(rm, now.Add(time.Minute))
+	assert.Nil(t, err)
+	assertTime(t, ptrTime(time.Date(2026, 10, 19, 12, 31, 0, 0, time.UTC)), due)
+	assert.NotContains(t, rm.Status.Conditions, v1.StatusTooManyMissedSchedules)
+}
+
+func TestResourceModifierReconciler_Reconcile_executeAt(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+		},
+	}
+	executeAt := metav1.NewTime(time.Now().Add(time.Hour))
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Name:         "test-pod",
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:scaled:down"},
+			ExecuteAt:   &executeAt,
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, rm).WithStatusSubresource(rm).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	label := func() string {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+		return got.Labels["scaled"]
+	}
+
+	// Not executed before the scheduled time
+	result, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+	assert.Empty(t, label())
+
+	gotRM := &v1.ResourceModifier{}
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Empty(t, gotRM.Status.Phase)
+	assert.NotNil(t, gotRM.Status.NextScheduleTime)
+
+	// Executed, once the scheduled time passes
+	passed := metav1.NewTime(time.Now().Add(-time.Second))
+	gotRM.Spec.ExecuteAt = &passed
+	gotRM.Generation = 2
+	assert.Nil(t, r.Update(ctx, gotRM))
+
+	result, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, "down", label())
+
+	assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+	assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+	assert.Equal(t, passed.Unix(), gotRM.Status.LastScheduleTime.Unix())
+	assert.Nil(t, gotRM.Status.NextScheduleTime)
+
+	// Executed only once
+	got := &v2.Pod{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pod), got))
+	got.Labels = nil
+	assert.Nil(t, r.Update(ctx, got))
+
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Empty(t, label())
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func assertTime(t *testing.T, want, got *time.Time) {
+	if want == nil {
+		assert.Nil(t, got)
+		return
+	}
+	if assert.NotNil(t, got) {
+		assert.True(t, want.Equal(*got), "want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"time"
+)
+
+const (
+	// noMatchingResource is an error message indicating that no resource matches the labels
+	noMatchingResource = "No resource matches labels "
+
+	// multipleMatchingResources is an error message indicating that labels match more than one resource
+	multipleMatchingResources = "Multiple resources match labels "
+)
+
+// errNoMatchingResource is returned, when no resource matches the labels.
+var errNoMatchingResource = errors.New(noMatchingResource)
+
+// findTarget retrieves the resource specified by resourceData. If labels are specified, they must match
+// exactly one resource.
+func (r *ResourceModifierReconciler) findTarget(ctx context.Context,
+	resourceData annotresourcemodifv1.TargetResourceData) (client.Object, error) {
+	if len(resourceData.Labels) > 0 {
+		resources, err := r.listTargets(ctx, resourceData)
+		if err != nil {
+			return nil, err
+		}
+
+		switch len(resources) {
+		case 0:
+			return nil, fmt.Errorf("%w%v", errNoMatchingResource, resourceData.Labels)
+		case 1:
+			return resources[0], nil
+		}
+		return nil, fmt.Errorf("%s%v: found %d resources", multipleMatchingResources, resourceData.Labels, len(resources))
+	}
+
+	resource, err := r.determineResourceType(resourceData)
+	if err != nil {
+		return nil, fmt.Errorf("error determining resource type: %w", err)
+	}
+
+	objectKey, err := r.determineResourceSelector(resourceData)
+	if err != nil {
+		return nil, fmt.Errorf("error determining selector: %w", err)
+	}
+
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	if err = r.Client.Get(ctx, objectKey, resource); err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// listTargets lists resources of the type specified by resourceData, which match its labels, in its namespace.
+func (r *ResourceModifierReconciler) listTargets(ctx context.Context,
+	resourceData annotresourcemodifv1.TargetResourceData) ([]client.Object, error) {
+	resource, err := r.determineResourceType(resourceData)
+	if err != nil {
+		return nil, fmt.Errorf("error determining resource type: %w", err)
+	}
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	newList, err := r.Scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	list, ok := newList.(client.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a list", newList)
+	}
+
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	err = r.Client.List(ctx, list, client.InNamespace(resourceData.Namespace), client.MatchingLabels(resourceData.Labels))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		object, ok := item.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("%T is not an object", item)
+		}
+		resources = append(resources, object)
+	}
+
+	return resources, nil
+}
+
+// executeSelector applies annotations and patches of ResourceModifier to every resource matching its labels,
+// which was not processed yet. UIDs of processed resources are recorded in the status, UIDs of resources which
+// no longer match are forgotten. Resources which failed are not recorded, so they are retried on their next change,
+// and the first error is returned. Returns true, if the set of processed resources changed.
+// Resources locked by others are skipped, and the lock error is returned, unless another error occurred.
+// Resources which do not satisfy the When expressions are skipped, and listed in the PreconditionNotMet condition.
+// With Rollout, only the next batch of pending resources is processed, and failed resources are not retried; the
+// error is only returned, when the rollout was aborted.
+func (r *ResourceModifierReconciler) executeSelector(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) (bool, error) {
+	resources, err := r.listTargets(ctx, rm.Spec.ResourceData)
+	if err != nil {
+		return false, err
+	}
+
+	processed := make(map[string]struct{}, len(rm.Status.ProcessedUIDs))
+	for _, uid := range rm.Status.ProcessedUIDs {
+		processed[uid] = struct{}{}
+	}
+
+	var batch map[string]struct{}
+	if rm.Spec.Rollout != nil {
+		var pending []client.Object
+		for _, resource := range resources {
+			if _, exists := processed[string(resource.GetUID())]; !exists {
+				pending = append(pending, resource)
+			}
+		}
+		selected, err := r.nextBatch(rm, pending, len(resources), time.Now())
+		if err != nil {
+			return false, err
+		}
+		batch = make(map[string]struct{}, len(selected))
+		for _, resource := range selected {
+			batch[string(resource.GetUID())] = struct{}{}
+		}
+	}
+
+	var processedUIDs, failedUIDs, unmet []string
+	var firstErr, lockErr error
+	changed := false
+	for _, resource := range resources {
+		uid := string(resource.GetUID())
+		if _, exists := processed[uid]; !exists {
+			if _, selected := batch[uid]; batch != nil && !selected {
+				continue
+			}
+			if unmetPrecondition(ctx, rm, resource) != "" {
+				// not processed, it is evaluated again, when the resource changes
+				unmet = append(unmet, client.ObjectKeyFromObject(resource).String())
+				continue
+			}
+			err = r.withTargetLock(ctx, rm, resource, func() error {
+				_, err := r.apply(ctx, resource, rm)
+				return err
+			})
+			if errors.Is(err, errLocked) {
+				// not processed, but not failed either, it is retried with ResourceModifier
+				lockErr = err
+				continue
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s %s: %w", rm.Spec.ResourceData.ResourceType,
+						client.ObjectKeyFromObject(resource), err)
+				}
+				failedUIDs = append(failedUIDs, uid)
+				continue
+			}
+			changed = true
+		}
+		processedUIDs = append(processedUIDs, uid)
+	}
+
+	changed = changed || len(processedUIDs) != len(rm.Status.ProcessedUIDs)
+	if message := describeUnmet(unmet); message != rm.Status.Conditions[annotresourcemodifv1.StatusPreconditionNotMet] {
+		changed = true
+		if message == "" {
+			delete(rm.Status.Conditions, annotresourcemodifv1.StatusPreconditionNotMet)
+		} else {
+			rm.Status.Conditions[annotresourcemodifv1.StatusPreconditionNotMet] = message
+		}
+	}
+	rm.Status.ProcessedUIDs = processedUIDs
+
+	if rm.Spec.Rollout != nil {
+		changed = changed || len(failedUIDs) > 0
+		if err = r.recordRolloutFailures(rm, failedUIDs, len(processedUIDs), firstErr); err != nil {
+			return changed, err
+		}
+		return changed, lockErr
+	}
+	if firstErr == nil {
+		firstErr = lockErr
+	}
+	return changed, firstErr
+}
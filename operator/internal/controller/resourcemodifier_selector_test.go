@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func newSelectorTestPod(name string, uid types.UID, labels map[string]string) *v2.Pod {
+	return &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-ns",
+			UID:       uid,
+			Labels:    labels,
+		},
+	}
+}
+
+func TestResourceModifierReconciler_findTarget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newSelectorTestPod("first", "uid-1", map[string]string{"app": "web", "tier": "frontend"}),
+			newSelectorTestPod("second", "uid-2", map[string]string{"app": "web", "tier": "backend"}),
+		).Build(),
+		Scheme: scheme,
+	}
+
+	tests := []struct {
+		name     string
+		data     v1.TargetResourceData
+		wantName string
+		wantErr  bool
+	}{
+		{
+			name:     "By name",
+			data:     v1.TargetResourceData{ResourceType: "pod", Name: "second", Namespace: "test-ns"},
+			wantName: "second",
+		},
+		{
+			name:     "By labels matching one resource",
+			data:     v1.TargetResourceData{ResourceType: "pod", Labels: map[string]string{"tier": "frontend"}, Namespace: "test-ns"},
+			wantName: "first",
+		},
+		{
+			name:    "Labels matching multiple resources",
+			data:    v1.TargetResourceData{ResourceType: "pod", Labels: map[string]string{"app": "web"}, Namespace: "test-ns"},
+			wantErr: true,
+		},
+		{
+			name:    "Labels matching no resource",
+			data:    v1.TargetResourceData{ResourceType: "pod", Labels: map[string]string{"app": "web"}, Namespace: "other-ns"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.findTarget(context.Background(), tt.data)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantName, got.GetName())
+		})
+	}
+}
+
+func TestResourceModifierReconciler_Reconcile_selector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	rm := &v1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rm-test",
+			Namespace:  "test-ns",
+			Generation: 1,
+		},
+		Spec: v1.ResourceModifierSpec{
+			ResourceData: v1.TargetResourceData{
+				Labels:       map[string]string{"app": "web"},
+				Namespace:    "test-ns",
+				ResourceType: "pod",
+			},
+			Annotations: []string{"addLabel:tolerated:true"},
+			Mode:        v1.SelectorMode,
+		},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newSelectorTestPod("existing", "uid-1", map[string]string{"app": "web"}),
+			newSelectorTestPod("unrelated", "uid-2", map[string]string{"app": "db"}),
+			rm,
+		).WithStatusSubresource(rm).Build(),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+	labelOf := func(name string) string {
+		got := &v2.Pod{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: name, Namespace: "test-ns"}, got))
+		return got.Labels["tolerated"]
+	}
+	processedUIDs := func() []string {
+		got := &v1.ResourceModifier{}
+		assert.Nil(t, r.Get(ctx, request.NamespacedName, got))
+		return got.Status.ProcessedUIDs
+	}
+
+	_, err := r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", labelOf("existing"))
+	assert.Empty(t, labelOf("unrelated"))
+	assert.Equal(t, []string{"uid-1"}, processedUIDs())
+
+	// Processed resource is not modified again
+	existing := &v2.Pod{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "existing", Namespace: "test-ns"}, existing))
+	delete(existing.Labels, "tolerated")
+	assert.Nil(t, r.Update(ctx, existing))
+
+	// Newly created matching resource is processed
+	assert.Nil(t, r.Create(ctx, newSelectorTestPod("created", "uid-3", map[string]string{"app": "web"})))
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Empty(t, labelOf("existing"))
+	assert.Equal(t, "true", labelOf("created"))
+	assert.ElementsMatch(t, []string{"uid-1", "uid-3"}, processedUIDs())
+
+	// Deleted resources are forgotten
+	assert.Nil(t, r.Delete(ctx, existing))
+	_, err = r.Reconcile(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"uid-3"}, processedUIDs())
+}
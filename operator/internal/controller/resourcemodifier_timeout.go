@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"time"
+)
+
+const (
+	// DefaultOperationTimeout limits a single request to the API server, unless configured otherwise
+	DefaultOperationTimeout = 5 * time.Second
+
+	// DefaultActionTimeout limits a single action, including waiting (e.g. for deletion), unless configured otherwise
+	DefaultActionTimeout = 10 * time.Minute
+)
+
+// reconcilerFor returns a copy of the reconciler, which limits requests to the API server by the operation timeout
+// of ResourceModifier. The shared reconciler is not modified, as reconciliations may run concurrently.
+func (r *ResourceModifierReconciler) reconcilerFor(rm *annotresourcemodifv1.ResourceModifier) *ResourceModifierReconciler {
+	reconciler := *r
+	reconciler.requestTimeout = r.operationTimeout(rm)
+	return &reconciler
+}
+
+// operationContext returns a context for a single request to the API server, derived from ctx, so it is cancelled
+// on shutdown, and limited by the operation timeout of the reconciled ResourceModifier.
+func (r *ResourceModifierReconciler) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := r.requestTimeout
+	if timeout <= 0 {
+		timeout = r.operationTimeout(nil)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// operationTimeout returns the timeout of requests to the API server for ResourceModifier: its Timeouts, or the
+// controller's default.
+func (r *ResourceModifierReconciler) operationTimeout(rm *annotresourcemodifv1.ResourceModifier) time.Duration {
+	if rm != nil && rm.Spec.Timeouts != nil && rm.Spec.Timeouts.Operation != nil {
+		return rm.Spec.Timeouts.Operation.Duration
+	}
+	if r.OperationTimeout > 0 {
+		return r.OperationTimeout
+	}
+
+	return DefaultOperationTimeout
+}
+
+// actionTimeout returns the timeout of a single action of ResourceModifier: its Timeouts, or the controller's default.
+func (r *ResourceModifierReconciler) actionTimeout(rm *annotresourcemodifv1.ResourceModifier) time.Duration {
+	if rm.Spec.Timeouts != nil && rm.Spec.Timeouts.Action != nil {
+		return rm.Spec.Timeouts.Action.Duration
+	}
+	if r.ActionTimeout > 0 {
+		return r.ActionTimeout
+	}
+
+	return DefaultActionTimeout
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestResourceModifierReconciler_timeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		reconciler    *ResourceModifierReconciler
+		timeouts      *v1.Timeouts
+		wantOperation time.Duration
+		wantAction    time.Duration
+	}{
+		{
+			name:          "Defaults",
+			reconciler:    &ResourceModifierReconciler{},
+			wantOperation: DefaultOperationTimeout,
+			wantAction:    DefaultActionTimeout,
+		},
+		{
+			name:          "Manager flags",
+			reconciler:    &ResourceModifierReconciler{OperationTimeout: time.Second, ActionTimeout: time.Minute},
+			wantOperation: time.Second,
+			wantAction:    time.Minute,
+		},
+		{
+			name:       "ResourceModifier overrides manager flags",
+			reconciler: &ResourceModifierReconciler{OperationTimeout: time.Second, ActionTimeout: time.Minute},
+			timeouts: &v1.Timeouts{
+				Operation: &metav1.Duration{Duration: 30 * time.Second},
+				Action:    &metav1.Duration{Duration: 30 * time.Minute},
+			},
+			wantOperation: 30 * time.Second,
+			wantAction:    30 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &v1.ResourceModifier{Spec: v1.ResourceModifierSpec{Timeouts: tt.timeouts}}
+			assert.Equal(t, tt.wantOperation, tt.reconciler.operationTimeout(rm))
+			assert.Equal(t, tt.wantAction, tt.reconciler.actionTimeout(rm))
+
+			ctx, cancel := tt.reconciler.reconcilerFor(rm).operationContext(context.Background())
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(tt.wantOperation), deadline, time.Second)
+		})
+	}
+}
+
+func TestResourceModifierReconciler_applyActions_timeout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	pod := &v2.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-pod",
+			Namespace:  "test-ns",
+			UID:        "test-uid",
+			Finalizers: []string{"test-finalizer"},
+		},
+	}
+	rm := &v1.ResourceModifier{
+		Spec: v1.ResourceModifierSpec{
+			Annotations: []string{"deleteResource"},
+			DeleteOptions: &v1.DeleteOptions{
+				WaitForDeletion: true,
+				Timeout:         &metav1.Duration{Duration: time.Minute},
+			},
+			Timeouts: &v1.Timeouts{Action: &metav1.Duration{Duration: 100 * time.Millisecond}},
+		},
+		Status: v1.ResourceModifierStatus{Conditions: map[string]string{}},
+	}
+
+	r := &ResourceModifierReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	// waiting for the deletion is cut short by the action timeout
+	started := time.Now()
+	err := r.applyActions(context.Background(), pod, rm)
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	// cancelled context stops the action immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rm.Spec.Timeouts = nil
+	assert.NotNil(t, r.applyActions(ctx, pod, rm))
+}
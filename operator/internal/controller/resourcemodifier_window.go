@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// reasonWaitingForWindow is a reason of the Event, which is emitted when execution waits for the maintenance window
+	reasonWaitingForWindow = "WaitingForWindow"
+
+	// invalidMaintenanceWindow is an error message indicating that the maintenance window is malformed
+	invalidMaintenanceWindow = "Invalid maintenance window "
+
+	// maxWindowSteps limits how many windows and blackouts are skipped, when looking for the next opening
+	maxWindowSteps = 1000
+)
+
+// destructiveActions lists actions, which are restricted by maintenance windows with Destructive scope.
+var destructiveActions = map[string]struct{}{
+	"deleteResource":      {},
+	"removeAnyFinalizers": {},
+}
+
+// waitForWindow checks, whether the maintenance window of ResourceModifier allows execution at now.
+// If it does not, the status is reset to previous, so a due scheduled execution is postponed rather than consumed,
+// the WaitingForWindow condition is recorded, and the time left until the window opens is returned.
+func (r *ResourceModifierReconciler) waitForWindow(ctx context.Context, rm *annotresourcemodifv1.ResourceModifier,
+	previous annotresourcemodifv1.ResourceModifierStatus, now time.Time) (bool, time.Duration, error) {
+	window, err := r.getMaintenanceWindow(ctx, rm)
+	if err != nil {
+		return false, 0, err
+	}
+	if window == nil || (window.Scope == annotresourcemodifv1.DestructiveScope && !isDestructive(rm)) {
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusWaitingForWindow)
+		return false, 0, nil
+	}
+
+	opens, reason, err := nextOpening(window, now)
+	if err != nil {
+		return false, 0, err
+	}
+	if !opens.After(now) {
+		delete(rm.Status.Conditions, annotresourcemodifv1.StatusWaitingForWindow)
+		return false, 0, nil
+	}
+
+	message := fmt.Sprintf("Execution is not allowed %s, waiting until %s", reason, opens.Format(time.RFC3339))
+	err = r.updateWaitingStatus(ctx, rm, previous, annotresourcemodifv1.StatusWaitingForWindow,
+		reasonWaitingForWindow, message)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return true, opens.Sub(now), nil
+}
+
+// getMaintenanceWindow returns the maintenance window, which restricts ResourceModifier, either specified inline
+// or referenced by name. Returns nil, if there is none.
+func (r *ResourceModifierReconciler) getMaintenanceWindow(ctx context.Context,
+	rm *annotresourcemodifv1.ResourceModifier) (*annotresourcemodifv1.MaintenanceWindowSpec, error) {
+	if rm.Spec.MaintenanceWindow != nil {
+		return rm.Spec.MaintenanceWindow, nil
+	}
+	if rm.Spec.MaintenanceWindowName == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	var window annotresourcemodifv1.MaintenanceWindow
+	key := client.ObjectKey{Name: rm.Spec.MaintenanceWindowName, Namespace: rm.Namespace}
+	if err := r.Client.Get(ctx, key, &window); err != nil {
+		return nil, fmt.Errorf("failed to get maintenance window %s: %w", key, err)
+	}
+
+	return &window.Spec, nil
+}
+
+// isDestructive returns true, if ResourceModifier performs any of the destructive actions, or any of its patches
+// removes a field: by a "remove" operation of a JSON patch, by "$patch: delete", or by a merge patch setting it to
+// null. A patch, whose effects can not be determined, is considered destructive.
+func isDestructive(rm *annotresourcemodifv1.ResourceModifier) bool {
+	for _, annotation := range rm.Spec.Annotations {
+		name, _ := splitAnnotation(annotation)
+		if _, destructive := destructiveActions[name]; destructive {
+			return true
+		}
+	}
+	for _, patch := range rm.Spec.Patches {
+		effects, err := patchEffects(patch)
+		if err != nil {
+			return true
+		}
+		for _, effect := range effects {
+			if effect.Value == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ValidateMaintenanceWindow checks that schedules of the windows are valid cron expressions, that the time zone
+// is known, and that the blackouts end after they start.
+func ValidateMaintenanceWindow(window annotresourcemodifv1.MaintenanceWindowSpec) error {
+	for _, timeWindow := range window.Windows {
+		if _, _, err := parseSchedule(timeWindow.Schedule, window.TimeZone); err != nil {
+			return fmt.Errorf("%s%w", invalidMaintenanceWindow, err)
+		}
+		if timeWindow.Duration.Duration <= 0 {
+			return fmt.Errorf("%swindow %s: duration must be positive", invalidMaintenanceWindow, timeWindow.Schedule)
+		}
+	}
+	for _, blackout := range window.Blackouts {
+		if !blackout.End.After(blackout.Start.Time) {
+			return fmt.Errorf("%sblackout %s: end must be after start", invalidMaintenanceWindow,
+				blackout.Start.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// nextOpening returns the earliest time not before now, at which the maintenance window allows execution.
+// If it is later than now, the reason why execution is not allowed at now is returned as well.
+func nextOpening(window *annotresourcemodifv1.MaintenanceWindowSpec, now time.Time) (time.Time, string, error) {
+	if err := ValidateMaintenanceWindow(*window); err != nil {
+		return time.Time{}, "", err
+	}
+
+	reason := ""
+	at := now
+	for step := 0; step < maxWindowSteps; step++ {
+		if blackout := activeBlackout(window, at); blackout != nil {
+			if reason == "" {
+				reason = "during blackout"
+				if blackout.Reason != "" {
+					reason += " (" + blackout.Reason + ")"
+				}
+			}
+			at = blackout.End.Time
+			continue
+		}
+
+		opens, err := nextWindowStart(window, at)
+		if err != nil {
+			return time.Time{}, "", err
+		}
+		if opens.IsZero() {
+			return time.Time{}, "", fmt.Errorf("%swindows never open", invalidMaintenanceWindow)
+		}
+		if !opens.After(at) {
+			return at, reason, nil
+		}
+		if reason == "" {
+			reason = "outside of maintenance window"
+		}
+		at = opens
+	}
+
+	return time.Time{}, "", fmt.Errorf("%sno opening found", invalidMaintenanceWindow)
+}
+
+// activeBlackout returns the blackout, which the time falls into, ending the latest. Returns nil, if there is none.
+func activeBlackout(window *annotresourcemodifv1.MaintenanceWindowSpec, at time.Time) *annotresourcemodifv1.Blackout {
+	var active *annotresourcemodifv1.Blackout
+	for i, blackout := range window.Blackouts {
+		if at.Before(blackout.Start.Time) || !at.Before(blackout.End.Time) {
+			continue
+		}
+		if active == nil || blackout.End.After(active.End.Time) {
+			active = &window.Blackouts[i]
+		}
+	}
+
+	return active
+}
+
+// nextWindowStart returns the time, if it falls into any of the windows, or the earliest start of a window after it.
+// Without windows, every time is allowed. Returns zero time, if no window ever starts.
+func nextWindowStart(window *annotresourcemodifv1.MaintenanceWindowSpec, at time.Time) (time.Time, error) {
+	if len(window.Windows) == 0 {
+		return at, nil
+	}
+
+	var next time.Time
+	for _, timeWindow := range window.Windows {
+		schedule, location, err := parseSchedule(timeWindow.Schedule, window.TimeZone)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		// the window, which started last before at, is the first one starting after at - duration
+		start := schedule.Next(at.Add(-timeWindow.Duration.Duration).In(location))
+		if start.IsZero() {
+			continue
+		}
+		if !start.After(at) {
+			return at, nil
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+
+	return next, nil
+}
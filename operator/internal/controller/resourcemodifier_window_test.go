@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"context"
+	v1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestNextOpening(t *testing.T) {
+	stockholm := "Europe/Stockholm"
+	// Monday
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	nights := v1.TimeWindow{Schedule: "0 22 * * *", Duration: metav1.Duration{Duration: 8 * time.Hour}}
+
+	tests := []struct {
+		name       string
+		window     v1.MaintenanceWindowSpec
+		want       time.Time
+		wantReason string
+	}{
+		{
+			name: "No restrictions",
+			want: now,
+		},
+		{
+			name:       "Outside of window",
+			window:     v1.MaintenanceWindowSpec{Windows: []v1.TimeWindow{nights}},
+			want:       time.Date(2026, 10, 19, 22, 0, 0, 0, time.UTC),
+			wantReason: "outside of maintenance window",
+		},
+		{
+			name:   "Window in time zone",
+			window: v1.MaintenanceWindowSpec{Windows: []v1.TimeWindow{nights}, TimeZone: &stockholm},
+			// 22:00 in Stockholm is 20:00 UTC in October
+			want:       time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC),
+			wantReason: "outside of maintenance window",
+		},
+		{
+			name: "Inside of window started the day before",
+			window: v1.MaintenanceWindowSpec{Windows: []v1.TimeWindow{
+				{Schedule: "0 22 * * *", Duration: metav1.Duration{Duration: 16 * time.Hour}},
+			}},
+			want: now,
+		},
+		{
+			name: "Blackout",
+			window: v1.MaintenanceWindowSpec{Blackouts: []v1.Blackout{{
+				Start:  metav1.NewTime(now.Add(-time.Hour)),
+				End:    metav1.NewTime(now.Add(time.Hour)),
+				Reason: "release freeze",
+			}}},
+			want:       now.Add(time.Hour),
+			wantReason: "during blackout (release freeze)",
+		},
+		{
+			name: "Blackout covering the next window",
+			window: v1.MaintenanceWindowSpec{
+				Windows: []v1.TimeWindow{nights},
+				Blackouts: []v1.Blackout{{
+					Start: metav1.NewTime(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)),
+					End:   metav1.NewTime(time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC)),
+				}},
+			},
+			want:       time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC),
+			wantReason: "during blackout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, err := nextOpening(&tt.window, now)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got.UTC())
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestIsDestructive(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations []string
+		patch       v1.Patch
+		want        bool
+	}{
+		{
+			name:        "Destructive action",
+			annotations: []string{"removeAnyFinalizers"},
+			want:        true,
+		},
+		{
+			name:  "Merge patch setting fields",
+			patch: v1.Patch{Type: v1.MergePatchType, Patch: apiextensionsv1.JSON{Raw: []byte(`{"spec":{"replicas":0}}`)}},
+		},
+		{
+			name: "Merge patch removing a field",
+			patch: v1.Patch{Type: v1.MergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{"metadata":{"finalizers":null}}`)}},
+			want: true,
+		},
+		{
+			name: "Strategic merge patch deleting an item",
+			patch: v1.Patch{Type: v1.StrategicMergePatchType, Patch: apiextensionsv1.JSON{Raw: []byte(
+				`{"spec":{"containers":[{"name":"sidecar","$patch":"delete"}]}}`)}},
+			want: true,
+		},
+		{
+			name: "JSON patch removing a field",
+			patch: v1.Patch{Type: v1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op":"remove","path":"/metadata/finalizers"}]`)}},
+			want: true,
+		},
+		{
+			name: "JSON patch replacing a field",
+			patch: v1.Patch{Type: v1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op":"replace","path":"/spec/replicas","value":0}]`)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &v1.ResourceModifier{Spec: v1.ResourceModifierSpec{Annotations: tt.annotations}}
+			if tt.patch.Patch.Raw != nil {
+				rm.Spec.Patches = []v1.Patch{tt.patch}
+			}
+			assert.Equal(t, tt.want, isDestructive(rm))
+		})
+	}
+}
+
+func TestResourceModifierReconciler_Reconcile_maintenanceWindow(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(scheme))
+	assert.Nil(t, v2.AddToScheme(scheme))
+
+	tests := []struct {
+		name        string
+		scope       v1.WindowScope
+		annotations []string
+		wantWaiting bool
+	}{
+		{
+			name:        "Waits for the window",
+			annotations: []string{"addLabel:maintained:true"},
+			wantWaiting: true,
+		},
+		{
+			name:        "Destructive scope does not restrict other actions",
+			scope:       v1.DestructiveScope,
+			annotations: []string{"addLabel:maintained:true"},
+		},
+		{
+			name:        "Destructive scope restricts deletion",
+			scope:       v1.DestructiveScope,
+			annotations: []string{"addLabel:maintained:true", "deleteResource"},
+			wantWaiting: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v2.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-ns",
+				},
+			}
+			window := &v1.MaintenanceWindow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "freeze",
+					Namespace: "test-ns",
+				},
+				Spec: v1.MaintenanceWindowSpec{
+					Scope: tt.scope,
+					Blackouts: []v1.Blackout{{
+						Start: metav1.NewTime(time.Now().Add(-time.Hour)),
+						End:   metav1.NewTime(time.Now().Add(time.Hour)),
+					}},
+				},
+			}
+			rm := &v1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "rm-test",
+					Namespace:  "test-ns",
+					Generation: 1,
+				},
+				Spec: v1.ResourceModifierSpec{
+					ResourceData: v1.TargetResourceData{
+						Name:         "test-pod",
+						Namespace:    "test-ns",
+						ResourceType: "pod",
+					},
+					Annotations:           tt.annotations,
+					MaintenanceWindowName: "freeze",
+				},
+			}
+
+			r := &ResourceModifierReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, window, rm).
+					WithStatusSubresource(rm).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			ctx := context.Background()
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(rm)}
+
+			result, err := r.Reconcile(ctx, request)
+			assert.Nil(t, err)
+
+			gotRM := &v1.ResourceModifier{}
+			assert.Nil(t, r.Get(ctx, request.NamespacedName, gotRM))
+			got := &v2.Pod{}
+			getErr := r.Get(ctx, client.ObjectKeyFromObject(pod), got)
+
+			if tt.wantWaiting {
+				assert.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+				assert.Contains(t, gotRM.Status.Conditions, v1.StatusWaitingForWindow)
+				assert.Empty(t, gotRM.Status.Phase)
+				assert.Nil(t, getErr)
+				assert.Empty(t, got.Labels["maintained"])
+				return
+			}
+			assert.NotContains(t, gotRM.Status.Conditions, v1.StatusWaitingForWindow)
+			assert.Equal(t, v1.PhaseSucceeded, gotRM.Status.Phase)
+			assert.Equal(t, "true", got.Labels["maintained"])
+		})
+	}
+}
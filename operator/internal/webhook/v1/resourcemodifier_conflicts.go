@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"ericsson.com/resource-modif-annotations/internal/controller"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+)
+
+// targetIndexField is the name of the index of ResourceModifiers by their target resource.
+const targetIndexField = "spec.resourceData.target"
+
+// indexTarget returns the key of the resource targeted by ResourceModifier, for the targetIndexField index.
+// ResourceModifiers selecting resources by labels are not indexed.
+func indexTarget(obj client.Object) []string {
+	rm, ok := obj.(*annotresourcemodifv1.ResourceModifier)
+	if !ok {
+		return nil
+	}
+	if key := controller.TargetKey(rm.Spec.ResourceData); key != "" {
+		return []string{key}
+	}
+
+	return nil
+}
+
+// validateConflicts checks that ResourceModifier does not change the same fields of its target as other active
+// ResourceModifiers with contradictory intent, e.g. one adds a label, and the other removes it. Such a conflict
+// is rejected, if either ResourceModifier is in Enforce mode, since they would revert each other forever,
+// otherwise a warning is returned. Only ResourceModifiers targeting a resource by name are checked: those selecting
+// resources by labels (e.g. in Selector mode) have no TargetKey, so they are not checked against any other
+// ResourceModifier. Effects, which can not be determined, are reported as warnings rather than rejected.
+// ResourceModifier, which is not active itself (e.g. being deleted), is not checked either.
+func validateConflicts(ctx context.Context, reader client.Reader,
+	rm *annotresourcemodifv1.ResourceModifier) (admission.Warnings, error) {
+	key := controller.TargetKey(rm.Spec.ResourceData)
+	if reader == nil || key == "" || !isActive(rm) {
+		return nil, nil
+	}
+	effects, err := controller.SpecEffects(rm.Spec)
+	if err != nil {
+		return admission.Warnings{fmt.Sprintf("conflicts with other ResourceModifiers were not checked: %v", err)}, nil
+	}
+
+	var list annotresourcemodifv1.ResourceModifierList
+	if err := reader.List(ctx, &list, client.MatchingFields{targetIndexField: key}); err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("failed to list ResourceModifiers: %w", err))
+	}
+
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+	for _, other := range list.Items {
+		if other.Namespace == rm.Namespace && other.Name == rm.Name || !isActive(&other) {
+			continue
+		}
+		otherEffects, err := controller.SpecEffects(other.Spec)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("conflicts with ResourceModifier %s were not checked: %v",
+				client.ObjectKeyFromObject(&other), err))
+			continue
+		}
+		fields := controller.ConflictingFields(effects, otherEffects)
+		if len(fields) == 0 {
+			continue
+		}
+
+		message := fmt.Sprintf("conflicts with ResourceModifier %s on %s", client.ObjectKeyFromObject(&other),
+			strings.Join(fields, ", "))
+		if rm.Spec.Mode == annotresourcemodifv1.EnforceMode || other.Spec.Mode == annotresourcemodifv1.EnforceMode {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), message+" in Enforce mode"))
+		} else {
+			warnings = append(warnings, message)
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+
+	return warnings, apierrors.NewInvalid(
+		schema.GroupKind{Group: annotresourcemodifv1.GroupVersion.Group, Kind: "ResourceModifier"},
+		rm.Name, allErrs)
+}
+
+// isActive returns true, if ResourceModifier still changes its target: it is continuous, scheduled, or was not
+// executed yet. ResourceModifiers in DryRun mode do not change the target.
+func isActive(rm *annotresourcemodifv1.ResourceModifier) bool {
+	if rm.DeletionTimestamp != nil || rm.Spec.DryRun {
+		return false
+	}
+	switch {
+	case rm.Spec.Mode == annotresourcemodifv1.EnforceMode, rm.Spec.Mode == annotresourcemodifv1.SelectorMode,
+		rm.Spec.Schedule != "":
+		return true
+	}
+
+	return !rm.Status.IsCompleted(rm.Generation, rm.Annotations[annotresourcemodifv1.RerunAnnotation])
+}
@@ -0,0 +1,190 @@
+package v1
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestValidateConflicts(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, annotresourcemodifv1.AddToScheme(scheme))
+
+	resourceModifier := func(name, target string, mode annotresourcemodifv1.Mode,
+		annotations ...string) *annotresourcemodifv1.ResourceModifier {
+		return &annotresourcemodifv1.ResourceModifier{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", Generation: 1},
+			Spec: annotresourcemodifv1.ResourceModifierSpec{
+				ResourceData: annotresourcemodifv1.TargetResourceData{
+					ResourceType: "pod",
+					Namespace:    "test-ns",
+					Name:         target,
+				},
+				Annotations: annotations,
+				Mode:        mode,
+			},
+		}
+	}
+	completed := resourceModifier("completed", "web", "", "addLabel:x:true")
+	completed.Status.Completed(annotresourcemodifv1.PhaseSucceeded, 1, "")
+	completedRemoval := resourceModifier("completed-removal", "web", "", "removeLabel:x")
+	completedRemoval.Status.Completed(annotresourcemodifv1.PhaseSucceeded, 1, "")
+
+	tests := []struct {
+		name         string
+		existing     []client.Object
+		rm           *annotresourcemodifv1.ResourceModifier
+		wantWarnings int
+		wantErr      bool
+	}{
+		{
+			name:     "No conflicts",
+			existing: []client.Object{resourceModifier("add-x", "web", "", "addLabel:x:true")},
+			rm:       resourceModifier("add-y", "web", "", "addLabel:y:true"),
+		},
+		{
+			name:         "Contradictory OneShot ResourceModifiers",
+			existing:     []client.Object{resourceModifier("add-x", "web", "", "addLabel:x:true")},
+			rm:           resourceModifier("remove-x", "web", "", "removeLabel:x"),
+			wantWarnings: 1,
+		},
+		{
+			name:     "Contradictory Enforce ResourceModifier",
+			existing: []client.Object{resourceModifier("add-x", "web", annotresourcemodifv1.EnforceMode, "addLabel:x:true")},
+			rm:       resourceModifier("remove-x", "web", "", "removeLabel:x"),
+			wantErr:  true,
+		},
+		{
+			name:     "Other target",
+			existing: []client.Object{resourceModifier("add-x", "db", annotresourcemodifv1.EnforceMode, "addLabel:x:true")},
+			rm:       resourceModifier("remove-x", "web", "", "removeLabel:x"),
+		},
+		{
+			name:     "Completed ResourceModifier is not active",
+			existing: []client.Object{completed},
+			rm:       resourceModifier("remove-x", "web", "", "removeLabel:x"),
+		},
+		{
+			name:     "Inactive ResourceModifier is not checked",
+			existing: []client.Object{resourceModifier("add-x", "web", annotresourcemodifv1.EnforceMode, "addLabel:x:true")},
+			rm:       completedRemoval,
+		},
+		{
+			name:         "Effects of other ResourceModifier can not be determined",
+			existing:     []client.Object{resourceModifier("legacy", "web", annotresourcemodifv1.EnforceMode, "unknownAction")},
+			rm:           resourceModifier("remove-x", "web", "", "removeLabel:x"),
+			wantWarnings: 1,
+		},
+		{
+			name:         "Effects can not be determined",
+			rm:           resourceModifier("unknown", "web", "", "unknownAction"),
+			wantWarnings: 1,
+		},
+		{
+			name:     "ResourceModifiers selecting by labels are not checked",
+			existing: []client.Object{resourceModifier("add-x", "", annotresourcemodifv1.EnforceMode, "addLabel:x:true")},
+			rm:       resourceModifier("remove-x", "", annotresourcemodifv1.SelectorMode, "removeLabel:x"),
+		},
+		{
+			name:     "Updated ResourceModifier does not conflict with itself",
+			existing: []client.Object{resourceModifier("label-x", "web", annotresourcemodifv1.EnforceMode, "addLabel:x:true")},
+			rm:       resourceModifier("label-x", "web", annotresourcemodifv1.EnforceMode, "removeLabel:x"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.existing...).
+				WithIndex(&annotresourcemodifv1.ResourceModifier{}, targetIndexField, indexTarget).Build()
+
+			warnings, err := validateConflicts(context.Background(), reader, tt.rm)
+			assert.Len(t, warnings, tt.wantWarnings)
+			if tt.wantErr {
+				assert.True(t, apierrors.IsInvalid(err))
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestResourceModifierCustomValidator_ValidateUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, annotresourcemodifv1.AddToScheme(scheme))
+
+	now := metav1.Now()
+	enforced := &annotresourcemodifv1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "add-x", Namespace: "test-ns", Generation: 1},
+		Spec: annotresourcemodifv1.ResourceModifierSpec{
+			ResourceData: annotresourcemodifv1.TargetResourceData{ResourceType: "pod", Namespace: "test-ns", Name: "web"},
+			Annotations:  []string{"addLabel:x:true"},
+			Mode:         annotresourcemodifv1.EnforceMode,
+		},
+	}
+	old := &annotresourcemodifv1.ResourceModifier{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "remove-x",
+			Namespace:  "test-ns",
+			Generation: 1,
+			Finalizers: []string{annotresourcemodifv1.RevertFinalizer},
+		},
+		Spec: annotresourcemodifv1.ResourceModifierSpec{
+			ResourceData:   annotresourcemodifv1.TargetResourceData{ResourceType: "pod", Namespace: "test-ns", Name: "web"},
+			Annotations:    []string{"removeLabel:x"},
+			RevertOnDelete: true,
+		},
+	}
+	old.Status.Completed(annotresourcemodifv1.PhaseSucceeded, 1, "")
+
+	tests := []struct {
+		name    string
+		update  func(rm *annotresourcemodifv1.ResourceModifier)
+		wantErr bool
+	}{
+		{
+			name: "Finalizer of deleted ResourceModifier is removed",
+			update: func(rm *annotresourcemodifv1.ResourceModifier) {
+				rm.DeletionTimestamp = &now
+				rm.Finalizers = nil
+			},
+		},
+		{
+			name: "Metadata is updated",
+			update: func(rm *annotresourcemodifv1.ResourceModifier) {
+				rm.Labels = map[string]string{"team": "web"}
+			},
+		},
+		{
+			name: "Spec is updated",
+			update: func(rm *annotresourcemodifv1.ResourceModifier) {
+				rm.Generation++
+				rm.Spec.Annotations = []string{"removeLabel:x", "addLabel:y:true"}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := &ResourceModifierCustomValidator{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(enforced, old).
+					WithIndex(&annotresourcemodifv1.ResourceModifier{}, targetIndexField, indexTarget).Build(),
+			}
+			updated := old.DeepCopy()
+			tt.update(updated)
+
+			_, err := validator.ValidateUpdate(context.Background(), old, updated)
+			if tt.wantErr {
+				assert.True(t, apierrors.IsInvalid(err))
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"slices"
+	"strings"
+)
+
+// validateDependencies checks that ResourceModifier does not depend on itself, neither directly, nor through other
+// ResourceModifiers in its namespace. Without a client, only direct dependencies are checked.
+func validateDependencies(ctx context.Context, reader client.Reader,
+	rm *annotresourcemodifv1.ResourceModifier) error {
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec", "dependsOn")
+	for i, name := range rm.Spec.DependsOn {
+		if name == rm.Name {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), name, "ResourceModifier can not depend on itself"))
+		}
+	}
+
+	if len(allErrs) == 0 && len(rm.Spec.DependsOn) > 0 && reader != nil {
+		var list annotresourcemodifv1.ResourceModifierList
+		if err := reader.List(ctx, &list, client.InNamespace(rm.Namespace)); err != nil {
+			return apierrors.NewInternalError(fmt.Errorf("failed to list ResourceModifiers: %w", err))
+		}
+
+		graph := make(map[string][]string, len(list.Items)+1)
+		for _, item := range list.Items {
+			graph[item.Name] = item.Spec.DependsOn
+		}
+		graph[rm.Name] = rm.Spec.DependsOn
+
+		if cycle := findCycle(graph, rm.Name); cycle != nil {
+			allErrs = append(allErrs, field.Invalid(path, rm.Spec.DependsOn,
+				"dependencies form a cycle: "+strings.Join(cycle, " -> ")))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: annotresourcemodifv1.GroupVersion.Group, Kind: "ResourceModifier"},
+		rm.Name, allErrs)
+}
+
+// findCycle returns a path of dependencies in the graph, which leads from start back to start, or nil if there is
+// none.
+func findCycle(graph map[string][]string, start string) []string {
+	visited := make(map[string]bool)
+
+	var visit func(path []string) []string
+	visit = func(path []string) []string {
+		for _, dependency := range graph[path[len(path)-1]] {
+			if dependency == start {
+				return append(slices.Clone(path), dependency)
+			}
+			if visited[dependency] {
+				continue
+			}
+			visited[dependency] = true
+			if cycle := visit(append(slices.Clone(path), dependency)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+
+	return visit([]string{start})
+}
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"context"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, annotresourcemodifv1.AddToScheme(scheme))
+
+	resourceModifier := func(name string, dependsOn ...string) *annotresourcemodifv1.ResourceModifier {
+		return &annotresourcemodifv1.ResourceModifier{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Spec:       annotresourcemodifv1.ResourceModifierSpec{DependsOn: dependsOn},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		existing  []client.Object
+		rm        *annotresourcemodifv1.ResourceModifier
+		noClient  bool
+		wantErr   bool
+		wantCycle string
+	}{
+		{
+			name:     "Chain of dependencies",
+			existing: []client.Object{resourceModifier("cordon"), resourceModifier("evict", "cordon")},
+			rm:       resourceModifier("remove-finalizers", "evict"),
+		},
+		{
+			name:    "Dependency on itself",
+			rm:      resourceModifier("cordon", "cordon"),
+			wantErr: true,
+		},
+		{
+			name:     "Dependency on itself without client",
+			rm:       resourceModifier("cordon", "cordon"),
+			noClient: true,
+			wantErr:  true,
+		},
+		{
+			name:      "Cycle through other ResourceModifiers",
+			existing:  []client.Object{resourceModifier("evict", "cordon"), resourceModifier("remove-finalizers", "evict")},
+			rm:        resourceModifier("cordon", "remove-finalizers"),
+			wantErr:   true,
+			wantCycle: "cordon -> remove-finalizers -> evict -> cordon",
+		},
+		{
+			name:     "Updated ResourceModifier breaks the cycle",
+			existing: []client.Object{resourceModifier("evict", "cordon"), resourceModifier("cordon", "evict")},
+			rm:       resourceModifier("cordon"),
+		},
+		{
+			name:     "Cycle not involving ResourceModifier",
+			existing: []client.Object{resourceModifier("a", "b"), resourceModifier("b", "a")},
+			rm:       resourceModifier("c", "a"),
+		},
+		{
+			name: "ResourceModifiers in other namespaces are ignored",
+			existing: []client.Object{&annotresourcemodifv1.ResourceModifier{
+				ObjectMeta: metav1.ObjectMeta{Name: "evict", Namespace: "other-ns"},
+				Spec:       annotresourcemodifv1.ResourceModifierSpec{DependsOn: []string{"cordon"}},
+			}},
+			rm: resourceModifier("cordon", "evict"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reader client.Reader
+			if !tt.noClient {
+				reader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.existing...).Build()
+			}
+
+			err := validateDependencies(context.Background(), reader, tt.rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			if tt.wantCycle != "" {
+				assert.Contains(t, err.Error(), tt.wantCycle)
+			}
+		})
+	}
+}
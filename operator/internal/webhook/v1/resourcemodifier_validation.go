@@ -0,0 +1,365 @@
+package v1
+
+import (
+	"encoding/json"
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"ericsson.com/resource-modif-annotations/internal/controller"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	v2 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+)
+
+// validateResourceModifier validates spec of the ResourceModifier, and returns an Invalid error listing
+// every problem found, or nil if the spec is valid.
+func validateResourceModifier(rm *annotresourcemodifv1.ResourceModifier) error {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateAnnotations(rm.Spec.Annotations, rm.Spec.ResourceData.ResourceType,
+		field.NewPath("spec", "annotations"))...)
+	allErrs = append(allErrs, validatePatches(rm.Spec.Patches, field.NewPath("spec", "patches"))...)
+	allErrs = append(allErrs, validateMode(rm.Spec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateSchedule(rm.Spec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateMaintenanceWindow(rm.Spec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateRetryPolicy(rm.Spec.RetryPolicy, field.NewPath("spec", "retryPolicy"))...)
+	allErrs = append(allErrs, validateTimeouts(rm.Spec.Timeouts, field.NewPath("spec", "timeouts"))...)
+	allErrs = append(allErrs, validateHooks(rm.Spec, field.NewPath("spec", "hooks"))...)
+	allErrs = append(allErrs, validateHealthCheck(rm.Spec, field.NewPath("spec", "healthCheck"))...)
+	allErrs = append(allErrs, validateWhen(rm.Spec.When, field.NewPath("spec", "when"))...)
+	allErrs = append(allErrs, validateRollout(rm.Spec, field.NewPath("spec", "rollout"))...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: annotresourcemodifv1.GroupVersion.Group, Kind: "ResourceModifier"},
+		rm.Name, allErrs)
+}
+
+// validateAnnotations checks that every annotation is known, that its arguments are well-formed, and that the type
+// of the resource supports it.
+func validateAnnotations(annotations []string, resourceType string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, annotation := range annotations {
+		if err := controller.ValidateAnnotationFor(annotation, resourceType); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), annotation, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateMode checks that the spec provides everything the mode and the write strategy of ResourceModifier
+// require.
+func validateMode(spec annotresourcemodifv1.ResourceModifierSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.Mode == annotresourcemodifv1.SelectorMode && len(spec.ResourceData.Labels) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("resourceData", "labels"),
+			"labels must be specified in Selector mode"))
+	}
+	if spec.ForceConflicts && spec.WriteStrategy != annotresourcemodifv1.ServerSideApplyWriteStrategy {
+		allErrs = append(allErrs, field.Forbidden(path.Child("forceConflicts"),
+			"forceConflicts is only used with ServerSideApply writeStrategy"))
+	}
+
+	return allErrs
+}
+
+// validateSchedule checks the schedule of ResourceModifier.
+func validateSchedule(spec annotresourcemodifv1.ResourceModifierSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.Schedule != "" && spec.ExecuteAt != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("executeAt"), "executeAt and schedule are mutually exclusive"))
+	}
+	if spec.Schedule != "" {
+		if err := controller.ValidateSchedule(spec.Schedule, spec.TimeZone); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("schedule"), spec.Schedule, err.Error()))
+		}
+	} else if spec.TimeZone != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("timeZone"), "timeZone is only used with schedule"))
+	}
+
+	scheduled := spec.Schedule != "" || spec.ExecuteAt != nil
+	if scheduled && spec.Mode != "" && spec.Mode != annotresourcemodifv1.OneShotMode {
+		allErrs = append(allErrs, field.Forbidden(path.Child("mode"), "scheduling is only supported in OneShot mode"))
+	}
+	if !scheduled && spec.StartingDeadlineSeconds != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("startingDeadlineSeconds"),
+			"startingDeadlineSeconds is only used with schedule or executeAt"))
+	}
+
+	return allErrs
+}
+
+// validateMaintenanceWindow checks the inline maintenance window of ResourceModifier. Referenced MaintenanceWindow
+// resources are checked by the controller, when they are used.
+func validateMaintenanceWindow(spec annotresourcemodifv1.ResourceModifierSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	window := spec.MaintenanceWindow
+	if window == nil {
+		return allErrs
+	}
+	if spec.MaintenanceWindowName != "" {
+		allErrs = append(allErrs, field.Forbidden(path.Child("maintenanceWindowName"),
+			"maintenanceWindow and maintenanceWindowName are mutually exclusive"))
+	}
+
+	windowPath := path.Child("maintenanceWindow")
+	for i, timeWindow := range window.Windows {
+		if err := controller.ValidateSchedule(timeWindow.Schedule, window.TimeZone); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("windows").Index(i).Child("schedule"),
+				timeWindow.Schedule, err.Error()))
+		}
+		if timeWindow.Duration.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("windows").Index(i).Child("duration"),
+				timeWindow.Duration.String(), "duration must be positive"))
+		}
+	}
+	for i, blackout := range window.Blackouts {
+		if !blackout.End.After(blackout.Start.Time) {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("blackouts").Index(i).Child("end"),
+				blackout.End.String(), "end must be after start"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRetryPolicy checks that the backoffs are positive, and that the initial backoff does not exceed the maximum.
+func validateRetryPolicy(policy *annotresourcemodifv1.RetryPolicy, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if policy == nil {
+		return allErrs
+	}
+	if policy.InitialBackoff != nil && policy.InitialBackoff.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("initialBackoff"), policy.InitialBackoff.String(),
+			"backoff must be positive"))
+	}
+	if policy.MaxBackoff != nil && policy.MaxBackoff.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("maxBackoff"), policy.MaxBackoff.String(),
+			"backoff must be positive"))
+	}
+	if policy.InitialBackoff != nil && policy.MaxBackoff != nil &&
+		policy.InitialBackoff.Duration > policy.MaxBackoff.Duration {
+		allErrs = append(allErrs, field.Invalid(path.Child("initialBackoff"), policy.InitialBackoff.String(),
+			"initialBackoff must not exceed maxBackoff"))
+	}
+
+	return allErrs
+}
+
+// validateTimeouts checks that the timeouts are positive.
+func validateTimeouts(timeouts *annotresourcemodifv1.Timeouts, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if timeouts == nil {
+		return allErrs
+	}
+	if timeouts.Operation != nil && timeouts.Operation.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("operation"), timeouts.Operation.String(),
+			"timeout must be positive"))
+	}
+	if timeouts.Action != nil && timeouts.Action.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("action"), timeouts.Action.String(),
+			"timeout must be positive"))
+	}
+
+	return allErrs
+}
+
+// validateHooks checks that hooks are only used in OneShot mode, their names are unique within the stage, and their
+// templates describe runnable Jobs. Templates are not validated by the schema, so that the CRD stays small.
+func validateHooks(spec annotresourcemodifv1.ResourceModifierSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.Hooks == nil {
+		return allErrs
+	}
+	if spec.Mode == annotresourcemodifv1.EnforceMode || spec.Mode == annotresourcemodifv1.SelectorMode {
+		allErrs = append(allErrs, field.Forbidden(path, "hooks are only supported in OneShot mode"))
+	}
+	stages := []struct {
+		path  *field.Path
+		hooks []annotresourcemodifv1.Hook
+	}{
+		{path: path.Child("pre"), hooks: spec.Hooks.Pre},
+		{path: path.Child("post"), hooks: spec.Hooks.Post},
+	}
+	for _, stage := range stages {
+		names := make(map[string]struct{}, len(stage.hooks))
+		for i, hook := range stage.hooks {
+			hookPath := stage.path.Index(i)
+			if _, exists := names[hook.Name]; exists {
+				allErrs = append(allErrs, field.Duplicate(hookPath.Child("name"), hook.Name))
+			}
+			names[hook.Name] = struct{}{}
+
+			if hook.Timeout != nil && hook.Timeout.Duration <= 0 {
+				allErrs = append(allErrs, field.Invalid(hookPath.Child("timeout"), hook.Timeout.String(),
+					"timeout must be positive"))
+			}
+			podPath := hookPath.Child("template", "spec", "template", "spec")
+			podSpec := hook.Template.Spec.Template.Spec
+			if len(podSpec.Containers) == 0 {
+				allErrs = append(allErrs, field.Required(podPath.Child("containers"),
+					"at least one container must be specified"))
+			}
+			if podSpec.RestartPolicy != v2.RestartPolicyNever && podSpec.RestartPolicy != v2.RestartPolicyOnFailure {
+				allErrs = append(allErrs, field.NotSupported(podPath.Child("restartPolicy"), podSpec.RestartPolicy,
+					[]string{string(v2.RestartPolicyNever), string(v2.RestartPolicyOnFailure)}))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateHealthCheck checks that the health check is only used in OneShot mode, its timeout is positive, and its
+// expression compiles.
+func validateHealthCheck(spec annotresourcemodifv1.ResourceModifierSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	check := spec.HealthCheck
+	if check == nil {
+		return allErrs
+	}
+	if spec.Mode == annotresourcemodifv1.EnforceMode || spec.Mode == annotresourcemodifv1.SelectorMode {
+		allErrs = append(allErrs, field.Forbidden(path, "healthCheck is only supported in OneShot mode"))
+	}
+	if check.Timeout != nil && check.Timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("timeout"), check.Timeout.String(),
+			"timeout must be positive"))
+	}
+	if check.Expression != "" {
+		if err := controller.ValidateExpression(check.Expression); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("expression"), check.Expression, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateWhen checks that every precondition compiles, and evaluates to a boolean.
+func validateWhen(when []string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, expression := range when {
+		if err := controller.ValidateExpression(expression); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), expression, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRollout checks that the rollout is only used in Selector mode, batches are not empty, and percentages
+// are well-formed.
+func validateRollout(spec annotresourcemodifv1.ResourceModifierSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	rollout := spec.Rollout
+	if rollout == nil {
+		return allErrs
+	}
+	if spec.Mode != annotresourcemodifv1.SelectorMode {
+		allErrs = append(allErrs, field.Forbidden(path, "rollout is only supported in Selector mode"))
+	}
+	allErrs = append(allErrs, validateIntOrPercent(rollout.BatchSize, 1, path.Child("batchSize"))...)
+	allErrs = append(allErrs, validateIntOrPercent(rollout.Canary, 1, path.Child("canary"))...)
+	allErrs = append(allErrs, validateIntOrPercent(rollout.MaxFailures, 0, path.Child("maxFailures"))...)
+	if rollout.Pause != nil && rollout.Pause.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("pause"), rollout.Pause.String(), "pause must be positive"))
+	}
+
+	return allErrs
+}
+
+// validateIntOrPercent checks that the value is a number or a percentage (at most 100%), not less than minimum.
+func validateIntOrPercent(value *intstr.IntOrString, minimum int, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if value == nil {
+		return allErrs
+	}
+	if value.Type == intstr.String && !strings.HasSuffix(value.StrVal, "%") {
+		return append(allErrs, field.Invalid(path, value.String(), "must be a number or a percentage"))
+	}
+	// scaled to 100, so a percentage resolves to itself
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(value, 100, false)
+	switch {
+	case err != nil:
+		allErrs = append(allErrs, field.Invalid(path, value.String(), err.Error()))
+	case scaled < minimum:
+		allErrs = append(allErrs, field.Invalid(path, value.String(), fmt.Sprintf("must be at least %d", minimum)))
+	case value.Type == intstr.String && scaled > 100:
+		allErrs = append(allErrs, field.Invalid(path, value.String(), "percentage must not exceed 100%"))
+	}
+
+	return allErrs
+}
+
+// validatePatches checks that every patch can be decoded according to its type.
+func validatePatches(patches []annotresourcemodifv1.Patch, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, patch := range patches {
+		patchPath := path.Index(i).Child("patch")
+		if len(patch.Patch.Raw) == 0 {
+			allErrs = append(allErrs, field.Required(patchPath, "patch content must be provided"))
+			continue
+		}
+
+		switch patch.Type {
+		case annotresourcemodifv1.JSONPatchType:
+			allErrs = append(allErrs, validateJSONPatch(patch.Patch.Raw, patchPath)...)
+		case annotresourcemodifv1.MergePatchType, annotresourcemodifv1.StrategicMergePatchType:
+			var object map[string]interface{}
+			if err := json.Unmarshal(patch.Patch.Raw, &object); err != nil {
+				allErrs = append(allErrs, field.Invalid(patchPath, string(patch.Patch.Raw),
+					"merge patch must be an object"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(path.Index(i).Child("type"), patch.Type,
+				[]string{string(annotresourcemodifv1.JSONPatchType), string(annotresourcemodifv1.MergePatchType),
+					string(annotresourcemodifv1.StrategicMergePatchType)}))
+		}
+	}
+
+	return allErrs
+}
+
+// validateJSONPatch checks that raw is a list of RFC 6902 operations with known op and a path.
+func validateJSONPatch(raw []byte, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	operations, err := jsonpatch.DecodePatch(raw)
+	if err != nil {
+		return append(allErrs, field.Invalid(path, string(raw), "json patch must be a list of operations: "+err.Error()))
+	}
+
+	for i, operation := range operations {
+		switch operation.Kind() {
+		case "add", "remove", "replace", "move", "copy", "test":
+		default:
+			allErrs = append(allErrs, field.NotSupported(path.Index(i).Child("op"), operation.Kind(),
+				[]string{"add", "remove", "replace", "move", "copy", "test"}))
+			continue
+		}
+
+		if _, err := operation.Path(); err != nil {
+			allErrs = append(allErrs, field.Required(path.Index(i).Child("path"), err.Error()))
+		}
+	}
+
+	return allErrs
+}
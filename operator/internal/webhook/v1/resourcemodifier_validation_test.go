@@ -0,0 +1,669 @@
+package v1
+
+import (
+	annotresourcemodifv1 "ericsson.com/resource-modif-annotations/api/v1"
+	"github.com/stretchr/testify/assert"
+	v2 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"testing"
+	"time"
+)
+
+func TestValidateResourceModifier_Patches(t *testing.T) {
+	tests := []struct {
+		name    string
+		patches []annotresourcemodifv1.Patch
+		wantErr bool
+	}{
+		{
+			name: "Valid JSON patch",
+			patches: []annotresourcemodifv1.Patch{{
+				Type: annotresourcemodifv1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{
+					Raw: []byte(`[{"op": "test", "path": "/spec/replicas", "value": 1}, {"op": "remove", "path": "/metadata/labels/a"}]`),
+				},
+			}},
+		},
+		{
+			name: "JSON patch must be a list",
+			patches: []annotresourcemodifv1.Patch{{
+				Type:  annotresourcemodifv1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{"op": "remove", "path": "/a"}`)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Unknown JSON patch operation",
+			patches: []annotresourcemodifv1.Patch{{
+				Type:  annotresourcemodifv1.JSONPatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op": "delete", "path": "/a"}]`)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Valid merge patch",
+			patches: []annotresourcemodifv1.Patch{{
+				Type:  annotresourcemodifv1.MergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`{"metadata": {"labels": {"a": null}}}`)},
+			}},
+		},
+		{
+			name: "Strategic merge patch must be an object",
+			patches: []annotresourcemodifv1.Patch{{
+				Type:  annotresourcemodifv1.StrategicMergePatchType,
+				Patch: apiextensionsv1.JSON{Raw: []byte(`[]`)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Empty patch",
+			patches: []annotresourcemodifv1.Patch{{
+				Type: annotresourcemodifv1.MergePatchType,
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{Patches: tt.patches},
+			}
+			err := validateResourceModifier(rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_Annotations(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		annotations  []string
+		wantErr      bool
+	}{
+		{
+			name:        "Valid annotations",
+			annotations: []string{"addLabel:env:prod", "addServicePort:http:80:8080:TCP:30080"},
+		},
+		{
+			name:        "Node port out of range",
+			annotations: []string{"addServicePort:http:80:8080:TCP:8080"},
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown annotation",
+			annotations: []string{"sleep:50"},
+			wantErr:     true,
+		},
+		{
+			name:         "Affinity of a workload",
+			resourceType: "deployment",
+			annotations:  []string{"addAffinity:nodeAffinity:zone:In:a", "removeToleration:spot"},
+		},
+		{
+			name:         "Affinity of a Pod",
+			resourceType: "pod",
+			annotations:  []string{"addAffinity:nodeAffinity:zone:In:a"},
+			wantErr:      true,
+		},
+		{
+			name:         "Toleration removed from a Pod",
+			resourceType: "Pod",
+			annotations:  []string{"removeToleration:spot"},
+			wantErr:      true,
+		},
+		{
+			name:         "Toleration added to a Pod",
+			resourceType: "pod",
+			annotations:  []string{"addToleration:spot"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := &annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{
+					Annotations:  tt.annotations,
+					ResourceData: annotresourcemodifv1.TargetResourceData{ResourceType: tt.resourceType},
+				},
+			}
+			err := validateResourceModifier(rm)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_Mode(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    annotresourcemodifv1.ResourceModifierSpec
+		wantErr bool
+	}{
+		{
+			name: "Selector mode with labels",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				Mode:         annotresourcemodifv1.SelectorMode,
+				ResourceData: annotresourcemodifv1.TargetResourceData{Labels: map[string]string{"app": "test"}},
+			},
+		},
+		{
+			name: "Selector mode requires labels",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				Mode:         annotresourcemodifv1.SelectorMode,
+				ResourceData: annotresourcemodifv1.TargetResourceData{Name: "test-pod"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Forced conflicts with Server-Side Apply",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				WriteStrategy:  annotresourcemodifv1.ServerSideApplyWriteStrategy,
+				ForceConflicts: true,
+			},
+		},
+		{
+			name:    "Forced conflicts with merge patches",
+			spec:    annotresourcemodifv1.ResourceModifierSpec{ForceConflicts: true},
+			wantErr: true,
+		},
+		{
+			name: "Enforce mode by name",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				Mode:         annotresourcemodifv1.EnforceMode,
+				ResourceData: annotresourcemodifv1.TargetResourceData{Name: "test-pod"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{Spec: tt.spec})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_Schedule(t *testing.T) {
+	stockholm := "Europe/Stockholm"
+	unknown := "Mars/Olympus_Mons"
+	deadline := int64(60)
+
+	tests := []struct {
+		name    string
+		spec    annotresourcemodifv1.ResourceModifierSpec
+		wantErr bool
+	}{
+		{
+			name: "Schedule with time zone",
+			spec: annotresourcemodifv1.ResourceModifierSpec{Schedule: "0 20 * * 1-5", TimeZone: &stockholm},
+		},
+		{
+			name: "Schedule descriptor",
+			spec: annotresourcemodifv1.ResourceModifierSpec{Schedule: "@daily", StartingDeadlineSeconds: &deadline},
+		},
+		{
+			name:    "Malformed schedule",
+			spec:    annotresourcemodifv1.ResourceModifierSpec{Schedule: "0 25 * * *"},
+			wantErr: true,
+		},
+		{
+			name:    "Time zone in schedule",
+			spec:    annotresourcemodifv1.ResourceModifierSpec{Schedule: "CRON_TZ=UTC 0 20 * * *"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown time zone",
+			spec:    annotresourcemodifv1.ResourceModifierSpec{Schedule: "0 20 * * *", TimeZone: &unknown},
+			wantErr: true,
+		},
+		{
+			name: "Schedule and executeAt",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				Schedule:  "0 20 * * *",
+				ExecuteAt: &metav1.Time{Time: time.Now()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Schedule in Enforce mode",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				Schedule: "0 20 * * *",
+				Mode:     annotresourcemodifv1.EnforceMode,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Starting deadline without schedule",
+			spec:    annotresourcemodifv1.ResourceModifierSpec{StartingDeadlineSeconds: &deadline},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{Spec: tt.spec})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_MaintenanceWindow(t *testing.T) {
+	start := metav1.NewTime(time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(time.Date(2027, 1, 6, 0, 0, 0, 0, time.UTC))
+	nightly := annotresourcemodifv1.TimeWindow{Schedule: "0 22 * * *", Duration: metav1.Duration{Duration: 8 * time.Hour}}
+
+	tests := []struct {
+		name    string
+		spec    annotresourcemodifv1.ResourceModifierSpec
+		wantErr bool
+	}{
+		{
+			name: "Windows and blackouts",
+			spec: annotresourcemodifv1.ResourceModifierSpec{MaintenanceWindow: &annotresourcemodifv1.MaintenanceWindowSpec{
+				Windows:   []annotresourcemodifv1.TimeWindow{nightly},
+				Blackouts: []annotresourcemodifv1.Blackout{{Start: start, End: end}},
+			}},
+		},
+		{
+			name: "Reference by name",
+			spec: annotresourcemodifv1.ResourceModifierSpec{MaintenanceWindowName: "nightly"},
+		},
+		{
+			name: "Inline window and reference",
+			spec: annotresourcemodifv1.ResourceModifierSpec{
+				MaintenanceWindow: &annotresourcemodifv1.MaintenanceWindowSpec{
+					Windows: []annotresourcemodifv1.TimeWindow{nightly},
+				},
+				MaintenanceWindowName: "nightly",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Malformed window schedule",
+			spec: annotresourcemodifv1.ResourceModifierSpec{MaintenanceWindow: &annotresourcemodifv1.MaintenanceWindowSpec{
+				Windows: []annotresourcemodifv1.TimeWindow{{Schedule: "0 25 * * *", Duration: nightly.Duration}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Window without duration",
+			spec: annotresourcemodifv1.ResourceModifierSpec{MaintenanceWindow: &annotresourcemodifv1.MaintenanceWindowSpec{
+				Windows: []annotresourcemodifv1.TimeWindow{{Schedule: "0 22 * * *"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Blackout ending before start",
+			spec: annotresourcemodifv1.ResourceModifierSpec{MaintenanceWindow: &annotresourcemodifv1.MaintenanceWindowSpec{
+				Blackouts: []annotresourcemodifv1.Blackout{{Start: end, End: start}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{Spec: tt.spec})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_RetryPolicy(t *testing.T) {
+	second := &metav1.Duration{Duration: time.Second}
+	minute := &metav1.Duration{Duration: time.Minute}
+
+	tests := []struct {
+		name    string
+		policy  *annotresourcemodifv1.RetryPolicy
+		wantErr bool
+	}{
+		{
+			name: "Valid retry policy",
+			policy: &annotresourcemodifv1.RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: second,
+				MaxBackoff:     minute,
+				RetryOn:        []annotresourcemodifv1.ErrorClass{annotresourcemodifv1.NotFoundErrorClass},
+			},
+		},
+		{
+			name:   "Default backoffs",
+			policy: &annotresourcemodifv1.RetryPolicy{MaxAttempts: 3},
+		},
+		{
+			name:    "Initial backoff exceeding maximum",
+			policy:  &annotresourcemodifv1.RetryPolicy{InitialBackoff: minute, MaxBackoff: second},
+			wantErr: true,
+		},
+		{
+			name:    "Negative backoff",
+			policy:  &annotresourcemodifv1.RetryPolicy{MaxBackoff: &metav1.Duration{Duration: -time.Second}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{RetryPolicy: tt.policy},
+			})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_Timeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts *annotresourcemodifv1.Timeouts
+		wantErr  bool
+	}{
+		{
+			name: "Valid timeouts",
+			timeouts: &annotresourcemodifv1.Timeouts{
+				Operation: &metav1.Duration{Duration: 30 * time.Second},
+				Action:    &metav1.Duration{Duration: 15 * time.Minute},
+			},
+		},
+		{
+			name:     "Zero operation timeout",
+			timeouts: &annotresourcemodifv1.Timeouts{Operation: &metav1.Duration{}},
+			wantErr:  true,
+		},
+		{
+			name:     "Negative action timeout",
+			timeouts: &annotresourcemodifv1.Timeouts{Action: &metav1.Duration{Duration: -time.Minute}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{Timeouts: tt.timeouts},
+			})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_Hooks(t *testing.T) {
+	hook := func(name string, containers int, restartPolicy v2.RestartPolicy) annotresourcemodifv1.Hook {
+		hook := annotresourcemodifv1.Hook{Name: name}
+		hook.Template.Spec.Template.Spec.RestartPolicy = restartPolicy
+		for i := 0; i < containers; i++ {
+			hook.Template.Spec.Template.Spec.Containers = append(hook.Template.Spec.Template.Spec.Containers,
+				v2.Container{Name: "backup", Image: "busybox"})
+		}
+		return hook
+	}
+	backup := hook("backup", 1, v2.RestartPolicyNever)
+
+	tests := []struct {
+		name    string
+		mode    annotresourcemodifv1.Mode
+		hooks   *annotresourcemodifv1.Hooks
+		wantErr bool
+	}{
+		{
+			name: "Valid hooks",
+			hooks: &annotresourcemodifv1.Hooks{
+				Pre:  []annotresourcemodifv1.Hook{backup},
+				Post: []annotresourcemodifv1.Hook{backup, hook("verify", 2, v2.RestartPolicyOnFailure)},
+			},
+		},
+		{
+			name:    "Hooks in Enforce mode",
+			mode:    annotresourcemodifv1.EnforceMode,
+			hooks:   &annotresourcemodifv1.Hooks{Pre: []annotresourcemodifv1.Hook{backup}},
+			wantErr: true,
+		},
+		{
+			name:    "Duplicate names within the stage",
+			hooks:   &annotresourcemodifv1.Hooks{Pre: []annotresourcemodifv1.Hook{backup, backup}},
+			wantErr: true,
+		},
+		{
+			name:    "No containers",
+			hooks:   &annotresourcemodifv1.Hooks{Pre: []annotresourcemodifv1.Hook{hook("backup", 0, v2.RestartPolicyNever)}},
+			wantErr: true,
+		},
+		{
+			name:    "Restart policy not supported by Jobs",
+			hooks:   &annotresourcemodifv1.Hooks{Post: []annotresourcemodifv1.Hook{hook("verify", 1, v2.RestartPolicyAlways)}},
+			wantErr: true,
+		},
+		{
+			name: "Zero timeout",
+			hooks: &annotresourcemodifv1.Hooks{Pre: []annotresourcemodifv1.Hook{{
+				Name: "backup", Template: backup.Template, Timeout: &metav1.Duration{},
+			}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{
+					Mode:  tt.mode,
+					Hooks: tt.hooks,
+				},
+			})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_HealthCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        annotresourcemodifv1.Mode
+		healthCheck *annotresourcemodifv1.HealthCheck
+		wantErr     bool
+	}{
+		{
+			name: "Valid health check",
+			healthCheck: &annotresourcemodifv1.HealthCheck{
+				Timeout:    &metav1.Duration{Duration: 10 * time.Minute},
+				Expression: "object.status.readyReplicas >= 2",
+				Rollback:   true,
+			},
+		},
+		{
+			name:        "Health check in Selector mode",
+			mode:        annotresourcemodifv1.SelectorMode,
+			healthCheck: &annotresourcemodifv1.HealthCheck{},
+			wantErr:     true,
+		},
+		{
+			name:        "Negative timeout",
+			healthCheck: &annotresourcemodifv1.HealthCheck{Timeout: &metav1.Duration{Duration: -time.Minute}},
+			wantErr:     true,
+		},
+		{
+			name:        "Expression does not compile",
+			healthCheck: &annotresourcemodifv1.HealthCheck{Expression: "object.status.readyReplicas >="},
+			wantErr:     true,
+		},
+		{
+			name:        "Expression is not a boolean",
+			healthCheck: &annotresourcemodifv1.HealthCheck{Expression: "'ready'"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{
+					Mode:         tt.mode,
+					ResourceData: annotresourcemodifv1.TargetResourceData{Labels: map[string]string{"app": "web"}},
+					HealthCheck:  tt.healthCheck,
+				},
+			})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_Rollout(t *testing.T) {
+	number := intstr.FromInt32
+	percent := intstr.FromString
+
+	tests := []struct {
+		name    string
+		mode    annotresourcemodifv1.Mode
+		rollout annotresourcemodifv1.Rollout
+		wantErr bool
+	}{
+		{
+			name: "Valid rollout",
+			mode: annotresourcemodifv1.SelectorMode,
+			rollout: annotresourcemodifv1.Rollout{
+				BatchSize:   ptr.To(percent("10%")),
+				Canary:      ptr.To(number(1)),
+				Pause:       &metav1.Duration{Duration: 5 * time.Minute},
+				MaxFailures: ptr.To(number(0)),
+			},
+		},
+		{
+			name:    "Rollout in OneShot mode",
+			rollout: annotresourcemodifv1.Rollout{BatchSize: ptr.To(number(10))},
+			wantErr: true,
+		},
+		{
+			name:    "Empty batch",
+			mode:    annotresourcemodifv1.SelectorMode,
+			rollout: annotresourcemodifv1.Rollout{BatchSize: ptr.To(percent("0%"))},
+			wantErr: true,
+		},
+		{
+			name:    "Malformed percentage",
+			mode:    annotresourcemodifv1.SelectorMode,
+			rollout: annotresourcemodifv1.Rollout{Canary: ptr.To(percent("ten"))},
+			wantErr: true,
+		},
+		{
+			name:    "Percentage over 100",
+			mode:    annotresourcemodifv1.SelectorMode,
+			rollout: annotresourcemodifv1.Rollout{MaxFailures: ptr.To(percent("150%"))},
+			wantErr: true,
+		},
+		{
+			name:    "Zero pause",
+			mode:    annotresourcemodifv1.SelectorMode,
+			rollout: annotresourcemodifv1.Rollout{Pause: &metav1.Duration{}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{
+					Mode:         tt.mode,
+					ResourceData: annotresourcemodifv1.TargetResourceData{Labels: map[string]string{"app": "web"}},
+					Rollout:      &tt.rollout,
+				},
+			})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourceModifier_When(t *testing.T) {
+	tests := []struct {
+		name    string
+		when    []string
+		wantErr bool
+	}{
+		{
+			name: "Valid preconditions",
+			when: []string{
+				"object.status.phase == 'Pending' && object.metadata.deletionTimestamp != null",
+				"object.metadata.labels['app'] == 'web'",
+			},
+		},
+		{
+			name:    "Expression does not compile",
+			when:    []string{"object.status.phase == 'Pending'", "object.status.phase =="},
+			wantErr: true,
+		},
+		{
+			name:    "Type of the field is only known at runtime",
+			when:    []string{"object.status.ready"},
+			wantErr: true,
+		},
+		{
+			name: "Field compared to a boolean",
+			when: []string{"object.status.ready == true"},
+		},
+		{
+			name:    "Expression is a string literal",
+			when:    []string{"'Pending'"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceModifier(&annotresourcemodifv1.ResourceModifier{
+				Spec: annotresourcemodifv1.ResourceModifierSpec{
+					ResourceData: annotresourcemodifv1.TargetResourceData{Name: "web"},
+					When:         tt.when,
+				},
+			})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
@@ -20,8 +20,10 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -34,9 +36,15 @@ import (
 var resourcemodifierlog = logf.Log.WithName("resourcemodifier-resource")
 
 // SetupResourceModifierWebhookWithManager registers the webhook for ResourceModifier in the manager.
+// ResourceModifiers are indexed by their target, to detect conflicts between them.
 func SetupResourceModifierWebhookWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &annotresourcemodifv1.ResourceModifier{},
+		targetIndexField, indexTarget); err != nil {
+		return err
+	}
+
 	return ctrl.NewWebhookManagedBy(mgr).For(&annotresourcemodifv1.ResourceModifier{}).
-		WithValidator(&ResourceModifierCustomValidator{}).
+		WithValidator(&ResourceModifierCustomValidator{Client: mgr.GetClient()}).
 		WithDefaulter(&ResourceModifierCustomDefaulter{}).
 		Complete()
 }
@@ -81,7 +89,9 @@ func (d *ResourceModifierCustomDefaulter) Default(ctx context.Context, obj runti
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type ResourceModifierCustomValidator struct {
-	//TODO(user): Add more fields as needed for validation
+	// Client reads other ResourceModifiers, to detect cycles of dependencies and conflicts. Optional.
+	// It must index ResourceModifiers by their target, see SetupResourceModifierWebhookWithManager.
+	Client client.Reader
 }
 
 var _ webhook.CustomValidator = &ResourceModifierCustomValidator{}
@@ -94,9 +104,13 @@ func (v *ResourceModifierCustomValidator) ValidateCreate(ctx context.Context, ob
 	}
 	resourcemodifierlog.Info("Validation for ResourceModifier upon creation", "name", resourcemodifier.GetName())
 
-	// TODO(user): fill in your validation logic upon object creation.
-
-	return nil, nil
+	if err := validateResourceModifier(resourcemodifier); err != nil {
+		return nil, err
+	}
+	if err := validateDependencies(ctx, v.Client, resourcemodifier); err != nil {
+		return nil, err
+	}
+	return validateConflicts(ctx, v.Client, resourcemodifier)
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ResourceModifier.
@@ -107,9 +121,19 @@ func (v *ResourceModifierCustomValidator) ValidateUpdate(ctx context.Context, ol
 	}
 	resourcemodifierlog.Info("Validation for ResourceModifier upon update", "name", resourcemodifier.GetName())
 
-	// TODO(user): fill in your validation logic upon object update.
-
-	return nil, nil
+	if err := validateResourceModifier(resourcemodifier); err != nil {
+		return nil, err
+	}
+	if err := validateDependencies(ctx, v.Client, resourcemodifier); err != nil {
+		return nil, err
+	}
+	// updates of metadata or status, e.g. removal of the finalizer of a deleted ResourceModifier, can not introduce
+	// new conflicts, and must not be blocked by ResourceModifiers created since
+	old, ok := oldObj.(*annotresourcemodifv1.ResourceModifier)
+	if resourcemodifier.DeletionTimestamp != nil || ok && equality.Semantic.DeepEqual(old.Spec, resourcemodifier.Spec) {
+		return nil, nil
+	}
+	return validateConflicts(ctx, v.Client, resourcemodifier)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ResourceModifier.